@@ -57,6 +57,9 @@ func (b *arccache) hasCached(k *cid.Cid) (has bool, ok bool) {
 		// in case of invalid key and correct error is created.
 		return false, false
 	}
+	if IsIdentity(k) {
+		return true, true
+	}
 
 	h, ok := b.arc.Get(k.KeyString())
 	if ok {
@@ -129,6 +132,9 @@ func (b *arccache) PutMany(bs []blocks.Block) error {
 }
 
 func (b *arccache) addCache(c *cid.Cid, has bool) {
+	if IsIdentity(c) {
+		return
+	}
 	b.arc.Add(c.KeyString(), has)
 }
 
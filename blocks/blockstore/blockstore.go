@@ -98,6 +98,9 @@ func (bs *blockstore) Get(k *cid.Cid) (blocks.Block, error) {
 		log.Error("nil cid in blockstore")
 		return nil, ErrNotFound
 	}
+	if blk, ok := IdentityBlock(k); ok {
+		return blk, nil
+	}
 
 	maybeData, err := bs.datastore.Get(dshelp.CidToDsKey(k))
 	if err == ds.ErrNotFound {
@@ -128,6 +131,9 @@ func (bs *blockstore) Get(k *cid.Cid) (blocks.Block, error) {
 }
 
 func (bs *blockstore) Put(block blocks.Block) error {
+	if IsIdentity(block.Cid()) {
+		return nil
+	}
 	k := dshelp.CidToDsKey(block.Cid())
 
 	// Has is cheaper than Put, so see if we already have it
@@ -144,6 +150,9 @@ func (bs *blockstore) PutMany(blocks []blocks.Block) error {
 		return err
 	}
 	for _, b := range blocks {
+		if IsIdentity(b.Cid()) {
+			continue
+		}
 		k := dshelp.CidToDsKey(b.Cid())
 		exists, err := bs.datastore.Has(k)
 		if err == nil && exists {
@@ -159,16 +168,25 @@ func (bs *blockstore) PutMany(blocks []blocks.Block) error {
 }
 
 func (bs *blockstore) Has(k *cid.Cid) (bool, error) {
+	if IsIdentity(k) {
+		return true, nil
+	}
 	return bs.datastore.Has(dshelp.CidToDsKey(k))
 }
 
 func (s *blockstore) DeleteBlock(k *cid.Cid) error {
+	if IsIdentity(k) {
+		return nil
+	}
 	return s.datastore.Delete(dshelp.CidToDsKey(k))
 }
 
 // AllKeysChan runs a query for keys from the blockstore.
 // this is very simplistic, in the future, take dsq.Query as a param?
 //
+// Blocks inlined in identity cids are not stored, so they are not listed,
+// even if an older version stored some.
+//
 // AllKeysChan respects context
 func (bs *blockstore) AllKeysChan(ctx context.Context) (<-chan *cid.Cid, error) {
 
@@ -204,6 +222,9 @@ func (bs *blockstore) AllKeysChan(ctx context.Context) (<-chan *cid.Cid, error)
 				log.Warningf("error parsing key from DsKey: ", err)
 				continue
 			}
+			if IsIdentity(k) {
+				continue
+			}
 
 			select {
 			case <-ctx.Done():
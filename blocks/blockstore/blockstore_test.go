@@ -12,6 +12,7 @@ import (
 	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
 	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
 	ds_sync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	mh "gx/ipfs/QmYDds3421prZgqKbLpEK7T9Aa2eVdQ7o3YarX1LVLdP2J/go-multihash"
 	u "gx/ipfs/Qmb912gdngC1UWwTkhuW8knyRbcWeu5kqkxBpveLmW8bSr/go-ipfs-util"
 	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
@@ -82,6 +83,49 @@ func TestHashOnRead(t *testing.T) {
 	}
 }
 
+func TestHashOnReadUsesCidPrefix(t *testing.T) {
+	orginalDebug := u.Debug
+	defer (func() {
+		u.Debug = orginalDebug
+	})()
+	u.Debug = false
+
+	pref := cid.Prefix{
+		Version:  1,
+		Codec:    cid.Raw,
+		MhType:   mh.SHA2_512,
+		MhLength: -1,
+	}
+	c, err := pref.Sum([]byte("some data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := NewBlockstore(ds_sync.MutexWrap(ds.NewMapDatastore()))
+	bl, err := blocks.NewBlockWithCid([]byte("some data"), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blBad, err := blocks.NewBlockWithCid([]byte("some other data"), c)
+	if err != nil {
+		t.Fatal("debug is off, still got an error")
+	}
+	bs.HashOnRead(true)
+
+	bs.Put(bl)
+	if b, err := bs.Get(c); err != nil || !bytes.Equal(b.RawData(), bl.RawData()) {
+		t.Fatal("a sha2-512 block did not verify: ", err)
+	}
+
+	if err := bs.DeleteBlock(c); err != nil {
+		t.Fatal(err)
+	}
+	bs.Put(blBad)
+	if _, err := bs.Get(c); err != ErrHashMismatch {
+		t.Fatalf("expected '%v' got '%v'\n", ErrHashMismatch, err)
+	}
+}
+
 func newBlockStoreWithKeys(t *testing.T, d ds.Datastore, N int) (Blockstore, []*cid.Cid) {
 	if d == nil {
 		d = ds.NewMapDatastore()
@@ -115,6 +115,9 @@ func (b *bloomcache) hasCached(k *cid.Cid) (has bool, ok bool) {
 		// in case of invalid key is forwarded deeper
 		return false, false
 	}
+	if IsIdentity(k) {
+		return true, true
+	}
 	if b.BloomActive() {
 		blr := b.bloom.HasTS(k.Bytes())
 		if blr == false { // not contained in bloom is only conclusive answer bloom gives
@@ -0,0 +1,43 @@
+package blockstore
+
+import (
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	mh "gx/ipfs/QmYDds3421prZgqKbLpEK7T9Aa2eVdQ7o3YarX1LVLdP2J/go-multihash"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// IsIdentity returns whether k uses the identity multihash, that is whether
+// the data of its block is inlined in the cid itself. Such blocks are never
+// stored nor fetched from the network.
+func IsIdentity(k *cid.Cid) bool {
+	_, ok := identityData(k)
+	return ok
+}
+
+// IdentityBlock returns the block inlined in k, if k uses the identity
+// multihash.
+func IdentityBlock(k *cid.Cid) (blocks.Block, bool) {
+	data, ok := identityData(k)
+	if !ok {
+		return nil, false
+	}
+
+	blk, err := blocks.NewBlockWithCid(data, k)
+	if err != nil {
+		return nil, false
+	}
+	return blk, true
+}
+
+func identityData(k *cid.Cid) ([]byte, bool) {
+	if k == nil {
+		return nil, false
+	}
+
+	dmh, err := mh.Decode(k.Hash())
+	if err != nil || dmh.Code != mh.ID {
+		return nil, false
+	}
+	return dmh.Digest, true
+}
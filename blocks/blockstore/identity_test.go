@@ -0,0 +1,112 @@
+package blockstore
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	dshelp "github.com/ipfs/go-ipfs/thirdparty/ds-help"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	syncds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	mh "gx/ipfs/QmYDds3421prZgqKbLpEK7T9Aa2eVdQ7o3YarX1LVLdP2J/go-multihash"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+func identityBlock(t *testing.T, data []byte) blocks.Block {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: mh.ID, MhLength: -1}
+	c, err := pref.Sum(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blk
+}
+
+func testIdentityBlocks(t *testing.T, bs Blockstore, d ds.Datastore) {
+	blk := identityBlock(t, []byte("tiny"))
+
+	if err := bs.Put(blk); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.PutMany([]blocks.Block{blk}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := d.Query(dsq.Query{KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := res.Rest(); len(entries) != 0 {
+		t.Fatal("identity block was stored")
+	}
+
+	has, err := bs.Has(blk.Cid())
+	if err != nil || !has {
+		t.Fatal("blockstore should have identity blocks")
+	}
+
+	out, err := bs.Get(blk.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.RawData(), []byte("tiny")) {
+		t.Fatal("wrong identity block data")
+	}
+
+	// deleting is a no-op, the block is still there
+	if err := bs.DeleteBlock(blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := bs.Has(blk.Cid()); !has {
+		t.Fatal("identity block disappeared")
+	}
+}
+
+func TestIdentityBlocks(t *testing.T) {
+	d := syncds.MutexWrap(ds.NewMapDatastore())
+	testIdentityBlocks(t, NewBlockstore(d), d)
+}
+
+func TestIdentityBlocksCached(t *testing.T) {
+	d := syncds.MutexWrap(ds.NewMapDatastore())
+	opts := DefaultCacheOpts()
+	cbs, err := CachedBlockstore(NewBlockstore(d), context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testIdentityBlocks(t, cbs, d)
+}
+
+func TestAllKeysSkipsIdentity(t *testing.T) {
+	d := syncds.MutexWrap(ds.NewMapDatastore())
+	bs := NewBlockstore(d)
+
+	// store an identity block behind the back of the blockstore, as older
+	// versions did
+	blk := identityBlock(t, []byte("tiny"))
+	if err := bs.datastore.Put(dshelp.CidToDsKey(blk.Cid()), blk.RawData()); err != nil {
+		t.Fatal(err)
+	}
+	regular := blocks.NewBlock([]byte("regular"))
+	if err := bs.Put(regular); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := bs.AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []*cid.Cid
+	for k := range ch {
+		keys = append(keys, k)
+	}
+	if len(keys) != 1 || !keys[0].Equals(regular.Cid()) {
+		t.Fatalf("expected only the regular block, got %v", keys)
+	}
+}
@@ -0,0 +1,337 @@
+package blockstore
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-ipfs/blocks"
+	dshelp "github.com/ipfs/go-ipfs/thirdparty/ds-help"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	lru "gx/ipfs/QmVYxfoJQiZijTgPNHCHgHELvQpbsJNTg6Crmc3dQkj3yy/golang-lru"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// blockUseKey is the prefix under which the last use of each block is
+// recorded, for the order of use to survive restarts.
+var blockUseKey = ds.NewKey("/local/blockuse")
+
+const (
+	// useResolution is how coarsely the uses of blocks are recorded: a
+	// block used again within the same period is not recorded again.
+	useResolution = time.Minute
+
+	// useBatchSize is the number of uses recorded together.
+	useBatchSize = 1024
+)
+
+// DefaultLRUSize is the default number of blocks whose use is tracked by an
+// LRUBlockstore, which takes about a hundred bytes of memory each.
+const DefaultLRUSize = 1 << 20
+
+// LRUBlockstore is a Blockstore keeping track of the order its blocks were
+// last used in, so that the least recently used ones can be evicted first.
+// Getting and putting a block counts as using it.
+//
+// The last use of each block is also recorded in a datastore, to the
+// minute and in batches, so that the blocks keep their order across
+// restarts and when more of them are used than can be tracked in memory.
+// Close records the uses not written yet, without closing the wrapped
+// blockstore.
+type LRUBlockstore interface {
+	Blockstore
+	io.Closer
+
+	// LeastRecentlyUsed sends the keys of the blockstore on the returned
+	// channel, from the least to the most recently used. The blocks never
+	// used since their use is recorded come first, then the ones whose use
+	// is only recorded in the datastore, by time of use, then the ones
+	// tracked in memory.
+	LeastRecentlyUsed(ctx context.Context) (<-chan *cid.Cid, error)
+
+	// Untracked returns the wrapped blockstore, to read blocks without it
+	// counting as using them, e.g. to mark them in a collection.
+	Untracked() Blockstore
+}
+
+// NewLRUBlockstore wraps bs, tracking the use of up to size blocks in
+// memory and recording it in d. The blocks used most recently according
+// to the records in d are tracked from the start.
+func NewLRUBlockstore(bs Blockstore, size int, d ds.Batching) (LRUBlockstore, error) {
+	if size <= 0 {
+		size = DefaultLRUSize
+	}
+	used, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	b := &lrubs{
+		Blockstore: bs,
+		used:       used,
+		d:          d,
+		pending:    make(map[string]*blockUse),
+	}
+
+	recorded, err := b.recorded(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range recorded {
+		used.Add(u.c.KeyString(), u)
+	}
+	return b, nil
+}
+
+// blockUse is the last use of a block, in units of useResolution.
+type blockUse struct {
+	c    *cid.Cid
+	time int64
+}
+
+type lrubs struct {
+	Blockstore
+
+	// used maps the key strings of the blocks used to their *blockUse
+	used *lru.Cache
+
+	d ds.Batching
+
+	lk sync.Mutex
+	// pending holds the uses not recorded in d yet, by key string
+	pending map[string]*blockUse
+}
+
+func useKey(c *cid.Cid) ds.Key {
+	return blockUseKey.Child(dshelp.CidToDsKey(c))
+}
+
+func (b *lrubs) touch(c *cid.Cid) {
+	if IsIdentity(c) {
+		return
+	}
+
+	k := c.KeyString()
+	now := time.Now().UnixNano() / int64(useResolution)
+	if v, ok := b.used.Get(k); ok && v.(*blockUse).time == now {
+		return
+	}
+	u := &blockUse{c: c, time: now}
+	b.used.Add(k, u)
+
+	b.lk.Lock()
+	b.pending[k] = u
+	full := len(b.pending) >= useBatchSize
+	b.lk.Unlock()
+
+	if full {
+		if err := b.Flush(); err != nil {
+			log.Errorf("recording the use of blocks: %s", err)
+		}
+	}
+}
+
+// Flush records the pending uses in the datastore.
+func (b *lrubs) Flush() error {
+	b.lk.Lock()
+	pending := b.pending
+	b.pending = make(map[string]*blockUse)
+	b.lk.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	batch, err := b.d.Batch()
+	if err != nil {
+		return err
+	}
+	for _, u := range pending {
+		buf := make([]byte, binary.MaxVarintLen64)
+		if err := batch.Put(useKey(u.c), buf[:binary.PutUvarint(buf, uint64(u.time))]); err != nil {
+			return err
+		}
+	}
+	return batch.Commit()
+}
+
+func (b *lrubs) Close() error {
+	return b.Flush()
+}
+
+// recorded returns the uses recorded in the datastore, oldest first,
+// leaving out the blocks in skip.
+func (b *lrubs) recorded(skip map[string]struct{}) ([]*blockUse, error) {
+	res, err := b.d.Query(dsq.Query{Prefix: blockUseKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var uses []*blockUse
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+
+		c, err := dshelp.DsKeyToCid(ds.RawKey(e.Key[len(blockUseKey.String()):]))
+		if err != nil {
+			log.Errorf("bad block use key %s: %s", e.Key, err)
+			continue
+		}
+		if _, ok := skip[c.KeyString()]; ok {
+			continue
+		}
+		v, ok := e.Value.([]byte)
+		if !ok {
+			log.Errorf("bad block use record for %s", c)
+			continue
+		}
+		t, n := binary.Uvarint(v)
+		if n <= 0 {
+			log.Errorf("bad block use record for %s", c)
+			continue
+		}
+		uses = append(uses, &blockUse{c: c, time: int64(t)})
+	}
+
+	sort.Stable(byTime(uses))
+	return uses, nil
+}
+
+type byTime []*blockUse
+
+func (s byTime) Len() int           { return len(s) }
+func (s byTime) Less(i, j int) bool { return s[i].time < s[j].time }
+func (s byTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (b *lrubs) Get(k *cid.Cid) (blocks.Block, error) {
+	bl, err := b.Blockstore.Get(k)
+	if err == nil {
+		b.touch(k)
+	}
+	return bl, err
+}
+
+func (b *lrubs) Put(bl blocks.Block) error {
+	err := b.Blockstore.Put(bl)
+	if err == nil {
+		b.touch(bl.Cid())
+	}
+	return err
+}
+
+func (b *lrubs) PutMany(bs []blocks.Block) error {
+	err := b.Blockstore.PutMany(bs)
+	if err == nil {
+		for _, bl := range bs {
+			b.touch(bl.Cid())
+		}
+	}
+	return err
+}
+
+func (b *lrubs) DeleteBlock(k *cid.Cid) error {
+	key := k.KeyString()
+	b.used.Remove(key)
+	b.lk.Lock()
+	delete(b.pending, key)
+	b.lk.Unlock()
+
+	if err := b.Blockstore.DeleteBlock(k); err != nil {
+		return err
+	}
+	if err := b.d.Delete(useKey(k)); err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+func (b *lrubs) Untracked() Blockstore {
+	return b.Blockstore
+}
+
+func (b *lrubs) LeastRecentlyUsed(ctx context.Context) (<-chan *cid.Cid, error) {
+	if err := b.Flush(); err != nil {
+		return nil, err
+	}
+
+	// the order is taken first, so that the blocks used while going
+	// through the blockstore come last
+	used := b.used.Keys()
+	tracked := make(map[string]struct{}, len(used))
+	for _, k := range used {
+		tracked[k.(string)] = struct{}{}
+	}
+
+	// the blocks which fell out of the tracked ones, or were last used
+	// before a restart and not tracked from the start, by time of use
+	recorded, err := b.recorded(tracked)
+	if err != nil {
+		return nil, err
+	}
+	hasRecord := make(map[string]struct{}, len(recorded))
+	for _, u := range recorded {
+		hasRecord[u.c.KeyString()] = struct{}{}
+	}
+
+	keys, err := b.Blockstore.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *cid.Cid, dsq.KeysOnlyBufSize)
+	go func() {
+		defer close(out)
+
+		send := func(c *cid.Cid) bool {
+			select {
+			case out <- c:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// only the recorded blocks still in the blockstore are sent
+		present := make(map[string]struct{}, len(recorded))
+		for c := range keys {
+			k := c.KeyString()
+			if _, ok := tracked[k]; ok {
+				continue
+			}
+			if _, ok := hasRecord[k]; ok {
+				present[k] = struct{}{}
+				continue
+			}
+			if !send(c) {
+				return
+			}
+		}
+
+		for _, u := range recorded {
+			if _, ok := present[u.c.KeyString()]; !ok {
+				continue
+			}
+			if !send(u.c) {
+				return
+			}
+		}
+
+		for _, k := range used {
+			v, ok := b.used.Peek(k)
+			if !ok {
+				// deleted since
+				continue
+			}
+			if !send(v.(*blockUse).c) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,145 @@
+package blockstore
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/blocks"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	syncds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+)
+
+func TestLeastRecentlyUsed(t *testing.T) {
+	d := syncds.MutexWrap(ds.NewMapDatastore())
+	bs := NewBlockstore(d)
+
+	var blks []blocks.Block
+	for i := 0; i < 5; i++ {
+		blk := blocks.NewBlock([]byte(fmt.Sprintf("block %d", i)))
+		blks = append(blks, blk)
+	}
+
+	// the first block is in the blockstore before it is tracked
+	if err := bs.Put(blks[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	lbs, err := NewLRUBlockstore(bs, 3, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, blk := range blks[1:] {
+		if err := lbs.Put(blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 1 falls out of the tracked blocks, 2 is used again, 3 deleted
+	if _, err := lbs.Get(blks[2].Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := lbs.DeleteBlock(blks[3].Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	// reading 4 without tracking it leaves it before 2
+	if _, err := lbs.Untracked().Get(blks[4].Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := lbs.LeastRecentlyUsed(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for k := range keys {
+		order = append(order, k.KeyString())
+	}
+
+	// 0 was never used, 1 is only recorded in the datastore
+	expected := []blocks.Block{blks[0], blks[1], blks[4], blks[2]}
+	checkOrder(t, order, expected)
+
+	has, err := d.Has(useKey(blks[3].Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Fatal("use of deleted block still recorded")
+	}
+}
+
+func TestLeastRecentlyUsedRecorded(t *testing.T) {
+	d := syncds.MutexWrap(ds.NewMapDatastore())
+	bs := NewBlockstore(d)
+
+	var blks []blocks.Block
+	for i := 0; i < 5; i++ {
+		blk := blocks.NewBlock([]byte(fmt.Sprintf("block %d", i)))
+		if err := bs.Put(blk); err != nil {
+			t.Fatal(err)
+		}
+		blks = append(blks, blk)
+	}
+
+	// uses recorded before a restart, 4 of a block since removed
+	times := map[int]uint64{1: 30, 2: 10, 3: 20}
+	for i, tm := range times {
+		buf := make([]byte, binary.MaxVarintLen64)
+		if err := d.Put(useKey(blks[i].Cid()), buf[:binary.PutUvarint(buf, tm)]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bs.DeleteBlock(blks[4].Cid()); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	if err := d.Put(useKey(blks[4].Cid()), buf[:binary.PutUvarint(buf, 5)]); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the most recently used block fits in memory
+	lbs, err := NewLRUBlockstore(bs, 1, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := lbs.LeastRecentlyUsed(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for k := range keys {
+		order = append(order, k.KeyString())
+	}
+	checkOrder(t, order, []blocks.Block{blks[0], blks[2], blks[3], blks[1]})
+
+	// using a block records it once closed
+	if _, err := lbs.Get(blks[0].Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := lbs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	has, err := d.Has(useKey(blks[0].Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has {
+		t.Fatal("use of block not recorded")
+	}
+}
+
+func checkOrder(t *testing.T, order []string, expected []blocks.Block) {
+	if len(order) != len(expected) {
+		t.Fatalf("expected %d keys, got %d", len(expected), len(order))
+	}
+	for i, blk := range expected {
+		if order[i] != blk.Cid().KeyString() {
+			t.Fatalf("key %d is not the one of %q", i, blk.RawData())
+		}
+	}
+}
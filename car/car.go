@@ -0,0 +1,132 @@
+// Package car implements the CAR (content addressable archive) format, a
+// simple container for shipping a DAG as a single stream of blocks.
+//
+// A CAR stream is a varint length prefixed DAG-CBOR header naming the root
+// CIDs, followed by one varint length prefixed section per block, each
+// holding the binary CID and the raw block data.
+package car
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// Version is the version of the CAR format written by this package.
+const Version = 1
+
+// WriteCar writes a CAR stream containing every block of the DAG under
+// root to w. Blocks are written once each, in depth first pre-order, so
+// the output for a given DAG is deterministic.
+func WriteCar(ctx context.Context, ds dag.DAGService, root *cid.Cid, w io.Writer) error {
+	return WriteCarSelected(ctx, ds, root, nil, w)
+}
+
+// WriteCarSelected is like WriteCar, but only writes the blocks of the part
+// of the DAG selected by sel.
+func WriteCarSelected(ctx context.Context, ds dag.DAGService, root *cid.Cid, sel *dag.Selector, w io.Writer) error {
+	bufw := bufio.NewWriter(w)
+
+	if err := writeSection(bufw, encodeHeader([]*cid.Cid{root})); err != nil {
+		return err
+	}
+
+	writeNode := func(c *cid.Cid) error {
+		nd, err := ds.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		return writeSection(bufw, c.Bytes(), nd.RawData())
+	}
+
+	seen := cid.NewSet()
+	seen.Add(root)
+	if err := writeNode(root); err != nil {
+		return err
+	}
+
+	err := dag.Walk(ctx, ds, root, sel, func(_ *cid.Cid, lnk *node.Link, _ int) (bool, error) {
+		if !seen.Visit(lnk.Cid) {
+			return false, nil
+		}
+		return true, writeNode(lnk.Cid)
+	})
+	if err != nil {
+		return err
+	}
+
+	return bufw.Flush()
+}
+
+// writeSection writes the concatenation of parts to w, prefixed by its
+// total length as an unsigned varint.
+func writeSection(w io.Writer, parts ...[]byte) error {
+	var size int
+	for _, p := range parts {
+		size += len(p)
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(size))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+
+	for _, p := range parts {
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeHeader returns the canonical DAG-CBOR encoding of
+// {"roots": [<root>...], "version": 1}.
+func encodeHeader(roots []*cid.Cid) []byte {
+	var out []byte
+	out = append(out, 0xa2) // map, 2 entries
+	out = appendCborString(out, "roots")
+	out = appendCborHead(out, 4, uint64(len(roots)))
+	for _, r := range roots {
+		// CIDs are tag 42 over a byte string with a leading zero byte
+		out = append(out, 0xd8, 42)
+		b := r.Bytes()
+		out = appendCborHead(out, 2, uint64(len(b)+1))
+		out = append(out, 0)
+		out = append(out, b...)
+	}
+	out = appendCborString(out, "version")
+	out = appendCborHead(out, 0, Version)
+	return out
+}
+
+func appendCborString(out []byte, s string) []byte {
+	out = appendCborHead(out, 3, uint64(len(s)))
+	return append(out, s...)
+}
+
+func appendCborHead(out []byte, major byte, v uint64) []byte {
+	major <<= 5
+	switch {
+	case v < 24:
+		return append(out, major|byte(v))
+	case v <= 0xff:
+		return append(out, major|24, byte(v))
+	case v <= 0xffff:
+		return append(out, major|25, byte(v>>8), byte(v))
+	case v <= 0xffffffff:
+		return append(out, major|26, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		out = append(out, major|27)
+		for i := 7; i >= 0; i-- {
+			out = append(out, byte(v>>(uint(i)*8)))
+		}
+		return out
+	}
+}
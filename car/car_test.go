@@ -0,0 +1,167 @@
+package car
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	dstest "github.com/ipfs/go-ipfs/merkledag/test"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+func readSections(t *testing.T, b []byte) [][]byte {
+	var out [][]byte
+	for len(b) > 0 {
+		l, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < l {
+			t.Fatal("truncated section")
+		}
+		out = append(out, b[n:n+int(l)])
+		b = b[n+int(l):]
+	}
+	return out
+}
+
+func TestWriteCar(t *testing.T) {
+	ds := dstest.Mock()
+
+	a := dag.NodeWithData([]byte("aaa"))
+	b := dag.NodeWithData([]byte("bbb"))
+	root := dag.NodeWithData([]byte("root"))
+	if err := root.AddNodeLinkClean("a", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.AddNodeLinkClean("b", b); err != nil {
+		t.Fatal(err)
+	}
+	// a duplicate link must only be written once
+	if err := root.AddNodeLinkClean("a2", a); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []*dag.ProtoNode{a, b, root} {
+		if _, err := ds.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteCar(context.Background(), ds, root.Cid(), buf); err != nil {
+		t.Fatal(err)
+	}
+
+	sections := readSections(t, buf.Bytes())
+	if len(sections) != 4 {
+		t.Fatalf("expected header and 3 blocks, got %d sections", len(sections))
+	}
+
+	if !bytes.Equal(sections[0], encodeHeader([]*cid.Cid{root.Cid()})) {
+		t.Fatal("unexpected header")
+	}
+
+	for i, n := range []*dag.ProtoNode{root, a, b} {
+		exp := append(n.Cid().Bytes(), n.RawData()...)
+		if !bytes.Equal(sections[i+1], exp) {
+			t.Fatalf("section %d does not match block %s", i+1, n.Cid())
+		}
+	}
+}
+
+func TestHeaderEncoding(t *testing.T) {
+	out := encodeHeader(nil)
+	exp := []byte{0xa2, 0x65, 'r', 'o', 'o', 't', 's', 0x80, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x01}
+	if !bytes.Equal(out, exp) {
+		t.Fatalf("expected %x, got %x", exp, out)
+	}
+}
+
+func TestRoundtrip(t *testing.T) {
+	ds := dstest.Mock()
+
+	leaf := dag.NewRawNode([]byte("raw leaf"))
+	root := dag.NodeWithData([]byte("root"))
+	if err := root.AddNodeLinkClean("leaf", leaf); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []node.Node{leaf, root} {
+		if _, err := ds.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteCar(context.Background(), ds, root.Cid(), buf); err != nil {
+		t.Fatal(err)
+	}
+
+	ds2 := dstest.Mock()
+	roots, err := LoadCar(ds2, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || !roots[0].Equals(root.Cid()) {
+		t.Fatalf("unexpected roots: %v", roots)
+	}
+
+	for _, n := range []node.Node{leaf, root} {
+		out, err := ds2.Get(context.Background(), n.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.RawData(), n.RawData()) {
+			t.Fatalf("block %s changed", n.Cid())
+		}
+	}
+}
+
+func TestReadCorrupted(t *testing.T) {
+	ds := dstest.Mock()
+	nd := dag.NodeWithData([]byte("some data"))
+	if _, err := ds.Add(nd); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteCar(context.Background(), ds, nd.Cid(), buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// a truncated stream
+	if _, err := LoadCar(dstest.Mock(), bytes.NewReader(data[:len(data)-3])); err != ErrMalformed {
+		t.Fatalf("expected ErrMalformed, got %v", err)
+	}
+
+	// a block whose data does not match its cid
+	bad := append([]byte(nil), data...)
+	bad[len(bad)-1] ^= 0xff
+	cr, err := NewCarReader(bytes.NewReader(bad))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cr.Next(); err == nil {
+		t.Fatal("expected an error reading a corrupted block")
+	}
+
+	// a stream without a header
+	if _, err := NewCarReader(bytes.NewReader(nil)); err != ErrMalformed {
+		t.Fatalf("expected ErrMalformed, got %v", err)
+	}
+}
+
+func TestHeaderRoundtrip(t *testing.T) {
+	a := dag.NodeWithData([]byte("a")).Cid()
+	b := dag.NewRawNode([]byte("b")).Cid()
+
+	roots, err := decodeHeader(encodeHeader([]*cid.Cid{a, b}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 || !roots[0].Equals(a) || !roots[1].Equals(b) {
+		t.Fatalf("unexpected roots: %v", roots)
+	}
+}
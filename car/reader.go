@@ -0,0 +1,302 @@
+package car
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// maxSectionSize bounds the size of a single section, so that a corrupt
+// length prefix can not make the reader allocate arbitrary amounts of memory.
+const maxSectionSize = 8 << 20
+
+var ErrMalformed = errors.New("car: malformed archive")
+
+// CarReader reads the blocks of a CAR stream.
+type CarReader struct {
+	br *bufio.Reader
+
+	// Roots are the root CIDs named by the header of the stream.
+	Roots []*cid.Cid
+}
+
+// NewCarReader reads the header of a CAR stream from r, and returns a
+// reader for the blocks that follow it.
+func NewCarReader(r io.Reader) (*CarReader, error) {
+	br := bufio.NewReader(r)
+
+	hdr, err := readSection(br)
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrMalformed
+		}
+		return nil, err
+	}
+
+	roots, err := decodeHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CarReader{br: br, Roots: roots}, nil
+}
+
+// Next returns the next block of the stream, after checking that its data
+// matches its CID. It returns io.EOF once all blocks were read.
+func (cr *CarReader) Next() (blocks.Block, error) {
+	data, err := readSection(cr.br)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := cidLength(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := cid.Cast(data[:n])
+	if err != nil {
+		return nil, err
+	}
+	data = data[n:]
+
+	chk, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !chk.Equals(c) {
+		return nil, fmt.Errorf("car: data of block %s does not match its hash", c)
+	}
+
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// LoadCar adds every block of the CAR stream read from r to ds, and returns
+// the roots named by its header. The blocks are added in a single batch.
+func LoadCar(ds dag.DAGService, r io.Reader) ([]*cid.Cid, error) {
+	cr, err := NewCarReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := ds.Batch()
+	for {
+		b, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		nd, err := dag.DecodeBlock(b)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := batch.Add(nd); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := batch.Commit(); err != nil {
+		return nil, err
+	}
+	return cr.Roots, nil
+}
+
+// readSection reads a varint length prefixed section from br. It returns
+// io.EOF only if the stream ends before the section starts.
+func readSection(br *bufio.Reader) ([]byte, error) {
+	l, err := binary.ReadUvarint(br)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, ErrMalformed
+		}
+		return nil, err
+	}
+	if l > maxSectionSize {
+		return nil, fmt.Errorf("car: section of %d bytes is too large", l)
+	}
+
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrMalformed
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// cidLength returns the length of the binary CID at the start of b.
+func cidLength(b []byte) (int, error) {
+	// CIDv0 is a bare sha2-256 multihash
+	if len(b) >= 34 && b[0] == 0x12 && b[1] == 0x20 {
+		return 34, nil
+	}
+
+	// CIDv1 is <version><codec><hash function><digest length><digest>
+	var off int
+	for i := 0; i < 4; i++ {
+		v, n := binary.Uvarint(b[off:])
+		if n <= 0 {
+			return 0, ErrMalformed
+		}
+		off += n
+
+		if i == 3 {
+			if uint64(len(b)-off) < v {
+				return 0, ErrMalformed
+			}
+			off += int(v)
+		}
+	}
+	return off, nil
+}
+
+// decodeHeader decodes the DAG-CBOR header written by encodeHeader, and
+// returns the roots it names.
+func decodeHeader(b []byte) ([]*cid.Cid, error) {
+	r := &cborReader{b: b}
+
+	entries, err := r.expect(5)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []*cid.Cid
+	var version uint64
+	for i := uint64(0); i < entries; i++ {
+		key, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "roots":
+			roots, err = r.readCids()
+			if err != nil {
+				return nil, err
+			}
+		case "version":
+			version, err = r.expect(0)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("car: unexpected header field %q", key)
+		}
+	}
+
+	if version != Version {
+		return nil, fmt.Errorf("car: unsupported version %d", version)
+	}
+	if len(r.b) != 0 {
+		return nil, ErrMalformed
+	}
+	return roots, nil
+}
+
+// cborReader decodes the small subset of CBOR used by CAR headers.
+type cborReader struct {
+	b []byte
+}
+
+// head reads the head of a CBOR item, made of its major type and its
+// argument.
+func (r *cborReader) head() (byte, uint64, error) {
+	if len(r.b) == 0 {
+		return 0, 0, ErrMalformed
+	}
+	major, info := r.b[0]>>5, r.b[0]&0x1f
+	r.b = r.b[1:]
+
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+	if info > 27 {
+		return 0, 0, ErrMalformed
+	}
+
+	n := 1 << (info - 24)
+	if len(r.b) < n {
+		return 0, 0, ErrMalformed
+	}
+	var v uint64
+	for _, c := range r.b[:n] {
+		v = v<<8 | uint64(c)
+	}
+	r.b = r.b[n:]
+	return major, v, nil
+}
+
+// expect reads the head of an item of the given major type.
+func (r *cborReader) expect(major byte) (uint64, error) {
+	m, v, err := r.head()
+	if err != nil {
+		return 0, err
+	}
+	if m != major {
+		return 0, ErrMalformed
+	}
+	return v, nil
+}
+
+func (r *cborReader) readBytes(major byte) ([]byte, error) {
+	l, err := r.expect(major)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(r.b)) < l {
+		return nil, ErrMalformed
+	}
+	out := r.b[:l]
+	r.b = r.b[l:]
+	return out, nil
+}
+
+func (r *cborReader) readString() (string, error) {
+	b, err := r.readBytes(3)
+	return string(b), err
+}
+
+func (r *cborReader) readCids() ([]*cid.Cid, error) {
+	n, err := r.expect(4)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*cid.Cid
+	for i := uint64(0); i < n; i++ {
+		tag, err := r.expect(6)
+		if err != nil {
+			return nil, err
+		}
+		if tag != 42 {
+			return nil, ErrMalformed
+		}
+
+		b, err := r.readBytes(2)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == 0 || b[0] != 0 {
+			return nil, ErrMalformed
+		}
+
+		c, err := cid.Cast(b[1:])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
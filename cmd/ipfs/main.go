@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"net"
 	"net/url"
@@ -22,6 +23,7 @@ import (
 	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
 	core "github.com/ipfs/go-ipfs/core"
 	coreCmds "github.com/ipfs/go-ipfs/core/commands"
+	_ "github.com/ipfs/go-ipfs/plugin/git" // registers the git codec
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
@@ -635,16 +637,36 @@ func getApiClient(repoPath, apiAddrStr string) (cmdsHttp.Client, error) {
 	if len(addr.Protocols()) == 0 {
 		return nil, fmt.Errorf(apiErrorFmt, repoPath, "multiaddr doesn't provide any protocols")
 	}
-	return apiClientForAddr(addr)
+
+	token := os.Getenv(cmdsHttp.TokenEnvVar)
+	if token == "" && len(apiAddrStr) == 0 {
+		token = localAPIToken(repoPath)
+	}
+	return apiClientForAddr(addr, token)
+}
+
+// localAPIToken returns the secret of a token allowing all commands kept
+// in the repo at repoPath, so that the local command line keeps working
+// once API tokens are enabled. It returns an empty string if there is none.
+func localAPIToken(repoPath string) string {
+	fname, err := config.APITokenFilename(repoPath)
+	if err != nil {
+		return ""
+	}
+	secret, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(secret))
 }
 
-func apiClientForAddr(addr ma.Multiaddr) (cmdsHttp.Client, error) {
+func apiClientForAddr(addr ma.Multiaddr, token string) (cmdsHttp.Client, error) {
 	_, host, err := manet.DialArgs(addr)
 	if err != nil {
 		return nil, err
 	}
 
-	return cmdsHttp.NewClient(host), nil
+	return cmdsHttp.NewClientWithToken(host, token), nil
 }
 
 func isConnRefused(err error) bool {
@@ -0,0 +1,45 @@
+package files
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Multipart headers carrying the permission bits, in octal, and the
+// modification time, in seconds since the epoch, of a file part.
+const (
+	ModeHeader  = "Ipfs-Mode"
+	MtimeHeader = "Ipfs-Mtime"
+)
+
+// ModeAndTime returns the permission bits and modification time of the file
+// or directory f was read from, when known.
+func ModeAndTime(f File) (os.FileMode, time.Time, bool) {
+	switch f := f.(type) {
+	case *MultipartFile:
+		if f.Part == nil {
+			return 0, time.Time{}, false
+		}
+
+		mode, err := strconv.ParseUint(f.Part.Header.Get(ModeHeader), 8, 32)
+		if err != nil {
+			return 0, time.Time{}, false
+		}
+
+		mtime, err := strconv.ParseInt(f.Part.Header.Get(MtimeHeader), 10, 64)
+		if err != nil {
+			return 0, time.Time{}, false
+		}
+
+		return os.FileMode(mode).Perm(), time.Unix(mtime, 0), true
+	case StatFile:
+		stat := f.Stat()
+		if stat == nil {
+			return 0, time.Time{}, false
+		}
+		return stat.Mode().Perm(), stat.ModTime(), true
+	default:
+		return 0, time.Time{}, false
+	}
+}
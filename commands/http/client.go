@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
@@ -21,6 +22,10 @@ import (
 const (
 	ApiUrlFormat = "http://%s%s/%s?%s"
 	ApiPath      = "/api/v0" // TODO: make configurable
+
+	// TokenEnvVar is the environment variable holding the API token
+	// clients created with NewClient authenticate with.
+	TokenEnvVar = "IPFS_API_TOKEN"
 )
 
 var OptionSkipMap = map[string]bool{
@@ -35,12 +40,22 @@ type Client interface {
 type client struct {
 	serverAddress string
 	httpClient    *http.Client
+	token         string
 }
 
+// NewClient returns a client for the API at address, authenticating with
+// the token in $IPFS_API_TOKEN if there is one.
 func NewClient(address string) Client {
+	return NewClientWithToken(address, os.Getenv(TokenEnvVar))
+}
+
+// NewClientWithToken returns a client for the API at address that sends
+// token with every request. An empty token sends none.
+func NewClientWithToken(address, token string) Client {
 	return &client{
 		serverAddress: address,
 		httpClient:    http.DefaultClient,
+		token:         token,
 	}
 }
 
@@ -93,6 +108,9 @@ func (c *client) Send(req cmds.Request) (cmds.Response, error) {
 		httpReq.Header.Set(contentTypeHeader, applicationOctetStream)
 	}
 	httpReq.Header.Set(uaHeader, config.ApiVersion)
+	if c.token != "" {
+		httpReq.Header.Set(authorizationHeader, bearerPrefix+c.token)
+	}
 
 	httpReq.Cancel = req.Context().Done()
 	httpReq.Close = true
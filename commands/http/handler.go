@@ -1,6 +1,7 @@
 package http
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"io"
@@ -38,6 +39,8 @@ type Handler struct {
 var (
 	ErrNotFound           = errors.New("404 page not found")
 	errApiVersionMismatch = errors.New("api version mismatch")
+	errMissingToken       = errors.New("401 - API token required")
+	errInvalidToken       = errors.New("401 - invalid API token")
 )
 
 const (
@@ -53,6 +56,8 @@ const (
 	applicationOctetStream   = "application/octet-stream"
 	plainText                = "text/plain"
 	originHeader             = "origin"
+	authorizationHeader      = "Authorization"
+	bearerPrefix             = "Bearer "
 )
 
 var AllowedExposedHeadersArr = []string{streamHeader, channelHeader, extraContentLengthHeader}
@@ -80,6 +85,10 @@ type ServerConfig struct {
 
 	// cORSOptsRWMutex is a RWMutex for read/write CORSOpts
 	cORSOptsRWMutex sync.RWMutex
+
+	// APITokens returns the tokens requests have to authenticate with.
+	// Authentication is disabled when it is nil or returns no tokens.
+	APITokens func() ([]config.APIToken, error)
 }
 
 func skipAPIHeader(h string) bool {
@@ -170,6 +179,15 @@ func (i internalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if code, err := authorize(r, i.cfg, req.Path(), req.Arguments()); err != nil {
+		if code == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, err.Error(), code)
+		log.Warningf("API blocked request to %s: %s", r.URL.Path, err)
+		return
+	}
+
 	rlog := i.ctx.ReqLog.Add(req)
 	defer rlog.Finish()
 
@@ -448,6 +466,88 @@ func allowReferer(r *http.Request, cfg *ServerConfig) bool {
 	return false
 }
 
+// authorize checks the bearer token of a request against the configured
+// API tokens, and that the token allows calling the command at path with
+// args. It returns the HTTP status to fail the request with.
+func authorize(r *http.Request, cfg *ServerConfig, path, args []string) (int, error) {
+	if cfg.APITokens == nil {
+		return 0, nil
+	}
+
+	tokens, err := cfg.APITokens()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	auth := r.Header.Get(authorizationHeader)
+	if !strings.HasPrefix(auth, bearerPrefix) {
+		return http.StatusUnauthorized, errMissingToken
+	}
+	hash := []byte(config.HashAPISecret(strings.TrimPrefix(auth, bearerPrefix)))
+
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(t.SecretHash)) != 1 {
+			continue
+		}
+		if !commandAllowed(t.Commands, path) {
+			return http.StatusForbidden, fmt.Errorf("token %q may not call %q", t.Name, strings.Join(path, "/"))
+		}
+		if !t.IsAdmin() && changesAPIConfig(path, args) {
+			// or a token could grant itself more commands
+			return http.StatusForbidden, fmt.Errorf("token %q may not change the API config", t.Name)
+		}
+		return 0, nil
+	}
+	return http.StatusUnauthorized, errInvalidToken
+}
+
+// changesAPIConfig reports whether calling the command at path with args
+// may change the API section of the config, tokens included.
+func changesAPIConfig(path, args []string) bool {
+	if len(path) == 0 || path[0] != "config" {
+		return false
+	}
+	if len(path) > 1 {
+		switch path[1] {
+		case "replace", "edit":
+			return true
+		case "api-token":
+			return len(path) < 3 || path[2] != "ls"
+		}
+		return false
+	}
+
+	if len(args) < 2 {
+		// reading a value
+		return false
+	}
+	key := strings.ToLower(args[0])
+	api := strings.ToLower(config.APITag)
+	return key == api || strings.HasPrefix(key, api+".")
+}
+
+// commandAllowed reports whether any of the patterns matches the command
+// path. A pattern is a command path, optionally ending in "/*" to match
+// the command and all of its subcommands; "*" matches everything.
+func commandAllowed(patterns []string, path []string) bool {
+	cmd := strings.Join(path, "/")
+	for _, p := range patterns {
+		switch {
+		case p == "*", p == cmd:
+			return true
+		case strings.HasSuffix(p, "/*"):
+			base := strings.TrimSuffix(p, "/*")
+			if cmd == base || strings.HasPrefix(cmd, base+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // apiVersionMatches checks whether the api client is running the
 // same version of go-ipfs. for now, only the exact same version of
 // client + server work. In the future, we should use semver for
@@ -9,6 +9,7 @@ import (
 	cmds "github.com/ipfs/go-ipfs/commands"
 	ipfscmd "github.com/ipfs/go-ipfs/core/commands"
 	coremock "github.com/ipfs/go-ipfs/core/mock"
+	config "github.com/ipfs/go-ipfs/repo/config"
 )
 
 func assertHeaders(t *testing.T, resHeaders http.Header, reqHeaders map[string]string) {
@@ -41,6 +42,7 @@ type testCase struct {
 	AllowOrigins []string
 	ReqHeaders   map[string]string
 	ResHeaders   map[string]string
+	Tokens       []config.APIToken
 }
 
 var defaultOrigins = []string{
@@ -50,7 +52,7 @@ var defaultOrigins = []string{
 	"https://127.0.0.1",
 }
 
-func getTestServer(t *testing.T, origins []string) *httptest.Server {
+func getTestServer(t *testing.T, origins []string, tokens []config.APIToken) *httptest.Server {
 	cmdsCtx, err := coremock.MockCmdsCtx()
 	if err != nil {
 		t.Error("failure to initialize mock cmds ctx", err)
@@ -67,7 +69,14 @@ func getTestServer(t *testing.T, origins []string) *httptest.Server {
 		origins = defaultOrigins
 	}
 
-	handler := NewHandler(cmdsCtx, cmdRoot, originCfg(origins))
+	cfg := originCfg(origins)
+	if tokens != nil {
+		cfg.APITokens = func() ([]config.APIToken, error) {
+			return tokens, nil
+		}
+	}
+
+	handler := NewHandler(cmdsCtx, cmdRoot, cfg)
 	return httptest.NewServer(handler)
 }
 
@@ -106,7 +115,7 @@ func (tc *testCase) test(t *testing.T) {
 	}
 
 	// server
-	server := getTestServer(t, tc.AllowOrigins)
+	server := getTestServer(t, tc.AllowOrigins, tc.Tokens)
 	if server == nil {
 		return
 	}
@@ -346,3 +355,88 @@ func TestAllowedMethod(t *testing.T) {
 		tc.test(t)
 	}
 }
+
+func TestAPITokens(t *testing.T) {
+	tokens := []config.APIToken{
+		{Name: "admin", SecretHash: config.HashAPISecret("s3cret"), Commands: []string{"*"}},
+		{Name: "reader", SecretHash: config.HashAPISecret("r3ader"), Commands: []string{"cat", "pin/*"}},
+	}
+
+	gtc := func(auth string, code int) testCase {
+		tc := testCase{
+			Tokens: tokens,
+			Code:   code,
+		}
+		if auth != "" {
+			tc.ReqHeaders = map[string]string{"Authorization": auth}
+		}
+		return tc
+	}
+
+	tcs := []testCase{
+		gtc("", http.StatusUnauthorized),
+		gtc("Bearer wrong", http.StatusUnauthorized),
+		gtc("Basic s3cret", http.StatusUnauthorized),
+		gtc("Bearer s3cret", http.StatusOK),
+		gtc("Bearer "+config.HashAPISecret("s3cret"), http.StatusUnauthorized),
+		gtc("Bearer r3ader", http.StatusForbidden),
+	}
+
+	for _, tc := range tcs {
+		tc.test(t)
+	}
+
+	// an empty token list leaves the API open
+	tc := gtc("", http.StatusOK)
+	tc.Tokens = []config.APIToken{}
+	tc.test(t)
+}
+
+func TestCommandAllowed(t *testing.T) {
+	patterns := []string{"cat", "pin/*", "files/ls"}
+	for _, tc := range []struct {
+		path    []string
+		allowed bool
+	}{
+		{[]string{"cat"}, true},
+		{[]string{"pin"}, true},
+		{[]string{"pin", "add"}, true},
+		{[]string{"pinx"}, false},
+		{[]string{"files", "ls"}, true},
+		{[]string{"files", "rm"}, false},
+		{[]string{"config"}, false},
+	} {
+		if commandAllowed(patterns, tc.path) != tc.allowed {
+			t.Errorf("expected commandAllowed(%v) to be %v", tc.path, tc.allowed)
+		}
+	}
+
+	if !commandAllowed([]string{"*"}, []string{"shutdown"}) {
+		t.Error("expected * to allow every command")
+	}
+}
+
+func TestChangesAPIConfig(t *testing.T) {
+	for _, tc := range []struct {
+		path    []string
+		args    []string
+		changes bool
+	}{
+		{[]string{"config"}, []string{"API.Tokens"}, false},
+		{[]string{"config"}, []string{"API.Tokens", "[]"}, true},
+		{[]string{"config"}, []string{"api.HTTPHeaders", "{}"}, true},
+		{[]string{"config"}, []string{"API", "{}"}, true},
+		{[]string{"config"}, []string{"APIx", "{}"}, false},
+		{[]string{"config"}, []string{"Datastore.Path", "foo"}, false},
+		{[]string{"config", "replace"}, nil, true},
+		{[]string{"config", "api-token", "add"}, []string{"t", "*"}, true},
+		{[]string{"config", "api-token", "rm"}, []string{"t"}, true},
+		{[]string{"config", "api-token", "ls"}, nil, false},
+		{[]string{"config", "show"}, nil, false},
+		{[]string{"cat"}, []string{"API", "x"}, false},
+	} {
+		if changesAPIConfig(tc.path, tc.args) != tc.changes {
+			t.Errorf("expected changesAPIConfig(%v, %v) to be %v", tc.path, tc.args, tc.changes)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"mime/multipart"
 	"net/textproto"
 	"net/url"
+	"strconv"
 	"sync"
 
 	files "github.com/ipfs/go-ipfs/commands/files"
@@ -96,6 +97,11 @@ func (mfr *MultiFileReader) Read(buf []byte) (written int, err error) {
 
 			header.Set("Content-Type", contentType)
 
+			if mode, mtime, ok := files.ModeAndTime(file); ok {
+				header.Set(files.ModeHeader, strconv.FormatUint(uint64(mode), 8))
+				header.Set(files.MtimeHeader, strconv.FormatInt(mtime.Unix(), 10))
+			}
+
 			_, err := mfr.mpWriter.CreatePart(header)
 			if err != nil {
 				return 0, err
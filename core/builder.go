@@ -184,6 +184,10 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		return err
 	}
 
+	if cbs, err = n.trackBlockUse(cbs, conf); err != nil {
+		return err
+	}
+
 	n.Blockstore = bstore.NewGCBlockstore(cbs, bstore.NewGCLocker())
 
 	rcfg, err := n.Repo.Config()
@@ -216,6 +220,17 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		// this is kinda sketchy and could cause data loss
 		n.Pinning = pin.NewPinner(n.Repo.Datastore(), n.DAG, internalDag)
 	}
+
+	if rcfg.Datastore.RefCounts {
+		refs, err := pin.NewRefCounts(n.Repo.Datastore())
+		if err != nil {
+			return err
+		}
+		if !refs.Valid() {
+			log.Warning(pin.ErrRefCountsInvalid)
+		}
+		n.Pinning.SetRefCounts(refs)
+	}
 	n.Resolver = path.NewBasicResolver(n.DAG)
 
 	err = n.loadFilesRoot()
@@ -225,3 +240,19 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 
 	return nil
 }
+
+// trackBlockUse wraps bs to track the use of its blocks when the automatic
+// garbage collection evicts the least recently used ones. The uses are
+// recorded in the repo datastore, and written out at teardown.
+func (n *IpfsNode) trackBlockUse(bs bstore.Blockstore, conf *cfg.Config) (bstore.Blockstore, error) {
+	if conf.Datastore.GCMode != cfg.GCModeLRU {
+		return bs, nil
+	}
+
+	lbs, err := bstore.NewLRUBlockstore(bs, bstore.DefaultLRUSize, n.Repo.Datastore())
+	if err != nil {
+		return nil, err
+	}
+	n.BlockUse = lbs
+	return lbs, nil
+}
@@ -17,11 +17,16 @@ import (
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 	u "gx/ipfs/Qmb912gdngC1UWwTkhuW8knyRbcWeu5kqkxBpveLmW8bSr/go-ipfs-util"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
 // Error indicating the max depth has been exceded.
 var ErrDepthLimitExceeded = fmt.Errorf("depth limit exceeded")
 
+// maxInlineLimit bounds --inline-limit, as inlined data makes cids, and so
+// links, paths and wantlists, longer.
+const maxInlineLimit = 1024
+
 const (
 	quietOptionName     = "quiet"
 	silentOptionName    = "silent"
@@ -33,6 +38,12 @@ const (
 	chunkerOptionName   = "chunker"
 	pinOptionName       = "pin"
 	rawLeavesOptionName = "raw-leaves"
+	preserveModeName    = "preserve-mode"
+	preserveMtimeName   = "preserve-mtime"
+	hashOptionName      = "hash"
+	cidVersionName      = "cid-version"
+	inlineOptionName    = "inline"
+	inlineLimitName     = "inline-limit"
 )
 
 var AddCmd = &cmds.Command{
@@ -60,6 +71,26 @@ its filename. For example:
 You can now refer to the added file in a gateway, like so:
 
   /ipfs/QmaG4FuMqEBnQNn3C8XJ5bpW8kLs7zq2ZXgHptJHbKDDVx/example.jpg
+
+The '--preserve-mode' and '--preserve-mtime' options record the permission
+bits and modification times of the added files and directories, which
+'ipfs get' restores. They change the resulting hashes, so they are off by
+default.
+
+The '--hash' option selects the multihash function used to hash the nodes,
+and '--cid-version' the version of their CIDs. CIDv0 only supports sha2-256,
+so another hash function selects CIDv1 unless a version is given.
+
+The '--inline' option inlines the data of nodes smaller than '--inline-limit'
+bytes in their CIDv1 with the identity hash. Such nodes are not stored as
+blocks and never fetched from the network, which suits tiny files and empty
+directories.
+
+The '--chunker' option selects how files are split into blocks: fixed size
+blocks with 'size-<bytes>', or content-defined ones with 'rabin',
+'rabin-<min>-<avg>-<max>', 'buzhash', 'fastcdc' or
+'fastcdc-<min>-<avg>-<max>'. Content-defined chunking keeps block boundaries
+stable around insertions and deletions, so similar files share blocks.
 `,
 	},
 
@@ -75,9 +106,15 @@ You can now refer to the added file in a gateway, like so:
 		cmds.BoolOption(onlyHashOptionName, "n", "Only chunk and hash - do not write to disk."),
 		cmds.BoolOption(wrapOptionName, "w", "Wrap files with a directory object."),
 		cmds.BoolOption(hiddenOptionName, "H", "Include files that are hidden. Only takes effect on recursive add."),
-		cmds.StringOption(chunkerOptionName, "s", "Chunking algorithm to use."),
+		cmds.StringOption(chunkerOptionName, "s", "Chunking algorithm to use: size-<bytes>, rabin, buzhash or fastcdc."),
 		cmds.BoolOption(pinOptionName, "Pin this object when adding.").Default(true),
 		cmds.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes. (experimental)"),
+		cmds.BoolOption(preserveModeName, "Record the permission bits of files and directories."),
+		cmds.BoolOption(preserveMtimeName, "Record the modification times of files and directories."),
+		cmds.StringOption(hashOptionName, "Hash function to use, e.g. sha2-256 or sha2-512. Default: sha2-256."),
+		cmds.IntOption(cidVersionName, "CID version, 0 or 1. Default: 0, or 1 for hash functions other than sha2-256."),
+		cmds.BoolOption(inlineOptionName, "Inline small nodes in their CID."),
+		cmds.IntOption(inlineLimitName, "Maximum size of inlined nodes, in bytes.").Default(32),
 	},
 	PreRun: func(req cmds.Request) error {
 		quiet, _, _ := req.Option(quietOptionName).Bool()
@@ -140,6 +177,24 @@ You can now refer to the added file in a gateway, like so:
 		chunker, _, _ := req.Option(chunkerOptionName).String()
 		dopin, _, _ := req.Option(pinOptionName).Bool()
 		rawblks, _, _ := req.Option(rawLeavesOptionName).Bool()
+		preserveMode, _, _ := req.Option(preserveModeName).Bool()
+		preserveMtime, _, _ := req.Option(preserveMtimeName).Bool()
+
+		prefix, err := cidPrefixOption(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		inline, _, _ := req.Option(inlineOptionName).Bool()
+		inlineLimit, _, _ := req.Option(inlineLimitName).Int()
+		if !inline {
+			inlineLimit = 0
+		}
+		if inlineLimit < 0 || inlineLimit > maxInlineLimit {
+			res.SetError(fmt.Errorf("inline limit must be between 0 and %d", maxInlineLimit), cmds.ErrClient)
+			return
+		}
 
 		if hash {
 			nilnode, err := core.NewNode(n.Context(), &core.BuildCfg{
@@ -180,10 +235,17 @@ You can now refer to the added file in a gateway, like so:
 		fileAdder.Pin = dopin
 		fileAdder.Silent = silent
 		fileAdder.RawLeaves = rawblks
+		fileAdder.PreserveMode = preserveMode
+		fileAdder.PreserveMtime = preserveMtime
+		fileAdder.Prefix = prefix
+		fileAdder.InlineLimit = inlineLimit
 
 		if hash {
 			md := dagtest.Mock()
-			mr, err := mfs.NewRoot(req.Context(), md, ft.EmptyDirNode(), nil)
+			rnode := ft.EmptyDirNode()
+			rnode.SetPrefix(prefix)
+			rnode.SetInlineLimit(inlineLimit)
+			mr, err := mfs.NewRoot(req.Context(), md, rnode, nil)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
@@ -329,3 +391,28 @@ You can now refer to the added file in a gateway, like so:
 	},
 	Type: coreunix.AddedObject{},
 }
+
+// cidPrefixOption returns the CID prefix selected by the --hash and
+// --cid-version options, or nil when neither was given.
+func cidPrefixOption(req cmds.Request) (*cid.Prefix, error) {
+	hash, hashFound, err := req.Option(hashOptionName).String()
+	if err != nil {
+		return nil, err
+	}
+	version, versionFound, err := req.Option(cidVersionName).Int()
+	if err != nil {
+		return nil, err
+	}
+	if !hashFound && !versionFound {
+		return nil, nil
+	}
+
+	if !versionFound {
+		version = -1
+	}
+	prefix, err := dag.PrefixFor(hash, version)
+	if err != nil {
+		return nil, err
+	}
+	return &prefix, nil
+}
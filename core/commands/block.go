@@ -109,6 +109,10 @@ var blockPutCmd = &cmds.Command{
 		ShortDescription: `
 'ipfs block put' is a plumbing command for storing raw IPFS blocks.
 It reads from stdin, and <key> is a base58 encoded multihash.
+
+The '--hash' option selects the multihash function, and '--cid-version' the
+version of the CID of the block. CIDv0 only supports protobuf blocks hashed
+with sha2-256, so other hash functions select CIDv1 unless a version is given.
 `,
 	},
 
@@ -119,6 +123,8 @@ It reads from stdin, and <key> is a base58 encoded multihash.
 		cmds.StringOption("format", "f", "cid format for blocks to be created with.").Default("v0"),
 		cmds.StringOption("mhtype", "multihash hash function").Default("sha2-256"),
 		cmds.IntOption("mhlen", "multihash hash length").Default(-1),
+		cmds.StringOption("hash", "Hash function to use, overrides --mhtype."),
+		cmds.IntOption("cid-version", "CID version, 0 or 1."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -165,13 +171,47 @@ It reads from stdin, and <key> is a base58 encoded multihash.
 		}
 
 		mhtype, _, _ := req.Option("mhtype").String()
-		mhtval, ok := mh.Names[mhtype]
+		if hash, found, _ := req.Option("hash").String(); found {
+			mhtype = hash
+		}
+		mhtval, ok := mh.Names[strings.ToLower(mhtype)]
 		if !ok {
 			res.SetError(fmt.Errorf("unrecognized multihash function: %s", mhtype), cmds.ErrNormal)
 			return
 		}
+		if mhtval == mh.ID {
+			res.SetError(fmt.Errorf("the identity hash puts whole blocks in their CIDs, see 'ipfs add --inline' to inline small ones"), cmds.ErrClient)
+			return
+		}
 		pref.MhType = mhtval
 
+		version, versionFound, err := req.Option("cid-version").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		switch {
+		case !versionFound:
+			if mhtval != mh.SHA2_256 {
+				pref.Version = 1
+			}
+		case version == 1:
+			pref.Version = 1
+		case version == 0:
+			if pref.Codec != cid.DagProtobuf {
+				res.SetError(fmt.Errorf("CIDv0 only supports protobuf blocks"), cmds.ErrClient)
+				return
+			}
+			pref.Version = 0
+		default:
+			res.SetError(fmt.Errorf("unknown CID version: %d", version), cmds.ErrClient)
+			return
+		}
+		if pref.Version == 0 && mhtval != mh.SHA2_256 {
+			res.SetError(fmt.Errorf("CIDv0 only supports sha2-256, use CIDv1 for %s", mhtype), cmds.ErrClient)
+			return
+		}
+
 		mhlen, _, err := req.Option("mhlen").Int()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
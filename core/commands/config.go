@@ -2,6 +2,8 @@ package commands
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -67,6 +69,11 @@ Set the value of the 'Datastore.Path' key:
 			return
 		default:
 		}
+		if lk := strings.ToLower(key); lk == strings.ToLower(config.APITokensSelector) ||
+			strings.HasPrefix(lk, strings.ToLower(config.APITokensSelector)+".") {
+			res.SetError(fmt.Errorf("cannot show or change API tokens this way, see 'ipfs config api-token'"), cmds.ErrNormal)
+			return
+		}
 
 		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
 		if err != nil {
@@ -100,6 +107,10 @@ Set the value of the 'Datastore.Path' key:
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		if m, ok := output.Value.(map[string]interface{}); ok && strings.ToLower(key) == strings.ToLower(config.APITag) {
+			// there may be no tokens to hide
+			scrubValue(m, []string{config.TokensTag})
+		}
 		res.SetOutput(output)
 	},
 	Marshalers: cmds.MarshalerMap{
@@ -128,9 +139,10 @@ Set the value of the 'Datastore.Path' key:
 	},
 	Type: ConfigField{},
 	Subcommands: map[string]*cmds.Command{
-		"show":    configShowCmd,
-		"edit":    configEditCmd,
-		"replace": configReplaceCmd,
+		"show":      configShowCmd,
+		"edit":      configEditCmd,
+		"replace":   configReplaceCmd,
+		"api-token": configAPITokenCmd,
 	},
 }
 
@@ -168,6 +180,9 @@ included in the output of this command.
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		if api, ok := cfg[config.APITag].(map[string]interface{}); ok {
+			delete(api, config.TokensTag)
+		}
 
 		output, err := config.HumanOutput(cfg)
 		if err != nil {
@@ -329,5 +344,297 @@ func replaceConfig(r repo.Repo, file io.Reader) error {
 
 	cfg.Identity.PrivKey = pkstr
 
+	if len(cfg.API.Tokens) != 0 {
+		return errors.New("setting API tokens with config replace is not supported")
+	}
+	cur, err := r.Config()
+	if err != nil {
+		return err
+	}
+	cfg.API.Tokens = cur.API.Tokens
+
 	return r.SetConfig(&cfg)
 }
+
+var configAPITokenCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the tokens that authenticate API requests.",
+		ShortDescription: `
+Once any token exists, every request to the API has to carry one of them as
+'Authorization: Bearer <secret>', and may only call the commands the token is
+scoped to. Removing the last token turns authentication off again.
+
+Only a hash of each secret is kept in the config. The ipfs command line sends
+the token in $IPFS_API_TOKEN, or else the secret of the last token allowing
+all commands ('*') created in the local repo, which is kept in its api_token
+file. When the first token added is scoped to some commands, a token named
+'local' allowing all commands is created along with it for that purpose, and
+the last token allowing all commands cannot be removed while others remain.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": configAPITokenAddCmd,
+		"rm":  configAPITokenRmCmd,
+		"ls":  configAPITokenLsCmd,
+	},
+}
+
+type APITokenOutput struct {
+	Name     string
+	Secret   string `json:",omitempty"`
+	Commands []string
+}
+
+type APITokenList struct {
+	Tokens []APITokenOutput
+}
+
+var configAPITokenAddCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Create an API token and print its secret.",
+		ShortDescription: `
+Creates a token named <name> allowed to call the given commands. Commands are
+written as paths, e.g. 'cat', 'pin/add' or 'pin/*' for 'pin' and all of its
+subcommands. '*' allows every command.
+
+The secret is only shown once.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the token to create."),
+		cmds.StringArg("commands", true, true, "Command paths the token may call."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		name := req.Arguments()[0]
+		commands := req.Arguments()[1:]
+
+		secret, err := newAPISecret()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		token := config.APIToken{
+			Name:       name,
+			SecretHash: config.HashAPISecret(secret),
+			Commands:   commands,
+		}
+
+		// the secret the local command line authenticates with, if any
+		localSecret := ""
+		if token.IsAdmin() {
+			localSecret = secret
+		}
+
+		err = updateAPITokens(req, func(tokens []config.APIToken) ([]config.APIToken, error) {
+			for _, t := range tokens {
+				if t.Name == name {
+					return nil, fmt.Errorf("API token %q already exists", name)
+				}
+			}
+
+			if token.IsAdmin() || hasAdminAPIToken(tokens) {
+				return append(tokens, token), nil
+			}
+
+			// without a token allowing all commands, the local command
+			// line would not be able to call most of them, and to remove
+			// this one
+			if name == config.LocalAPITokenName {
+				return nil, fmt.Errorf("the first API token named %q must allow all commands ('*')", name)
+			}
+			var err error
+			localSecret, err = newAPISecret()
+			if err != nil {
+				return nil, err
+			}
+			local := config.APIToken{
+				Name:       config.LocalAPITokenName,
+				SecretHash: config.HashAPISecret(localSecret),
+				Commands:   []string{"*"},
+			}
+			return append(tokens, local, token), nil
+		})
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if localSecret != "" {
+			if err := writeLocalAPIToken(req, localSecret); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		res.SetOutput(&APITokenOutput{
+			Name:     token.Name,
+			Secret:   secret,
+			Commands: token.Commands,
+		})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			t, ok := res.Output().(*APITokenOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			return strings.NewReader(t.Secret + "\n"), nil
+		},
+	},
+	Type: APITokenOutput{},
+}
+
+var configAPITokenRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove an API token.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the token to remove."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		name := req.Arguments()[0]
+
+		var removed config.APIToken
+		err := updateAPITokens(req, func(tokens []config.APIToken) ([]config.APIToken, error) {
+			var out []config.APIToken
+			for _, t := range tokens {
+				if t.Name != name {
+					out = append(out, t)
+				} else {
+					removed = t
+				}
+			}
+			if len(out) == len(tokens) {
+				return nil, fmt.Errorf("no API token named %q", name)
+			}
+			if len(out) > 0 && !hasAdminAPIToken(out) {
+				return nil, fmt.Errorf("cannot remove the last API token allowing all commands while others remain")
+			}
+			return out, nil
+		})
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := removeLocalAPIToken(req, removed.SecretHash); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+var configAPITokenLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List API tokens and the commands they allow.",
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer r.Close()
+
+		cfg, err := r.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &APITokenList{Tokens: []APITokenOutput{}}
+		for _, t := range cfg.API.Tokens {
+			// secrets are only shown when a token is created
+			out.Tokens = append(out.Tokens, APITokenOutput{Name: t.Name, Commands: t.Commands})
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list, ok := res.Output().(*APITokenList)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			for _, t := range list.Tokens {
+				fmt.Fprintf(buf, "%s\t%s\n", t.Name, strings.Join(t.Commands, " "))
+			}
+			return buf, nil
+		},
+	},
+	Type: APITokenList{},
+}
+
+// updateAPITokens replaces the API tokens in the config with the result of
+// applying f to them.
+func updateAPITokens(req cmds.Request, f func([]config.APIToken) ([]config.APIToken, error)) error {
+	r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	tokens, err := f(append([]config.APIToken(nil), cfg.API.Tokens...))
+	if err != nil {
+		return err
+	}
+
+	updated := *cfg
+	updated.API.Tokens = tokens
+	return r.SetConfig(&updated)
+}
+
+// newAPISecret returns a new random API token secret.
+func newAPISecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hasAdminAPIToken returns whether one of tokens allows all commands.
+func hasAdminAPIToken(tokens []config.APIToken) bool {
+	for _, t := range tokens {
+		if t.IsAdmin() {
+			return true
+		}
+	}
+	return false
+}
+
+// writeLocalAPIToken keeps secret in the repo, for the local command line
+// to authenticate with.
+func writeLocalAPIToken(req cmds.Request, secret string) error {
+	fname, err := config.APITokenFilename(req.InvocContext().ConfigRoot)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fname, []byte(secret), 0600)
+}
+
+// removeLocalAPIToken removes the secret kept in the repo if it is the one
+// of the token with the given hash.
+func removeLocalAPIToken(req cmds.Request, hash string) error {
+	fname, err := config.APITokenFilename(req.InvocContext().ConfigRoot)
+	if err != nil {
+		return err
+	}
+	secret, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if config.HashAPISecret(strings.TrimSpace(string(secret))) != hash {
+		return nil
+	}
+	return os.Remove(fname)
+}
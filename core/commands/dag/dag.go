@@ -1,15 +1,20 @@
 package dagcmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	coredag "github.com/ipfs/go-ipfs/core/coredag"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 
 	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
-	ipldcbor "gx/ipfs/QmbuuwTd9x4NReZ7sxtiKk7wFcfDUo54MfWBdtF5MRCPGR/go-ipld-cbor"
 	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
@@ -24,29 +29,53 @@ to deprecate and replace the existing 'ipfs object' command moving forward.
 		`,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"put": DagPutCmd,
-		"get": DagGetCmd,
+		"put":     DagPutCmd,
+		"get":     DagGetCmd,
+		"resolve": DagResolveCmd,
+		"stat":    DagStatCmd,
+		"export":  DagExportCmd,
+		"import":  DagImportCmd,
 	},
 }
 
 type OutputObject struct {
 	Cid *cid.Cid
+	Err string
 }
 
 var DagPutCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
-		Tagline: "Add a dag node to ipfs.",
+		Tagline: "Add dag nodes to ipfs.",
 		ShortDescription: `
-'ipfs dag put' accepts input from a file or stdin and parses it
-into an object of the specified format.
+'ipfs dag put' accepts input from files or stdin and parses it
+into objects of the specified format, printing the cid of each.
+`,
+		LongDescription: `
+'ipfs dag put' accepts input from files or stdin and parses it
+into objects of the specified format, printing the cid of each.
+
+The input encodings are:
+
+  json      - objects in JSON, to be added as cbor
+  cbor      - objects already encoded in cbor
+  protobuf  - an object already encoded as a dag-pb protobuf
+  raw       - a single object, taken as is as a block of the format:
+              raw, cbor or protobuf
+
+A json input may hold several objects, one per line, and a cbor input
+several concatenated items. Every object makes a node of its own, and one
+cid is printed per object, in the order they were read. The nodes are
+written in batches, and their cids printed as each batch is written.
 `,
 	},
 	Arguments: []cmds.Argument{
-		cmds.FileArg("object data", true, false, "The object to put").EnableStdin(),
+		cmds.FileArg("object data", true, true, "The object to put").EnableStdin(),
 	},
 	Options: []cmds.Option{
 		cmds.StringOption("format", "f", "Format that the object will be added as.").Default("cbor"),
 		cmds.StringOption("input-enc", "Format that the input object will be.").Default("json"),
+		cmds.BoolOption("pin", "Pin the objects recursively when adding.").Default(false),
+		cmds.StringOption("hash", "Hash function to use instead of the default one of the format."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -55,49 +84,154 @@ into an object of the specified format.
 			return
 		}
 
-		fi, err := req.Files().NextFile()
-		if err != nil {
-			res.SetError(err, cmds.ErrNormal)
-			return
-		}
-
 		ienc, _, _ := req.Option("input-enc").String()
 		format, _, _ := req.Option("format").String()
+		dopin, _, _ := req.Option("pin").Bool()
+		hash, _, _ := req.Option("hash").String()
 
-		switch ienc {
-		case "json":
-			nd, err := convertJsonToType(fi, format)
+		mhType := -1
+		if hash != "" {
+			// rehashed nodes are CIDv1
+			prefix, err := dag.PrefixFor(hash, 1)
 			if err != nil {
-				res.SetError(err, cmds.ErrNormal)
+				res.SetError(err, cmds.ErrClient)
 				return
 			}
+			mhType = prefix.MhType
+		}
 
-			c, err := n.DAG.Add(nd)
-			if err != nil {
-				res.SetError(err, cmds.ErrNormal)
-				return
-			}
+		out := make(chan interface{}, 8)
+		res.SetOutput((<-chan interface{})(out))
 
-			res.SetOutput(&OutputObject{Cid: c})
-			return
-		default:
-			res.SetError(fmt.Errorf("unrecognized input encoding: %s", ienc), cmds.ErrNormal)
-			return
-		}
+		go func() {
+			defer close(out)
+
+			if err := dagPut(req, n, ienc, format, mhType, dopin, out); err != nil {
+				out <- &OutputObject{Err: err.Error()}
+			}
+		}()
 	},
 	Type: OutputObject{},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-			oobj, ok := res.Output().(*OutputObject)
+			outChan, ok := res.Output().(<-chan interface{})
 			if !ok {
 				return nil, fmt.Errorf("expected a different object in marshaler")
 			}
 
-			return strings.NewReader(oobj.Cid.String()), nil
+			marshal := func(v interface{}) (io.Reader, error) {
+				oobj, ok := v.(*OutputObject)
+				if !ok {
+					return nil, fmt.Errorf("expected a different object in marshaler")
+				}
+				if oobj.Err != "" {
+					return nil, errors.New(oobj.Err)
+				}
+
+				return strings.NewReader(oobj.Cid.String() + "\n"), nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
 		},
 	},
 }
 
+// dagPutBatchObjects and dagPutBatchBytes bound the number and the size of
+// the objects read before they are written, and their cids sent.
+const (
+	dagPutBatchObjects = 128
+	dagPutBatchBytes   = 1 << 20
+)
+
+// dagPut adds every object of the request files in batches, sending the
+// cids of each batch to out once it is committed, and pins its nodes if
+// asked to. A mhType of -1 keeps the hash function of the format.
+func dagPut(req cmds.Request, n *core.IpfsNode, ienc, format string, mhType int, dopin bool, out chan<- interface{}) error {
+	if dopin {
+		defer n.Blockstore.PinLock().Unlock()
+	}
+
+	batch := n.DAG.Batch()
+
+	// the nodes added to the batch since it was last committed
+	var pending []node.Node
+	size := 0
+
+	commit := func() error {
+		if err := batch.Commit(); err != nil {
+			return err
+		}
+
+		for _, nd := range pending {
+			select {
+			case out <- &OutputObject{Cid: nd.Cid()}:
+			case <-req.Context().Done():
+				return req.Context().Err()
+			}
+
+			if dopin {
+				if err := n.Pinning.Pin(req.Context(), nd, true); err != nil {
+					return err
+				}
+			}
+		}
+
+		pending = pending[:0]
+		size = 0
+		return nil
+	}
+
+	addNode := func(nd node.Node) error {
+		if mhType != -1 {
+			var err error
+			nd, err = coredag.Rehash(nd, mhType)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := batch.Add(nd); err != nil {
+			return err
+		}
+		pending = append(pending, nd)
+		size += len(nd.RawData())
+
+		if len(pending) >= dagPutBatchObjects || size >= dagPutBatchBytes {
+			return commit()
+		}
+		return nil
+	}
+
+	for {
+		fi, err := req.Files().NextFile()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		err = coredag.ParseInputs(ienc, format, fi, addNode)
+		fi.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := commit(); err != nil {
+		return err
+	}
+
+	if !dopin {
+		return nil
+	}
+	return n.Pinning.Flush()
+}
+
 var DagGetCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Get a dag node from ipfs.",
@@ -131,13 +265,278 @@ var DagGetCmd = &cmds.Command{
 	},
 }
 
-func convertJsonToType(r io.Reader, format string) (node.Node, error) {
-	switch format {
-	case "cbor", "dag-cbor":
-		return ipldcbor.FromJson(r)
-	case "dag-pb", "protobuf":
-		return nil, fmt.Errorf("protobuf handling in 'dag' command not yet implemented")
-	default:
-		return nil, fmt.Errorf("unknown target format: %s", format)
+// ResolveOutput is the output of 'ipfs dag resolve'
+type ResolveOutput struct {
+	Cid     *cid.Cid
+	RemPath string
+}
+
+var DagResolveCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Resolve an ipld path to a cid.",
+		ShortDescription: `
+'ipfs dag resolve' walks the given path through nodes of any format, and
+prints the cid of the last node it reaches, followed by the part of the
+path naming a value inside that node, if any.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ref", true, false, "The path to resolve").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		c, rest, err := n.Resolver.ResolveToLastNode(req.Context(), p)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&ResolveOutput{
+			Cid:     c,
+			RemPath: path.Join(rest),
+		})
+	},
+	Type: ResolveOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*ResolveOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected a different object in marshaler")
+			}
+
+			p := out.Cid.String()
+			if out.RemPath != "" {
+				p += "/" + out.RemPath
+			}
+			return strings.NewReader(p + "\n"), nil
+		},
+	},
+}
+
+// DagStat is the output of 'ipfs dag stat'
+type DagStat struct {
+	Cid       *cid.Cid
+	NumBlocks int
+	Size      uint64
+}
+
+var DagStatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Print statistics about a dag.",
+		ShortDescription: `
+'ipfs dag stat' walks the dag under the given root, and prints the number
+of distinct blocks it is made of and their total size. Blocks linked more
+than once are only counted once.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "The root of the dag").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		obj, err := n.Resolver.ResolvePath(req.Context(), p)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		set := cid.NewSet()
+		set.Add(obj.Cid())
+		err = dag.EnumerateChildrenAsync(req.Context(), n.DAG, obj.Cid(), set.Visit)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		// the walk fetched every block, so getting them again is cheap
+		stat := &DagStat{Cid: obj.Cid(), NumBlocks: set.Len()}
+		for _, c := range set.Keys() {
+			b, err := n.Blocks.GetBlock(req.Context(), c)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			stat.Size += uint64(len(b.RawData()))
+		}
+
+		res.SetOutput(stat)
+	},
+	Type: DagStat{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			stat, ok := res.Output().(*DagStat)
+			if !ok {
+				return nil, fmt.Errorf("expected a different object in marshaler")
+			}
+
+			return strings.NewReader(fmt.Sprintf("Size: %d, NumBlocks: %d\n", stat.Size, stat.NumBlocks)), nil
+		},
+	},
+}
+
+var DagExportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Stream a dag as a CAR archive.",
+		ShortDescription: `
+'ipfs dag export' writes the dag under the given root to stdout as a CAR
+(content addressable archive) file: a header naming the root, followed by
+every block of the dag, each written once in depth first order.
+The output for a given dag is always the same.
+
+The --max-depth, --path-glob and --link-glob options export a part of the
+dag only. Path patterns are made of link names separated by slashes, and
+use the syntax of shell globs for each name: '*/meta*' selects the links
+whose name starts with 'meta' in the children of the root, and everything
+under them, along with the blocks leading to them.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "The root of the dag to export.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("max-depth", "Only export the blocks down to this depth below the root.").Default(-1),
+		cmds.StringOption("path-glob", "Only export the blocks along the paths matching this glob pattern, and under them."),
+		cmds.StringOption("link-glob", "Only follow named links matching this glob pattern."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		obj, err := n.Resolver.ResolvePath(req.Context(), p)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		sel, err := SelectorOption(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(coreunix.ExportCar(req.Context(), n, obj.Cid(), sel, pw))
+		}()
+
+		res.SetOutput(pr)
+	},
+}
+
+type ImportOutput struct {
+	Roots []*cid.Cid
+}
+
+var DagImportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Import the contents of a CAR archive.",
+		ShortDescription: `
+'ipfs dag import' reads a CAR (content addressable archive) file, as
+written by 'ipfs dag export', checks the hash of every block and adds them
+all to the local repo. The roots named by the archive are printed and,
+unless --pin-roots=false is given, pinned recursively.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("file", true, false, "The CAR file to import.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("pin-roots", "Pin the roots of the archive recursively.").Default(true),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fi, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer fi.Close()
+
+		pin, _, err := req.Option("pin-roots").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		roots, err := coreunix.ImportCar(req.Context(), n, fi, pin)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&ImportOutput{Roots: roots})
+	},
+	Type: ImportOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*ImportOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected a different object in marshaler")
+			}
+
+			buf := new(bytes.Buffer)
+			for _, c := range out.Roots {
+				fmt.Fprintln(buf, c)
+			}
+			return buf, nil
+		},
+	},
+}
+
+// SelectorOption returns the selector set by the max-depth, path-glob and
+// link-glob options, or nil if none of them is set.
+func SelectorOption(req cmds.Request) (*dag.Selector, error) {
+	maxDepth, _, err := req.Option("max-depth").Int()
+	if err != nil {
+		return nil, err
+	}
+	pathGlob, _, err := req.Option("path-glob").String()
+	if err != nil {
+		return nil, err
+	}
+	linkGlob, _, err := req.Option("link-glob").String()
+	if err != nil {
+		return nil, err
+	}
+
+	if maxDepth < 0 && pathGlob == "" && linkGlob == "" {
+		return nil, nil
 	}
+	return dag.NewSelector(maxDepth, pathGlob, linkGlob)
 }
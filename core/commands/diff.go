@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	dagutils "github.com/ipfs/go-ipfs/merkledag/utils"
+	path "github.com/ipfs/go-ipfs/path"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	u "gx/ipfs/Qmb912gdngC1UWwTkhuW8knyRbcWeu5kqkxBpveLmW8bSr/go-ipfs-util"
+)
+
+// DiffOutput is a single line of the output of 'ipfs diff': a change, or in
+// merge mode a conflict between the changes of both sides.
+type DiffOutput struct {
+	Change   *dagutils.FileChange   `json:",omitempty"`
+	Conflict *dagutils.FileConflict `json:",omitempty"`
+	Err      string                 `json:",omitempty"`
+}
+
+var DiffCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the files changed between two directories.",
+		ShortDescription: `
+'ipfs diff' compares the unixfs trees under two paths and lists the files,
+directories and symlinks added, removed, modified or renamed between them.
+`,
+		LongDescription: `
+'ipfs diff' compares the unixfs trees under two paths and lists the files,
+directories and symlinks added, removed, modified or renamed between them.
+Directories present on both sides are compared recursively, and subtrees
+with the same hash on both sides are skipped without being fetched.
+
+An entry removed at one path and added with the same hash at another is
+listed as renamed. Modifications are listed as they are found, and the other
+changes once the whole trees have been compared.
+
+Each line of the output starts with the kind of change:
+
+  + <hash> <path>            added
+  - <hash> <path>            removed
+  ~ <hash> <hash> <path>     modified
+  > <hash> <path> -> <path>  renamed
+
+Use --enc=json to get each change as a JSON object, with the kind of the
+entry and the sizes of files.
+
+With --merge=<base>, both paths are compared against the base, and the two
+lists of changes are merged. Changes of both sides touching the same path are
+listed as conflicts, on lines starting with '!'.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("before", true, false, "Path to the tree to diff against."),
+		cmds.StringArg("after", true, false, "Path to the tree to diff."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("merge", "m", "Diff both paths against this base, and merge the two diffs."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		paths := req.Arguments()
+		if base, found, _ := req.Option("merge").String(); found {
+			paths = append([]string{base}, paths...)
+		}
+
+		var nodes []node.Node
+		for _, p := range paths {
+			pth, err := path.ParsePath(p)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+
+			nd, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, pth)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			nodes = append(nodes, nd)
+		}
+
+		out := make(chan interface{}, 8)
+		res.SetOutput((<-chan interface{})(out))
+
+		go func() {
+			defer close(out)
+
+			var err error
+			if len(nodes) == 3 {
+				err = diffMerge(req, n, nodes[0], nodes[1], nodes[2], out)
+			} else {
+				err = dagutils.DiffFiles(req.Context(), n.DAG, nodes[0], nodes[1], func(c *dagutils.FileChange) error {
+					return sendDiffOutput(req, out, &DiffOutput{Change: c})
+				})
+			}
+			if err != nil {
+				out <- &DiffOutput{Err: err.Error()}
+			}
+		}()
+	},
+	Type: DiffOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			marshal := func(v interface{}) (io.Reader, error) {
+				obj, ok := v.(*DiffOutput)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+				if obj.Err != "" {
+					return nil, errors.New(obj.Err)
+				}
+
+				if obj.Conflict != nil {
+					return strings.NewReader(fmt.Sprintf("! %s\n  %s\n",
+						formatFileChange(obj.Conflict.A), formatFileChange(obj.Conflict.B))), nil
+				}
+				return strings.NewReader(formatFileChange(obj.Change) + "\n"), nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
+	},
+}
+
+// diffMerge diffs a and b against base, and sends the merge of both diffs
+// to out, followed by their conflicts.
+func diffMerge(req cmds.Request, n *core.IpfsNode, base, a, b node.Node, out chan<- interface{}) error {
+	collect := func(nd node.Node) ([]*dagutils.FileChange, error) {
+		var changes []*dagutils.FileChange
+		err := dagutils.DiffFiles(req.Context(), n.DAG, base, nd, func(c *dagutils.FileChange) error {
+			changes = append(changes, c)
+			return nil
+		})
+		return changes, err
+	}
+
+	ca, err := collect(a)
+	if err != nil {
+		return err
+	}
+	cb, err := collect(b)
+	if err != nil {
+		return err
+	}
+
+	merged, conflicts := dagutils.MergeFileDiffs(ca, cb)
+	for _, c := range merged {
+		if err := sendDiffOutput(req, out, &DiffOutput{Change: c}); err != nil {
+			return err
+		}
+	}
+	for i := range conflicts {
+		if err := sendDiffOutput(req, out, &DiffOutput{Conflict: &conflicts[i]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sendDiffOutput(req cmds.Request, out chan<- interface{}, o *DiffOutput) error {
+	select {
+	case out <- o:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+func formatFileChange(c *dagutils.FileChange) string {
+	switch c.Type {
+	case dagutils.Add:
+		return fmt.Sprintf("+ %s %s", c.After, c.Path)
+	case dagutils.Remove:
+		return fmt.Sprintf("- %s %s", c.Before, c.Path)
+	case dagutils.Mod:
+		return fmt.Sprintf("~ %s %s %s", c.Before, c.After, c.Path)
+	case dagutils.Rename:
+		return fmt.Sprintf("> %s %s -> %s", c.After, c.From, c.Path)
+	default:
+		return c.String()
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	gopath "path"
 	"strings"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
@@ -20,6 +21,7 @@ import (
 
 	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
 var log = logging.Logger("cmds/files")
@@ -39,24 +41,45 @@ of consistency guarantees. If the daemon is unexpectedly killed before running
 'ipfs files flush' on the files in question, then data may be lost. This also
 applies to running 'ipfs repo gc' concurrently with '--flush=false'
 operations.
+
+Besides the default one, named files roots can be created with
+'ipfs files roots create' and selected with '--root=<name>'. Each of them is
+a separate tree, so that applications sharing a daemon do not step on each
+other.
 `,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("f", "flush", "Flush target and ancestors after write.").Default(true),
+		cmds.StringOption("root", "Name of the files root to operate on. See 'ipfs files roots'.").Default(core.DefaultFilesRoot),
 	},
 	Subcommands: map[string]*cmds.Command{
-		"read":  FilesReadCmd,
-		"write": FilesWriteCmd,
-		"mv":    FilesMvCmd,
-		"cp":    FilesCpCmd,
-		"ls":    FilesLsCmd,
-		"mkdir": FilesMkdirCmd,
-		"stat":  FilesStatCmd,
-		"rm":    FilesRmCmd,
-		"flush": FilesFlushCmd,
+		"read":     FilesReadCmd,
+		"write":    FilesWriteCmd,
+		"mv":       FilesMvCmd,
+		"cp":       FilesCpCmd,
+		"ls":       FilesLsCmd,
+		"mkdir":    FilesMkdirCmd,
+		"stat":     FilesStatCmd,
+		"rm":       FilesRmCmd,
+		"flush":    FilesFlushCmd,
+		"snapshot": FilesSnapshotCmd,
+		"roots":    FilesRootsCmd,
+		"chmod":    FilesChmodCmd,
+		"touch":    FilesTouchCmd,
+		"ln":       FilesLnCmd,
+		"readlink": FilesReadlinkCmd,
 	},
 }
 
+// filesRoot returns the files root selected with --root.
+func filesRoot(req cmds.Request, n *core.IpfsNode) (*mfs.Root, error) {
+	name, _, err := req.Option("root").String()
+	if err != nil {
+		return nil, err
+	}
+	return n.GetFilesRoot(name)
+}
+
 var formatError = errors.New("Format was set by multiple options. Only one format option is allowed")
 
 var FilesStatCmd = &cmds.Command{
@@ -69,7 +92,7 @@ var FilesStatCmd = &cmds.Command{
 	},
 	Options: []cmds.Option{
 		cmds.StringOption("format", "Print statistics in given format. Allowed tokens: "+
-			"<hash> <size> <cumulsize> <type> <childs>. Conflicts with other format options.").Default(
+			"<hash> <size> <cumulsize> <type> <childs> <mode> <mtime>. Conflicts with other format options.").Default(
 			`<hash>
 Size: <size>
 CumulativeSize: <cumulsize>
@@ -91,13 +114,19 @@ Type: <type>`),
 			return
 		}
 
+		root, err := filesRoot(req, node)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		path, err := checkPath(req.Arguments()[0])
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		fsn, err := mfs.Lookup(node.FilesRoot, path)
+		fsn, err := mfs.Lookup(root, path)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -118,11 +147,20 @@ Type: <type>`),
 			buf := new(bytes.Buffer)
 
 			s, _ := statGetFormatOptions(res.Request())
+			if _, custom, _ := res.Request().Option("format").String(); !custom && s != "<hash>" && s != "<cumulsize>" {
+				// the default format only mentions unix metadata when
+				// there is some
+				if out.Mode != nil || out.Mtime != 0 {
+					s += "\nMode: <mode>\nMtime: <mtime>"
+				}
+			}
 			s = strings.Replace(s, "<hash>", out.Hash, -1)
 			s = strings.Replace(s, "<size>", fmt.Sprintf("%d", out.Size), -1)
 			s = strings.Replace(s, "<cumulsize>", fmt.Sprintf("%d", out.CumulativeSize), -1)
 			s = strings.Replace(s, "<childs>", fmt.Sprintf("%d", out.Blocks), -1)
 			s = strings.Replace(s, "<type>", out.Type, -1)
+			s = strings.Replace(s, "<mode>", formatMode(out.Mode), -1)
+			s = strings.Replace(s, "<mtime>", formatMtime(out.Mtime), -1)
 
 			fmt.Fprintln(buf, s)
 			return buf, nil
@@ -154,6 +192,20 @@ func statGetFormatOptions(req cmds.Request) (string, error) {
 	}
 }
 
+func formatMode(mode *uint32) string {
+	if mode == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%04o", *mode)
+}
+
+func formatMtime(mtime int64) string {
+	if mtime == 0 {
+		return "-"
+	}
+	return time.Unix(mtime, 0).Format(time.RFC3339)
+}
+
 func statNode(ds dag.DAGService, fsn mfs.FSNode) (*Object, error) {
 	nd, err := fsn.GetNode()
 	if err != nil {
@@ -177,12 +229,26 @@ func statNode(ds dag.DAGService, fsn mfs.FSNode) (*Object, error) {
 		return nil, err
 	}
 
+	m := ft.GetUnixMeta(d)
+	var mode *uint32
+	if m.HasMode {
+		perm := uint32(m.Mode)
+		mode = &perm
+	}
+	var unixtime int64
+	if !m.ModTime.IsZero() {
+		unixtime = m.ModTime.Unix()
+	}
+
 	var ndtype string
 	switch fsn.Type() {
 	case mfs.TDir:
 		ndtype = "directory"
 	case mfs.TFile:
 		ndtype = "file"
+		if d.GetType() == ft.TSymlink {
+			ndtype = "symlink"
+		}
 	default:
 		return nil, fmt.Errorf("Unrecognized node type: %s", fsn.Type())
 	}
@@ -193,6 +259,8 @@ func statNode(ds dag.DAGService, fsn mfs.FSNode) (*Object, error) {
 		Size:           d.GetFilesize(),
 		CumulativeSize: cumulsize,
 		Type:           ndtype,
+		Mode:           mode,
+		Mtime:          unixtime,
 	}, nil
 }
 
@@ -211,6 +279,12 @@ var FilesCpCmd = &cmds.Command{
 			return
 		}
 
+		root, err := filesRoot(req, node)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		flush, _, _ := req.Option("flush").Bool()
 
 		src, err := checkPath(req.Arguments()[0])
@@ -230,20 +304,20 @@ var FilesCpCmd = &cmds.Command{
 			dst += gopath.Base(src)
 		}
 
-		nd, err := getNodeFromPath(req.Context(), node, src)
+		nd, err := getNodeFromPath(req.Context(), node, root, src)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		err = mfs.PutNode(node.FilesRoot, dst, nd)
+		err = mfs.PutNode(root, dst, nd)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
 		if flush {
-			err := mfs.FlushPath(node.FilesRoot, dst)
+			err := mfs.FlushPath(root, dst)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
@@ -252,7 +326,7 @@ var FilesCpCmd = &cmds.Command{
 	},
 }
 
-func getNodeFromPath(ctx context.Context, node *core.IpfsNode, p string) (node.Node, error) {
+func getNodeFromPath(ctx context.Context, node *core.IpfsNode, root *mfs.Root, p string) (node.Node, error) {
 	switch {
 	case strings.HasPrefix(p, "/ipfs/"):
 		np, err := path.ParsePath(p)
@@ -276,7 +350,7 @@ func getNodeFromPath(ctx context.Context, node *core.IpfsNode, p string) (node.N
 
 		return pbnd, nil
 	default:
-		fsn, err := mfs.Lookup(node.FilesRoot, p)
+		fsn, err := mfs.Lookup(root, p)
 		if err != nil {
 			return nil, err
 		}
@@ -291,6 +365,8 @@ type Object struct {
 	CumulativeSize uint64
 	Blocks         int
 	Type           string
+	Mode           *uint32 `json:",omitempty"`
+	Mtime          int64   `json:",omitempty"`
 }
 
 type FilesLsOutput struct {
@@ -345,7 +421,13 @@ Examples:
 			return
 		}
 
-		fsn, err := mfs.Lookup(nd.FilesRoot, path)
+		root, err := filesRoot(req, nd)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fsn, err := mfs.Lookup(root, path)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -428,13 +510,19 @@ Examples:
 			return
 		}
 
+		root, err := filesRoot(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		path, err := checkPath(req.Arguments()[0])
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		fsn, err := mfs.Lookup(n.FilesRoot, path)
+		fsn, err := mfs.Lookup(root, path)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -536,6 +624,12 @@ Example:
 			return
 		}
 
+		root, err := filesRoot(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		src, err := checkPath(req.Arguments()[0])
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -547,7 +641,7 @@ Example:
 			return
 		}
 
-		err = mfs.Mv(n.FilesRoot, src, dst)
+		err = mfs.Mv(root, src, dst)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -569,10 +663,17 @@ If the '--flush' option is set to false, changes will not be propogated to the
 merkledag root. This can make operations much faster when doing a large number
 of writes to a deeper directory structure.
 
+The '--append' option writes at the end of the file. Only the last blocks of
+the file are rewritten, so appending stays cheap however large the file is.
+
+The '--hash' and '--cid-version' options select the hash function and CID
+version of a file created by the write. Existing files keep theirs.
+
 EXAMPLE:
 
     echo "hello world" | ipfs files write --create /myfs/a/b/file
     echo "hello world" | ipfs files write --truncate /myfs/a/b/file
+    echo "another line" | ipfs files write --append /myfs/logs/app.log
 
 WARNING:
 
@@ -589,7 +690,10 @@ stat' on the file or any of its ancestors.
 		cmds.IntOption("offset", "o", "Byte offset to begin writing at."),
 		cmds.BoolOption("create", "e", "Create the file if it does not exist."),
 		cmds.BoolOption("truncate", "t", "Truncate the file to size zero before writing."),
+		cmds.BoolOption("append", "a", "Write at the end of the file. Conflicts with --offset and --truncate."),
 		cmds.IntOption("count", "n", "Maximum number of bytes to read."),
+		cmds.StringOption("hash", "Hash function of a created file. Default: sha2-256."),
+		cmds.IntOption("cid-version", "CID version of a created file, 0 or 1. Default: 0, or 1 for hash functions other than sha2-256."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		path, err := checkPath(req.Arguments()[0])
@@ -601,6 +705,7 @@ stat' on the file or any of its ancestors.
 		create, _, _ := req.Option("create").Bool()
 		trunc, _, _ := req.Option("truncate").Bool()
 		flush, _, _ := req.Option("flush").Bool()
+		appnd, _, _ := req.Option("append").Bool()
 
 		nd, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -608,7 +713,13 @@ stat' on the file or any of its ancestors.
 			return
 		}
 
-		offset, _, err := req.Option("offset").Int()
+		root, err := filesRoot(req, nd)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		offset, offsetfound, err := req.Option("offset").Int()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -617,8 +728,31 @@ stat' on the file or any of its ancestors.
 			res.SetError(fmt.Errorf("cannot have negative write offset"), cmds.ErrNormal)
 			return
 		}
+		if appnd && (offsetfound || trunc) {
+			res.SetError(fmt.Errorf("--append cannot be used with --offset or --truncate"), cmds.ErrClient)
+			return
+		}
+
+		var prefix *cid.Prefix
+		hash, hashFound, _ := req.Option("hash").String()
+		version, versionFound, err := req.Option("cid-version").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if hashFound || versionFound {
+			if !versionFound {
+				version = -1
+			}
+			p, err := dag.PrefixFor(hash, version)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+			prefix = &p
+		}
 
-		fi, err := getFileHandle(nd.FilesRoot, path, create)
+		fi, err := getFileHandle(root, path, create, prefix)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -654,7 +788,11 @@ stat' on the file or any of its ancestors.
 			return
 		}
 
-		_, err = wfd.Seek(int64(offset), os.SEEK_SET)
+		if appnd {
+			_, err = wfd.Seek(0, os.SEEK_END)
+		} else {
+			_, err = wfd.Seek(int64(offset), os.SEEK_SET)
+		}
 		if err != nil {
 			log.Error("seekfail: ", err)
 			res.SetError(err, cmds.ErrNormal)
@@ -710,6 +848,12 @@ Examples:
 			return
 		}
 
+		root, err := filesRoot(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		dashp, _, _ := req.Option("parents").Bool()
 		dirtomake, err := checkPath(req.Arguments()[0])
 		if err != nil {
@@ -719,7 +863,7 @@ Examples:
 
 		flush, _, _ := req.Option("flush").Bool()
 
-		err = mfs.Mkdir(n.FilesRoot, dirtomake, dashp, flush)
+		err = mfs.Mkdir(root, dirtomake, dashp, flush)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -746,12 +890,18 @@ are run with the '--flush=false'.
 			return
 		}
 
+		root, err := filesRoot(req, nd)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		path := "/"
 		if len(req.Arguments()) > 0 {
 			path = req.Arguments()[0]
 		}
 
-		err = mfs.FlushPath(nd.FilesRoot, path)
+		err = mfs.FlushPath(root, path)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -787,6 +937,12 @@ Remove files or directories.
 			return
 		}
 
+		root, err := filesRoot(req, nd)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		path, err := checkPath(req.Arguments()[0])
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -804,7 +960,7 @@ Remove files or directories.
 		}
 
 		dir, name := gopath.Split(path)
-		parent, err := mfs.Lookup(nd.FilesRoot, dir)
+		parent, err := mfs.Lookup(root, dir)
 		if err != nil {
 			res.SetError(fmt.Errorf("parent lookup: %s", err), cmds.ErrNormal)
 			return
@@ -863,7 +1019,9 @@ Remove files or directories.
 	},
 }
 
-func getFileHandle(r *mfs.Root, path string, create bool) (*mfs.File, error) {
+// getFileHandle returns the file at path, creating it if create is set. A
+// created file is hashed with the given prefix, the default one if nil.
+func getFileHandle(r *mfs.Root, path string, create bool, prefix *cid.Prefix) (*mfs.File, error) {
 
 	target, err := mfs.Lookup(r, path)
 	switch err {
@@ -892,6 +1050,7 @@ func getFileHandle(r *mfs.Root, path string, create bool) (*mfs.File, error) {
 		}
 
 		nd := dag.NodeWithData(ft.FilePBData(nil, 0))
+		nd.SetPrefix(prefix)
 		err = pdir.AddChild(fname, nd)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+)
+
+var FilesChmodCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Change the permission bits of a file or directory.",
+		ShortDescription: `
+Records unix permission bits, given in octal, in a file or directory. They are
+restored by 'ipfs get'. A mode of 'none' removes them.
+
+    $ ipfs files chmod 0755 /bin/tool
+    $ ipfs files chmod none /bin/tool
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("mode", true, false, "Permission bits, in octal, or 'none'."),
+		cmds.StringArg("path", true, false, "Path to the file or directory."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		root, err := filesRoot(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		clear := req.Arguments()[0] == "none"
+		var mode uint64
+		if !clear {
+			mode, err = strconv.ParseUint(req.Arguments()[0], 8, 32)
+			if err != nil || os.FileMode(mode)&^os.ModePerm != 0 {
+				res.SetError(fmt.Errorf("invalid mode %q", req.Arguments()[0]), cmds.ErrClient)
+				return
+			}
+		}
+
+		path, err := checkPath(req.Arguments()[1])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fsn, err := mfs.Lookup(root, path)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if clear {
+			err = mfs.ClearMode(fsn)
+		} else {
+			err = mfs.SetMode(fsn, os.FileMode(mode))
+		}
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		flush, _, _ := req.Option("flush").Bool()
+		if flush {
+			if err := mfs.FlushPath(root, path); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+	},
+}
+
+var FilesTouchCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Set the modification time of a file or directory.",
+		ShortDescription: `
+Records a modification time in a file or directory, the current time unless
+'--mtime' is given. Missing files are created empty. The time is restored by
+'ipfs get'.
+
+    $ ipfs files touch /notes.txt
+    $ ipfs files touch --mtime=1490000000 /notes.txt
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path to the file or directory."),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("mtime", "Modification time, in seconds since the epoch."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		root, err := filesRoot(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		path, err := checkPath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		mtime := time.Now()
+		if secs, found, err := req.Option("mtime").Int(); err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		} else if found {
+			mtime = time.Unix(int64(secs), 0)
+		}
+
+		var fsn mfs.FSNode
+		fsn, err = mfs.Lookup(root, path)
+		if err == os.ErrNotExist {
+			fsn, err = getFileHandle(root, path, true, nil)
+		}
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := mfs.SetModTime(fsn, mtime); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		flush, _, _ := req.Option("flush").Bool()
+		if flush {
+			if err := mfs.FlushPath(root, path); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+	},
+}
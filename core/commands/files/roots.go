@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+)
+
+var FilesRootsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage named files roots.",
+		ShortDescription: `
+Besides the default files root, any number of named roots can be kept. Each
+is a separate tree, selected in the other 'ipfs files' commands with
+'--root=<name>', and kept by 'ipfs repo gc' like the default one.
+
+    $ ipfs files roots create myapp
+    $ ipfs files --root=myapp mkdir /data
+    $ ipfs files --root=myapp ls /
+
+A root created with '--key=<keyname>' is published through IPNS under that
+key from 'ipfs key list' each time it changes.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":     filesRootsLsCmd,
+		"create": filesRootsCreateCmd,
+		"rm":     filesRootsRmCmd,
+	},
+}
+
+type FilesRootOutput struct {
+	Name string
+	Key  string `json:",omitempty"`
+	Hash string
+}
+
+type FilesRootList struct {
+	Roots []FilesRootOutput
+}
+
+var filesRootsLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List files roots.",
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		roots, err := n.ListFilesRoots()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &FilesRootList{}
+		for _, r := range roots {
+			out.Roots = append(out.Roots, FilesRootOutput{
+				Name: r.Name,
+				Key:  r.Key,
+				Hash: r.Root.String(),
+			})
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list := res.Output().(*FilesRootList)
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			for _, r := range list.Roots {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.Hash, r.Key)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: FilesRootList{},
+}
+
+var filesRootsCreateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Create an empty named files root.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the new root."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("key", "k", "Name of a key to publish the root with through IPNS on every change."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		name := req.Arguments()[0]
+		key, _, _ := req.Option("key").String()
+
+		r, err := n.CreateFilesRoot(name, key)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		nd, err := r.GetValue().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&FilesRootOutput{
+			Name: name,
+			Key:  key,
+			Hash: nd.Cid().String(),
+		})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			r := res.Output().(*FilesRootOutput)
+			return bytes.NewBufferString(fmt.Sprintf("created files root %s\n", r.Name)), nil
+		},
+	},
+	Type: FilesRootOutput{},
+}
+
+var filesRootsRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove named files roots.",
+		ShortDescription: `
+Removes the given roots. Their content is no longer kept by 'ipfs repo gc'
+unless it is pinned or referenced from another root.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, true, "Names of the roots to remove."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		for _, name := range req.Arguments() {
+			if err := n.RemoveFilesRoot(name); err != nil {
+				res.SetError(fmt.Errorf("%s: %s", name, err), cmds.ErrNormal)
+				return
+			}
+		}
+	},
+}
@@ -0,0 +1,224 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+)
+
+var FilesSnapshotCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Record and restore past states of the files root.",
+		ShortDescription: `
+A snapshot is a named record of the files root at some point in time. The
+content of every snapshot is kept by 'ipfs repo gc' until the snapshot is
+removed, so that it can be brought back with 'ipfs files snapshot restore'.
+
+    $ ipfs files snapshot create before-cleanup
+    $ ipfs files rm -r /old
+    $ ipfs files snapshot restore before-cleanup
+
+Snapshots are taken of the default files root only. The daemon can also take
+them automatically, as set by the Files.Snapshots config key.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"create":  filesSnapshotCreateCmd,
+		"ls":      filesSnapshotLsCmd,
+		"restore": filesSnapshotRestoreCmd,
+		"rm":      filesSnapshotRmCmd,
+	},
+}
+
+type SnapshotOutput struct {
+	Name    string
+	Hash    string
+	Created time.Time
+	Period  string `json:",omitempty"`
+}
+
+type SnapshotList struct {
+	Snapshots []SnapshotOutput
+}
+
+func snapshotOutput(s *corerepo.Snapshot) SnapshotOutput {
+	return SnapshotOutput{
+		Name:    s.Name,
+		Hash:    s.Root.String(),
+		Created: s.Created,
+		Period:  s.Period,
+	}
+}
+
+var errSnapshotRoot = errors.New("snapshots are only supported for the default files root")
+
+func checkSnapshotRoot(req cmds.Request) error {
+	name, _, err := req.Option("root").String()
+	if err != nil {
+		return err
+	}
+	if name != "" && name != core.DefaultFilesRoot {
+		return errSnapshotRoot
+	}
+	return nil
+}
+
+var filesSnapshotCreateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Record the current files root as a snapshot.",
+		ShortDescription: `
+Flushes the files root and records its hash under the given name. If no name
+is given, the current time is used.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", false, false, "Name of the snapshot."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := checkSnapshotRoot(req); err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		name := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+		if len(req.Arguments()) > 0 {
+			name = req.Arguments()[0]
+		}
+
+		s, err := corerepo.CreateSnapshot(n, name)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := snapshotOutput(s)
+		res.SetOutput(&out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			s := res.Output().(*SnapshotOutput)
+			return bytes.NewBufferString(fmt.Sprintf("created snapshot %s of %s\n", s.Name, s.Hash)), nil
+		},
+	},
+	Type: SnapshotOutput{},
+}
+
+var filesSnapshotLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List snapshots of the files root, oldest first.",
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		snaps, err := corerepo.ListSnapshots(n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &SnapshotList{Snapshots: []SnapshotOutput{}}
+		for _, s := range snaps {
+			out.Snapshots = append(out.Snapshots, snapshotOutput(s))
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list := res.Output().(*SnapshotList)
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			for _, s := range list.Snapshots {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, s.Created.Format(time.RFC3339), s.Hash)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: SnapshotList{},
+}
+
+var filesSnapshotRestoreCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Replace the files root with a snapshot.",
+		ShortDescription: `
+Makes the given snapshot the files root again. The root being replaced is
+first recorded as a snapshot named 'pre-restore-<time>', so that the restore
+can be undone.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the snapshot to restore."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := checkSnapshotRoot(req); err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		prev, err := corerepo.RestoreSnapshot(req.Context(), n, req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := snapshotOutput(prev)
+		res.SetOutput(&out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			s := res.Output().(*SnapshotOutput)
+			return bytes.NewBufferString(fmt.Sprintf("restored %s, previous root saved as snapshot %s\n", res.Request().Arguments()[0], s.Name)), nil
+		},
+	},
+	Type: SnapshotOutput{},
+}
+
+var filesSnapshotRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove snapshots of the files root.",
+		ShortDescription: `
+Removes the given snapshots. Their content is no longer kept by 'ipfs repo gc'
+unless it is still in the files root, pinned or part of another snapshot.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, true, "Names of the snapshots to remove."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		for _, name := range req.Arguments() {
+			if err := corerepo.RemoveSnapshot(n, name); err != nil {
+				res.SetError(fmt.Errorf("%s: %s", name, err), cmds.ErrNormal)
+				return
+			}
+		}
+	},
+}
@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+)
+
+var FilesLnCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Make symbolic links.",
+		ShortDescription: `
+Creates a symbolic link at the given path that points to target. The target is
+stored as given and is not resolved, so it may be relative, absolute or
+dangling. 'ipfs get' recreates the link on disk.
+
+Only symbolic links are supported, so '-s' is required. To give an object a
+second name, use 'ipfs files cp'.
+
+    $ ipfs files ln -s ../lib/libfoo.so.1 /bin/libfoo.so
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("target", true, false, "Target of the link."),
+		cmds.StringArg("path", true, false, "Path of the link to create."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("symbolic", "s", "Make a symbolic link.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		symbolic, _, _ := req.Option("symbolic").Bool()
+		if !symbolic {
+			res.SetError(errors.New("only symbolic links are supported, pass -s"), cmds.ErrClient)
+			return
+		}
+
+		root, err := filesRoot(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		target := req.Arguments()[0]
+		if target == "" {
+			res.SetError(errors.New("symlink target cannot be empty"), cmds.ErrClient)
+			return
+		}
+
+		path, err := checkPath(req.Arguments()[1])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		flush, _, _ := req.Option("flush").Bool()
+
+		if err := mfs.Symlink(root, path, target, flush); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+type ReadlinkOutput struct {
+	Target string
+}
+
+var FilesReadlinkCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Print the target of a symbolic link.",
+		ShortDescription: `
+    $ ipfs files readlink /bin/libfoo.so
+    ../lib/libfoo.so.1
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path of the symbolic link."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		root, err := filesRoot(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		path, err := checkPath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fsn, err := mfs.Lookup(root, path)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fi, ok := fsn.(*mfs.File)
+		if !ok {
+			res.SetError(mfs.ErrNotSymlink, cmds.ErrNormal)
+			return
+		}
+
+		target, err := fi.Readlink()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&ReadlinkOutput{Target: target})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*ReadlinkOutput)
+			return bytes.NewBufferString(out.Target + "\n"), nil
+		},
+	},
+	Type: ReadlinkOutput{},
+}
@@ -212,7 +212,7 @@ func (gw *getWriter) writeExtracted(r io.Reader, fpath string) error {
 	bar.Start()
 	defer bar.Finish()
 
-	extractor := &tar.Extractor{fpath}
+	extractor := &tar.Extractor{Path: fpath}
 	return extractor.Extract(barR)
 }
 
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"os"
 	"text/tabwriter"
+	"time"
 
 	blockservice "github.com/ipfs/go-ipfs/blockservice"
 	cmds "github.com/ipfs/go-ipfs/commands"
@@ -23,6 +25,8 @@ type LsLink struct {
 	Name, Hash string
 	Size       uint64
 	Type       unixfspb.Data_DataType
+	Mode       *uint32 `json:",omitempty"`
+	Mtime      int64   `json:",omitempty"`
 }
 
 type LsObject struct {
@@ -43,6 +47,10 @@ the following format:
 
   <link base58 hash> <link size in bytes> <link name>
 
+With '-l', the permission bits and modification time recorded by
+'ipfs add --preserve-mode --preserve-mtime' are shown in front, or '-' if
+there are none.
+
 The JSON output contains type information.
 `,
 	},
@@ -53,6 +61,7 @@ The JSON output contains type information.
 	Options: []cmds.Option{
 		cmds.BoolOption("headers", "v", "Print table headers (Hash, Size, Name).").Default(false),
 		cmds.BoolOption("resolve-type", "Resolve linked objects to find out their types.").Default(true),
+		cmds.BoolOption("long", "l", "Show permission bits and modification times.").Default(false),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		nd, err := req.InvocContext().GetNode()
@@ -111,6 +120,8 @@ The JSON output contains type information.
 			}
 			for j, link := range dagnode.Links() {
 				t := unixfspb.Data_DataType(-1)
+				var mode *uint32
+				var mtime int64
 
 				linkNode, err := link.GetNode(req.Context(), dserv)
 				if err == merkledag.ErrNotFound && !resolve {
@@ -129,12 +140,23 @@ The JSON output contains type information.
 					}
 
 					t = d.GetType()
+
+					m := unixfs.GetUnixMeta(d)
+					if m.HasMode {
+						perm := uint32(m.Mode)
+						mode = &perm
+					}
+					if !m.ModTime.IsZero() {
+						mtime = m.ModTime.Unix()
+					}
 				}
 				output[i].Links[j] = LsLink{
-					Name: link.Name,
-					Hash: link.Cid.String(),
-					Size: link.Size,
-					Type: t,
+					Name:  link.Name,
+					Hash:  link.Cid.String(),
+					Size:  link.Size,
+					Type:  t,
+					Mode:  mode,
+					Mtime: mtime,
 				}
 			}
 		}
@@ -145,6 +167,7 @@ The JSON output contains type information.
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
 
 			headers, _, _ := res.Request().Option("headers").Bool()
+			long, _, _ := res.Request().Option("long").Bool()
 			output := res.Output().(*LsOutput)
 			buf := new(bytes.Buffer)
 			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
@@ -153,12 +176,18 @@ The JSON output contains type information.
 					fmt.Fprintf(w, "%s:\n", object.Hash)
 				}
 				if headers {
+					if long {
+						fmt.Fprint(w, "Mode\tModified\t")
+					}
 					fmt.Fprintln(w, "Hash\tSize\tName")
 				}
 				for _, link := range object.Links {
 					if link.Type == unixfspb.Data_Directory {
 						link.Name += "/"
 					}
+					if long {
+						fmt.Fprintf(w, "%s\t%s\t", lsMode(link), lsMtime(link))
+					}
 					fmt.Fprintf(w, "%s\t%v\t%s\n", link.Hash, link.Size, link.Name)
 				}
 				if len(output.Objects) > 1 {
@@ -172,3 +201,26 @@ The JSON output contains type information.
 	},
 	Type: LsOutput{},
 }
+
+// lsMode formats the permission bits of link like ls(1) does.
+func lsMode(link LsLink) string {
+	if link.Mode == nil {
+		return "-"
+	}
+
+	mode := os.FileMode(*link.Mode)
+	switch link.Type {
+	case unixfspb.Data_Directory:
+		mode |= os.ModeDir
+	case unixfspb.Data_Symlink:
+		mode |= os.ModeSymlink
+	}
+	return mode.String()
+}
+
+func lsMtime(link LsLink) string {
+	if link.Mtime == 0 {
+		return "-"
+	}
+	return time.Unix(link.Mtime, 0).Format("2006-01-02 15:04")
+}
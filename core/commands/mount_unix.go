@@ -41,6 +41,10 @@ You may have to create /ipfs and /ipns before using 'ipfs mount':
 > ipfs daemon &
 > ipfs mount
 
+The files root, the tree managed with 'ipfs files', can be mounted read-write
+as well with '--mfs=<path>', or by setting Mounts.MFS in the configuration.
+Changes are flushed to the files root when a file is closed or fsynced.
+
 Example:
 
 # setup
@@ -73,6 +77,7 @@ baz
 	Options: []cmds.Option{
 		cmds.StringOption("ipfs-path", "f", "The path where IPFS should be mounted."),
 		cmds.StringOption("ipns-path", "n", "The path where IPNS should be mounted."),
+		cmds.StringOption("mfs", "The path where the files root should be mounted, read-write."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		cfg, err := req.InvocContext().GetConfig()
@@ -112,7 +117,16 @@ baz
 			nsdir = cfg.Mounts.IPNS // NB: be sure to not redeclare!
 		}
 
-		err = nodeMount.Mount(node, fsdir, nsdir)
+		mfsdir, found, err := req.Option("mfs").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !found {
+			mfsdir = cfg.Mounts.MFS
+		}
+
+		err = nodeMount.Mount(node, fsdir, nsdir, mfsdir)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -121,6 +135,7 @@ baz
 		var output config.Mounts
 		output.IPFS = fsdir
 		output.IPNS = nsdir
+		output.MFS = mfsdir
 		res.SetOutput(&output)
 	},
 	Type: config.Mounts{},
@@ -129,6 +144,9 @@ baz
 			v := res.Output().(*config.Mounts)
 			s := fmt.Sprintf("IPFS mounted at: %s\n", v.IPFS)
 			s += fmt.Sprintf("IPNS mounted at: %s\n", v.IPNS)
+			if v.MFS != "" {
+				s += fmt.Sprintf("MFS mounted at: %s\n", v.MFS)
+			}
 			return strings.NewReader(s), nil
 		},
 	},
@@ -0,0 +1,140 @@
+package objectcmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	dagutils "github.com/ipfs/go-ipfs/merkledag/utils"
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+type MergeOutput struct {
+	Hash      string
+	Conflicts []dagutils.FileConflict
+}
+
+var ObjectMergeCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Merge the changes made to a directory in two of its versions.",
+		ShortDescription: `
+'ipfs object merge' applies the changes made to the <base> directory in both
+<ours> and <theirs> to it, and outputs the hash of the merged directory.
+`,
+		LongDescription: `
+'ipfs object merge' applies the changes made to the <base> directory in both
+<ours> and <theirs> to it, and outputs the hash of the merged directory.
+
+The changes are found as by 'ipfs diff'. Changes of both sides touching the
+same path, or a path under one changed by the other side, are in conflict.
+The --strategy option chooses how conflicts are resolved:
+
+  fail    Fail, listing the conflicts (default).
+  ours    Keep the changes of <ours>.
+  theirs  Keep the changes of <theirs>.
+  both    Keep the changes of <ours>, and copy the entries of <theirs> in
+          conflict next to them, with '.theirs' appended to their names.
+
+The conflicts resolved are listed before the merged hash.
+
+Example:
+
+  > ipfs object merge --strategy=both $BASE $OURS $THEIRS
+  CONFLICT: ours changed bar to QmRfFV..., theirs changed bar to QmUSvc...
+  QmcmRptkSPWhptCttgHg27QNDmnV33wAJyUkCnAvqD3eCD
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("base", true, false, "The directory both versions come from."),
+		cmds.StringArg("ours", true, false, "The version whose changes win with --strategy=ours."),
+		cmds.StringArg("theirs", true, false, "The version whose changes win with --strategy=theirs."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("strategy", "s", "How to resolve conflicts: fail, ours, theirs or both.").Default(dagutils.MergeFail),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		strategy, _, _ := req.Option("strategy").String()
+
+		var nodes []*dag.ProtoNode
+		for _, arg := range req.Arguments() {
+			p, err := path.ParsePath(arg)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+
+			nd, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, p)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			pbnd, ok := nd.(*dag.ProtoNode)
+			if !ok {
+				res.SetError(dag.ErrNotProtobuf, cmds.ErrNormal)
+				return
+			}
+			nodes = append(nodes, pbnd)
+		}
+
+		merged, conflicts, err := dagutils.Merge(req.Context(), n.DAG, nodes[0], nodes[1], nodes[2], strategy)
+		if err == dagutils.ErrMergeConflict {
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "%d conflicts, use --strategy to resolve them:\n", len(conflicts))
+			writeConflicts(buf, conflicts)
+			res.SetError(errors.New(strings.TrimSuffix(buf.String(), "\n")), cmds.ErrNormal)
+			return
+		}
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&MergeOutput{
+			Hash:      merged.Cid().String(),
+			Conflicts: conflicts,
+		})
+	},
+	Type: MergeOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*MergeOutput)
+			buf := new(bytes.Buffer)
+			writeConflicts(buf, out.Conflicts)
+			fmt.Fprintln(buf, out.Hash)
+			return buf, nil
+		},
+	},
+}
+
+func writeConflicts(w io.Writer, conflicts []dagutils.FileConflict) {
+	for _, c := range conflicts {
+		fmt.Fprintf(w, "CONFLICT: ours %s, theirs %s\n", describeChange(c.A), describeChange(c.B))
+	}
+}
+
+func describeChange(c *dagutils.FileChange) string {
+	switch c.Type {
+	case dagutils.Add:
+		return fmt.Sprintf("added %s at %s", c.After, c.Path)
+	case dagutils.Remove:
+		return fmt.Sprintf("removed %s", c.Path)
+	case dagutils.Mod:
+		return fmt.Sprintf("changed %s to %s", c.Path, c.After)
+	case dagutils.Rename:
+		return fmt.Sprintf("moved %s to %s", c.From, c.Path)
+	default:
+		return c.String()
+	}
+}
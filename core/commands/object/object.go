@@ -54,6 +54,7 @@ directly.`,
 		"data":  ObjectDataCmd,
 		"diff":  ObjectDiffCmd,
 		"get":   ObjectGetCmd,
+		"merge": ObjectMergeCmd,
 		"links": ObjectLinksCmd,
 		"new":   ObjectNewCmd,
 		"patch": ObjectPatchCmd,
@@ -346,6 +347,9 @@ links, create a file named 'node.json' with the contents:
 And then run:
 
 	$ ipfs object put node.json
+
+The '--hash' and '--cid-version' options select the hash function and CID
+version of the stored object. CIDv0 only supports sha2-256.
 `,
 	},
 
@@ -355,6 +359,8 @@ And then run:
 	Options: []cmds.Option{
 		cmds.StringOption("inputenc", "Encoding type of input data. One of: {\"protobuf\", \"json\"}.").Default("json"),
 		cmds.StringOption("datafieldenc", "Encoding type of the data field, either \"text\" or \"base64\".").Default("text"),
+		cmds.StringOption("hash", "Hash function to use. Default: sha2-256."),
+		cmds.IntOption("cid-version", "CID version, 0 or 1. Default: 0, or 1 for hash functions other than sha2-256."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -381,7 +387,22 @@ And then run:
 			return
 		}
 
-		output, err := objectPut(n, input, inputenc, datafieldenc)
+		hash, _, _ := req.Option("hash").String()
+		version, found, err := req.Option("cid-version").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !found {
+			version = -1
+		}
+		prefix, err := dag.PrefixFor(hash, version)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		output, err := objectPut(n, input, inputenc, datafieldenc, prefix)
 		if err != nil {
 			errType := cmds.ErrNormal
 			if err == ErrUnknownObjectEnc {
@@ -468,7 +489,7 @@ func nodeFromTemplate(template string) (*dag.ProtoNode, error) {
 var ErrEmptyNode = errors.New("no data or links in this node")
 
 // objectPut takes a format option, serializes bytes from stdin and updates the dag with that data
-func objectPut(n *core.IpfsNode, input io.Reader, encoding string, dataFieldEncoding string) (*Object, error) {
+func objectPut(n *core.IpfsNode, input io.Reader, encoding string, dataFieldEncoding string, prefix cid.Prefix) (*Object, error) {
 
 	data, err := ioutil.ReadAll(io.LimitReader(input, inputLimit+10))
 	if err != nil {
@@ -528,6 +549,7 @@ func objectPut(n *core.IpfsNode, input io.Reader, encoding string, dataFieldEnco
 		return nil, err
 	}
 
+	dagnode.SetPrefix(&prefix)
 	_, err = n.DAG.Add(dagnode)
 	if err != nil {
 		return nil, err
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 
@@ -11,8 +12,10 @@ import (
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	pin "github.com/ipfs/go-ipfs/pin"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
 
 	context "context"
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
 	u "gx/ipfs/Qmb912gdngC1UWwTkhuW8knyRbcWeu5kqkxBpveLmW8bSr/go-ipfs-util"
 	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
@@ -26,6 +29,7 @@ var PinCmd = &cmds.Command{
 		"add": addPinCmd,
 		"rm":  rmPinCmd,
 		"ls":  listPinCmd,
+		"why": whyPinCmd,
 	},
 }
 
@@ -44,6 +48,7 @@ var addPinCmd = &cmds.Command{
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Recursively pin the object linked to by the specified object(s).").Default(true),
+		cmds.IntOption("max-depth", "Only pin the objects down to this depth below the specified ones.").Default(-1),
 	},
 	Type: PinOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
@@ -62,7 +67,22 @@ var addPinCmd = &cmds.Command{
 			return
 		}
 
-		added, err := corerepo.Pin(n, req.Context(), req.Arguments(), recursive)
+		maxDepth, _, err := req.Option("max-depth").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var added []*cid.Cid
+		switch {
+		case maxDepth < 0:
+			added, err = corerepo.Pin(n, req.Context(), req.Arguments(), recursive)
+		case !recursive:
+			res.SetError(errors.New("--max-depth can not be used with --recursive=false"), cmds.ErrClient)
+			return
+		default:
+			added, err = corerepo.PinToDepth(n, req.Context(), req.Arguments(), maxDepth)
+		}
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -259,6 +279,89 @@ Example:
 	},
 }
 
+type PinWhyOutput struct {
+	Key     *cid.Cid
+	Reasons []gc.Reason
+}
+
+var whyPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Explain why an object is kept by the garbage collector.",
+		ShortDescription: `
+Lists the pins, and the other roots 'ipfs repo gc' keeps, that keep the given
+object, along with a path from each of them to it.
+`,
+		LongDescription: `
+Lists the pins, and the other roots 'ipfs repo gc' keeps, that keep the given
+object, along with a path from each of them to it. The roots are listed with
+their kind:
+
+    * "recursive": a recursive pin
+    * "depth-limited": a recursive pin with a maximum depth
+    * "best-effort": the root of the files API (ipfs files)
+    * "internal": an object used by the pinner itself
+    * "direct": a direct pin of the object
+
+Nothing is listed for an object the next garbage collection would remove.
+
+Example:
+	$ ipfs pin why QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN
+	QmZTR5bcpQD7cFgTorqxZDYaew1Wqgfbd2ud9QqGPAkK2V recursive /ipfs/QmZTR5bcpQD7cFgTorqxZDYaew1Wqgfbd2ud9QqGPAkK2V/docs/hello
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "Path to the object to explain."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		pth, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		c, err := core.ResolveToCid(req.Context(), n, pth)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		reasons, err := corerepo.Why(n, req.Context(), c)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&PinWhyOutput{Key: c, Reasons: reasons})
+	},
+	Type: PinWhyOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*PinWhyOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			if len(out.Reasons) == 0 {
+				fmt.Fprintf(buf, "%s is not kept, and would be removed by the next garbage collection\n", out.Key)
+				return buf, nil
+			}
+			for _, r := range out.Reasons {
+				p := path.Join(append([]string{"/ipfs", r.Root.String()}, r.Path...))
+				fmt.Fprintf(buf, "%s %s %s\n", r.Root, r.Kind, p)
+			}
+			return buf, nil
+		},
+	},
+}
+
 type RefKeyObject struct {
 	Type string
 }
@@ -332,10 +435,23 @@ func pinLsAll(typeStr string, ctx context.Context, n *core.IpfsNode) (map[string
 				return nil, err
 			}
 		}
+		for _, dp := range n.Pinning.DepthLimitedPins() {
+			sel := &dag.Selector{MaxDepth: dp.MaxDepth}
+			err := dag.Walk(n.Context(), n.DAG, dp.Key, sel, func(_ *cid.Cid, lnk *node.Link, _ int) (bool, error) {
+				set.Add(lnk.Cid)
+				return true, nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
 		AddToResultKeys(set.Keys(), "indirect")
 	}
 	if typeStr == "recursive" || typeStr == "all" {
 		AddToResultKeys(n.Pinning.RecursiveKeys(), "recursive")
+		for _, dp := range n.Pinning.DepthLimitedPins() {
+			AddToResultKeys([]*cid.Cid{dp.Key}, fmt.Sprintf("recursive, max depth %d", dp.MaxDepth))
+		}
 	}
 
 	return keys, nil
@@ -9,6 +9,7 @@ import (
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
+	dagcmd "github.com/ipfs/go-ipfs/core/commands/dag"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 
@@ -42,6 +43,8 @@ with the following format:
   <link base58 hash>
 
 NOTE: List all references recursively by using the flag '-r'.
+The --max-depth, --path-glob and --link-glob flags restrict the references
+listed recursively to a part of the dag, see 'ipfs dag export --help'.
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
@@ -55,6 +58,9 @@ NOTE: List all references recursively by using the flag '-r'.
 		cmds.BoolOption("edges", "e", "Emit edge format: `<from> -> <to>`.").Default(false),
 		cmds.BoolOption("unique", "u", "Omit duplicate refs from output.").Default(false),
 		cmds.BoolOption("recursive", "r", "Recursively list links of child nodes.").Default(false),
+		cmds.IntOption("max-depth", "Only list links down to this depth, implies -r.").Default(-1),
+		cmds.StringOption("path-glob", "Only list links along the paths matching this glob pattern, and under them, implies -r."),
+		cmds.StringOption("link-glob", "Only follow named links matching this glob pattern, implies -r."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		ctx := req.Context()
@@ -97,6 +103,12 @@ NOTE: List all references recursively by using the flag '-r'.
 			format = "<src> -> <dst>"
 		}
 
+		sel, err := dagcmd.SelectorOption(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
 		objs, err := objectsForPaths(ctx, n, req.Arguments())
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -116,6 +128,7 @@ NOTE: List all references recursively by using the flag '-r'.
 				Unique:    unique,
 				PrintFmt:  format,
 				Recursive: recursive,
+				Selector:  sel,
 			}
 
 			for _, o := range objs {
@@ -227,37 +240,71 @@ type RefWriter struct {
 	Recursive bool
 	PrintFmt  string
 
+	// Selector restricts the refs written. Without one, all the refs are
+	// written if Recursive is set, and only the direct ones otherwise.
+	Selector *dag.Selector
+
 	seen *cid.Set
 }
 
 // WriteRefs writes refs of the given object to the underlying writer.
 func (rw *RefWriter) WriteRefs(n node.Node) (int, error) {
-	if rw.Recursive {
-		return rw.writeRefsRecursive(n)
+	sel := rw.Selector
+	if sel == nil && !rw.Recursive {
+		sel = &dag.Selector{MaxDepth: 1}
+	}
+
+	if !rw.Recursive && rw.skip(n.Cid()) {
+		return 0, nil
 	}
-	return rw.writeRefsSingle(n)
+	return rw.writeRefs(n, sel, 1, 0)
 }
 
-func (rw *RefWriter) writeRefsRecursive(n node.Node) (int, error) {
+// writeRefs writes the refs of n selected by sel, its links pointing to
+// nodes at depth with the first matched components of the path pattern
+// matched. The nodes linked are fetched in parallel as the refs of the
+// first ones are written.
+func (rw *RefWriter) writeRefs(n node.Node, sel *dag.Selector, depth, matched int) (int, error) {
 	nc := n.Cid()
 
+	var links []*node.Link
+	var below []int
+	for _, lnk := range n.Links() {
+		if ok, m := sel.Follow(lnk.Name, depth, matched); ok {
+			links = append(links, lnk)
+			below = append(below, m)
+		}
+	}
+
+	var nodes []dag.NodeGetter
+	if sel == nil || sel.MaxDepth < 0 || depth < sel.MaxDepth {
+		cids := make([]*cid.Cid, len(links))
+		for i, lnk := range links {
+			cids[i] = lnk.Cid
+		}
+		nodes = dag.GetNodes(rw.Ctx, rw.DAG, cids)
+	}
+
 	var count int
-	for i, ng := range dag.GetDAG(rw.Ctx, rw.DAG, n) {
-		lc := n.Links()[i].Cid
-		if rw.skip(lc) {
+	for i, lnk := range links {
+		if rw.skip(lnk.Cid) {
 			continue
 		}
 
-		if err := rw.WriteEdge(nc, lc, n.Links()[i].Name); err != nil {
+		if err := rw.WriteEdge(nc, lnk.Cid, lnk.Name); err != nil {
 			return count, err
 		}
+		count++
 
-		nd, err := ng.Get(rw.Ctx)
+		if nodes == nil {
+			continue
+		}
+		nd, err := nodes[i].Get(rw.Ctx)
 		if err != nil {
 			return count, err
 		}
 
-		c, err := rw.writeRefsRecursive(nd)
+		c, err := rw.writeRefs(nd, sel, depth+1, below[i])
 		count += c
 		if err != nil {
 			return count, err
@@ -266,28 +313,6 @@ func (rw *RefWriter) writeRefsRecursive(n node.Node) (int, error) {
 	return count, nil
 }
 
-func (rw *RefWriter) writeRefsSingle(n node.Node) (int, error) {
-	c := n.Cid()
-
-	if rw.skip(c) {
-		return 0, nil
-	}
-
-	count := 0
-	for _, l := range n.Links() {
-		lc := l.Cid
-		if rw.skip(lc) {
-			continue
-		}
-
-		if err := rw.WriteEdge(c, lc, l.Name); err != nil {
-			return count, err
-		}
-		count++
-	}
-	return count, nil
-}
-
 // skip returns whether to skip a cid
 func (rw *RefWriter) skip(c *cid.Cid) bool {
 	if !rw.Unique {
@@ -2,19 +2,23 @@ package commands
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	lockfile "github.com/ipfs/go-ipfs/repo/fsrepo/lock"
 
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	u "gx/ipfs/Qmb912gdngC1UWwTkhuW8knyRbcWeu5kqkxBpveLmW8bSr/go-ipfs-util"
 )
 
@@ -46,10 +50,40 @@ var repoGcCmd = &cmds.Command{
 'ipfs repo gc' is a plumbing command that will sweep the local
 set of stored objects and remove ones that are not pinned in
 order to reclaim hard disk space.
+`,
+		LongDescription: `
+'ipfs repo gc' is a plumbing command that will sweep the local
+set of stored objects and remove ones that are not pinned in
+order to reclaim hard disk space.
+
+The collection first marks the blocks to keep, keeping track of them in the
+repo datastore, then goes through the blockstore and removes the others in
+batches. Adding and pinning only wait for the current batch, rather than for
+the whole collection.
+
+--incremental only goes through the blocks whose last reference from the
+recursive pins was removed since the last incremental collection, rather than
+through the whole blockstore. It requires the reference count index, enabled
+by the Datastore.RefCounts config option, and leaves the blocks that were
+never pinned for a full collection.
+
+--dry-run only lists the blocks the collection would remove, and how much
+space that would free, without removing anything. The blocks added while it
+runs may be listed as well.
+
+--progress prints the phase of the collection and how many blocks it went
+through to stderr, once a second. --max-time and --max-bytes stop the
+collection once it ran for the given duration, or removed blocks of the
+given total size, leaving the remaining garbage for the next one.
 `,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("quiet", "q", "Write minimal output.").Default(false),
+		cmds.BoolOption("progress", "Report the progress of the collection.").Default(false),
+		cmds.BoolOption("dry-run", "List the blocks that would be removed, without removing them.").Default(false),
+		cmds.BoolOption("incremental", "Only go through the blocks released by the removal of pins.").Default(false),
+		cmds.StringOption("max-time", "Stop the collection after this duration, e.g. '10m'."),
+		cmds.StringOption("max-bytes", "Stop the collection after removing this much data, e.g. '5GB'."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -58,7 +92,37 @@ order to reclaim hard disk space.
 			return
 		}
 
-		gcOutChan, err := corerepo.GarbageCollectAsync(n, req.Context())
+		var opts gc.Options
+		if maxTime, found, _ := req.Option("max-time").String(); found {
+			opts.MaxDuration, err = time.ParseDuration(maxTime)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+		}
+		if maxBytes, found, _ := req.Option("max-bytes").String(); found {
+			opts.MaxBytes, err = humanize.ParseBytes(maxBytes)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+		}
+		progress, _, _ := req.Option("progress").Bool()
+		dryRun, _, _ := req.Option("dry-run").Bool()
+		incremental, _, _ := req.Option("incremental").Bool()
+
+		var gcOutChan <-chan *corerepo.KeyRemoved
+		switch {
+		case dryRun && incremental:
+			res.SetError(errors.New("--dry-run can not be used with --incremental"), cmds.ErrClient)
+			return
+		case dryRun:
+			gcOutChan, err = corerepo.GarbageCollectDryRun(n, req.Context())
+		case incremental:
+			gcOutChan, err = corerepo.GarbageCollectReleased(n, req.Context(), opts)
+		default:
+			gcOutChan, err = corerepo.GarbageCollectWithOptions(n, req.Context(), opts)
+		}
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -70,6 +134,11 @@ order to reclaim hard disk space.
 		go func() {
 			defer close(outChan)
 			for k := range gcOutChan {
+				// without --progress, only a collection stopping on
+				// its budget, and the totals of a dry run, are reported
+				if k.Progress != nil && !progress && !k.Progress.BudgetExhausted && !dryRun {
+					continue
+				}
 				outChan <- k
 			}
 		}()
@@ -86,6 +155,8 @@ order to reclaim hard disk space.
 			if err != nil {
 				return nil, err
 			}
+			progress, _, _ := res.Request().Option("progress").Bool()
+			dryRun, _, _ := res.Request().Option("dry-run").Bool()
 
 			marshal := func(v interface{}) (io.Reader, error) {
 				obj, ok := v.(*corerepo.KeyRemoved)
@@ -93,10 +164,32 @@ order to reclaim hard disk space.
 					return nil, u.ErrCast()
 				}
 
+				if obj.Error != "" {
+					return nil, errors.New(obj.Error)
+				}
+
 				buf := new(bytes.Buffer)
-				if quiet {
+				if p := obj.Progress; p != nil {
+					if progress {
+						fmt.Fprintf(res.Stderr(), "%s: %d marked, %d scanned, %d removed\n",
+							p.Phase, p.Marked, p.Scanned, p.Removed)
+					}
+					if p.BudgetExhausted && !quiet {
+						fmt.Fprintln(res.Stderr(), "budget exhausted, stopped before the end of the collection")
+					}
+					if dryRun && p.Phase == gc.PhaseDone && !quiet {
+						fmt.Fprintf(buf, "would remove %d blocks, freeing %s\n",
+							p.Removed, humanize.Bytes(p.RemovedBytes))
+					}
+					return buf, nil
+				}
+
+				switch {
+				case quiet:
 					buf = bytes.NewBufferString(obj.Key.String() + "\n")
-				} else {
+				case dryRun:
+					buf = bytes.NewBufferString(fmt.Sprintf("would remove %s\n", obj.Key))
+				default:
 					buf = bytes.NewBufferString(fmt.Sprintf("removed %s\n", obj.Key))
 				}
 				return buf, nil
@@ -177,8 +270,20 @@ var RepoFsckCmd = &cmds.Command{
 'ipfs repo fsck' is a plumbing command that will remove repo and level db
 lockfiles, as well as the api file. This command can only run when no ipfs
 daemons are running.
+`,
+		LongDescription: `
+'ipfs repo fsck' is a plumbing command that will remove repo and level db
+lockfiles, as well as the api file. This command can only run when no ipfs
+daemons are running.
+
+With --refcounts, it then rebuilds the index of the references to the blocks
+from the recursive pins, enabled by the Datastore.RefCounts config option.
+This is needed once after enabling it, and after an update of it failed.
 `,
 	},
+	Options: []cmds.Option{
+		cmds.BoolOption("refcounts", "Rebuild the block reference count index.").Default(false),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		configRoot := req.InvocContext().ConfigRoot
 
@@ -212,7 +317,21 @@ daemons are running.
 			return
 		}
 
-		res.SetOutput(&MessageOutput{"Lockfiles have been removed.\n"})
+		msg := "Lockfiles have been removed.\n"
+		if refcounts, _, _ := req.Option("refcounts").Bool(); refcounts {
+			n, err := req.InvocContext().GetNode()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if err := corerepo.RebuildRefCounts(n, req.Context()); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			msg += "Reference counts have been rebuilt.\n"
+		}
+
+		res.SetOutput(&MessageOutput{msg})
 	},
 	Type: MessageOutput{},
 	Marshalers: cmds.MarshalerMap{
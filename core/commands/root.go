@@ -30,6 +30,7 @@ BASIC COMMANDS
   get <ref>     Download IPFS objects
   ls <ref>      List links from an object
   refs <ref>    List hashes of links from an object
+  diff <a> <b>  Show the files changed between two directories
 
 DATA STRUCTURE COMMANDS
   block         Interact with raw blocks in the datastore
@@ -100,6 +101,7 @@ var rootSubcommands = map[string]*cmds.Command{
 	"dag":       dag.DagCmd,
 	"dht":       DhtCmd,
 	"diag":      DiagCmd,
+	"diff":      DiffCmd,
 	"dns":       DNSCmd,
 	"files":     files.FilesCmd,
 	"get":       GetCmd,
@@ -142,6 +144,7 @@ var rootROSubcommands = map[string]*cmds.Command{
 	},
 	"cat":      CatCmd,
 	"commands": CommandsDaemonROCmd,
+	"diff":     DiffCmd,
 	"dns":      DNSCmd,
 	"get":      GetCmd,
 	"ls":       LsCmd,
@@ -161,7 +164,10 @@ var rootROSubcommands = map[string]*cmds.Command{
 	},
 	"dag": &cmds.Command{
 		Subcommands: map[string]*cmds.Command{
-			"get": dag.DagGetCmd,
+			"get":     dag.DagGetCmd,
+			"resolve": dag.DagResolveCmd,
+			"stat":    dag.DagStatCmd,
+			"export":  dag.DagExportCmd,
 		},
 	},
 	"refs":    RefsROCmd,
@@ -18,6 +18,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
@@ -107,12 +108,13 @@ type IpfsNode struct {
 	// Services
 	Peerstore  pstore.Peerstore     // storage for other Peer instances
 	Blockstore bstore.GCBlockstore  // the block store (lower level)
+	BlockUse   bstore.LRUBlockstore // tracks the use of blocks in the lru GC mode, or nil
 	Blocks     bserv.BlockService   // the block service, get/add blocks.
 	DAG        merkledag.DAGService // the merkle dag service, get/add objects.
 	Resolver   *path.Resolver       // the path resolution system
 	Reporter   metrics.Reporter
 	Discovery  discovery.Service
-	FilesRoot  *mfs.Root
+	FilesRoot  *mfs.Root // the default files root, replaced by SetFilesRoot; see GetFilesRoot
 
 	// Online
 	PeerHost     p2phost.Host        // the network host (server+client)
@@ -127,6 +129,11 @@ type IpfsNode struct {
 
 	Floodsub *floodsub.PubSub
 
+	// named files roots, loaded on first use. See GetFilesRoot. The lock
+	// also guards FilesRoot.
+	filesRootsLk sync.Mutex
+	filesRoots   map[string]*mfs.Root
+
 	proc goprocess.Process
 	ctx  context.Context
 
@@ -140,6 +147,7 @@ type IpfsNode struct {
 type Mounts struct {
 	Ipfs mount.Mount
 	Ipns mount.Mount
+	Mfs  mount.Mount
 }
 
 func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption RoutingOption, hostOption HostOption, do DiscoveryOption, pubsub, mplex bool) error {
@@ -393,10 +401,21 @@ func (n *IpfsNode) teardown() error {
 	// needs to use another during its shutdown/cleanup process, it should be
 	// closed before that other object
 
+	// the mfs mount flushes to the files root when going away
+	if n.Mounts.Mfs != nil && !n.Mounts.Mfs.IsActive() {
+		closers = append(closers, mount.Closer(n.Mounts.Mfs))
+	}
+
 	if n.FilesRoot != nil {
 		closers = append(closers, n.FilesRoot)
 	}
 
+	n.filesRootsLk.Lock()
+	for _, r := range n.filesRoots {
+		closers = append(closers, r)
+	}
+	n.filesRootsLk.Unlock()
+
 	if n.Exchange != nil {
 		closers = append(closers, n.Exchange)
 	}
@@ -416,6 +435,11 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, n.Blocks)
 	}
 
+	// the last uses of blocks are recorded once nothing uses them anymore
+	if n.BlockUse != nil {
+		closers = append(closers, n.BlockUse)
+	}
+
 	if n.Bootstrapper != nil {
 		closers = append(closers, n.Bootstrapper)
 	}
@@ -565,8 +589,11 @@ func (n *IpfsNode) loadBootstrapPeers() ([]pstore.PeerInfo, error) {
 	return toPeerInfos(parsed), nil
 }
 
+// filesRootKey is the datastore key holding the CID of the files root.
+var filesRootKey = ds.NewKey("/local/filesroot")
+
 func (n *IpfsNode) loadFilesRoot() error {
-	dsk := ds.NewKey("/local/filesroot")
+	dsk := filesRootKey
 	pf := func(ctx context.Context, c *cid.Cid) error {
 		return n.Repo.Datastore().Put(dsk, c.Bytes())
 	}
@@ -611,6 +638,24 @@ func (n *IpfsNode) loadFilesRoot() error {
 	return nil
 }
 
+// SetFilesRoot replaces the files root with nd, which must already be in
+// the DAG. The current root is flushed and closed first.
+func (n *IpfsNode) SetFilesRoot(nd *merkledag.ProtoNode) error {
+	n.filesRootsLk.Lock()
+	defer n.filesRootsLk.Unlock()
+
+	if n.FilesRoot != nil {
+		if err := n.FilesRoot.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := n.Repo.Datastore().Put(filesRootKey, nd.Cid().Bytes()); err != nil {
+		return err
+	}
+	return n.loadFilesRoot()
+}
+
 // SetupOfflineRouting loads the local nodes private key and
 // uses it to instantiate a routing system in offline mode.
 // This is primarily used for offline ipns modifications.
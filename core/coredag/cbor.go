@@ -0,0 +1,124 @@
+package coredag
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// maxCborDepth bounds the nesting of the cbor items splitCbor reads
+const maxCborDepth = 256
+
+var errMalformedCbor = errors.New("malformed cbor input")
+
+// splitCbor cuts a sequence of concatenated cbor items. Items are not
+// decoded, only scanned far enough to find where each ends.
+func splitCbor(r io.Reader) splitter {
+	s := &cborScanner{br: bufio.NewReader(r)}
+	return func() ([]byte, error) {
+		s.buf.Reset()
+
+		// io.EOF before the first byte of an item is the end of the input
+		if _, err := s.br.Peek(1); err != nil {
+			return nil, err
+		}
+
+		if err := s.item(0); err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		out := make([]byte, s.buf.Len())
+		copy(out, s.buf.Bytes())
+		return out, nil
+	}
+}
+
+type cborScanner struct {
+	br  *bufio.Reader
+	buf bytes.Buffer
+}
+
+func (s *cborScanner) readByte() (byte, error) {
+	b, err := s.br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	s.buf.WriteByte(b)
+	return b, nil
+}
+
+// item reads a whole cbor item, nested items included.
+func (s *cborScanner) item(depth int) error {
+	if depth > maxCborDepth {
+		return errMalformedCbor
+	}
+
+	b, err := s.readByte()
+	if err != nil {
+		return err
+	}
+	major, info := b>>5, b&0x1f
+
+	if info == 31 {
+		// indefinite length strings, arrays and maps end with a break
+		if major < 2 || major > 5 {
+			return errMalformedCbor
+		}
+		for {
+			next, err := s.br.Peek(1)
+			if err != nil {
+				return err
+			}
+			if next[0] == 0xff {
+				_, err := s.readByte()
+				return err
+			}
+			if err := s.item(depth + 1); err != nil {
+				return err
+			}
+		}
+	}
+
+	var v uint64
+	switch {
+	case info < 24:
+		v = uint64(info)
+	case info <= 27:
+		for i := 0; i < 1<<(info-24); i++ {
+			c, err := s.readByte()
+			if err != nil {
+				return err
+			}
+			v = v<<8 | uint64(c)
+		}
+	default:
+		return errMalformedCbor
+	}
+
+	switch major {
+	case 2, 3:
+		n, err := io.CopyN(&s.buf, s.br, int64(v))
+		if err != nil {
+			return err
+		}
+		if uint64(n) != v {
+			return io.ErrUnexpectedEOF
+		}
+	case 4, 5:
+		if major == 5 {
+			v *= 2
+		}
+		for i := uint64(0); i < v; i++ {
+			if err := s.item(depth + 1); err != nil {
+				return err
+			}
+		}
+	case 6:
+		return s.item(depth + 1)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Package coredag holds the parsers used by 'ipfs dag put' to turn input
+// data into dag nodes, keyed by input encoding and target format.
+package coredag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	ipldcbor "gx/ipfs/QmbuuwTd9x4NReZ7sxtiKk7wFcfDUo54MfWBdtF5MRCPGR/go-ipld-cbor"
+)
+
+// DagParser parses a single object read from r into a node.
+type DagParser func(r io.Reader) (node.Node, error)
+
+// splitter returns the next object of an input, or io.EOF once there are no
+// more.
+type splitter func() ([]byte, error)
+
+var (
+	parsersLk sync.RWMutex
+
+	// parsers maps input encodings to the parsers of each format they
+	// can be turned into
+	parsers = make(map[string]map[string]DagParser)
+
+	// splitters cut the inputs of the encodings able to hold several
+	// objects in a row. Inputs of other encodings are a single object.
+	splitters = map[string]func(io.Reader) splitter{
+		"json": splitJSON,
+		"cbor": splitCbor,
+	}
+)
+
+func init() {
+	AddParser("json", "cbor", parseJSONCbor)
+	AddParser("json", "dag-cbor", parseJSONCbor)
+
+	AddParser("raw", "raw", parseRaw)
+	AddParser("raw", "cbor", parseCbor)
+	AddParser("raw", "dag-cbor", parseCbor)
+	AddParser("raw", "protobuf", parseProtobuf)
+	AddParser("raw", "dag-pb", parseProtobuf)
+
+	AddParser("cbor", "cbor", parseCbor)
+	AddParser("cbor", "dag-cbor", parseCbor)
+
+	AddParser("protobuf", "protobuf", parseProtobuf)
+	AddParser("protobuf", "dag-pb", parseProtobuf)
+}
+
+// AddParser registers the parser turning input of encoding ienc into nodes of
+// the given format. Codec packages call it at init time, alongside
+// merkledag.RegisterCodec.
+func AddParser(ienc, format string, p DagParser) {
+	parsersLk.Lock()
+	defer parsersLk.Unlock()
+
+	fp, ok := parsers[ienc]
+	if !ok {
+		fp = make(map[string]DagParser)
+		parsers[ienc] = fp
+	}
+	fp[format] = p
+}
+
+func getParser(ienc, format string) (DagParser, error) {
+	parsersLk.RLock()
+	defer parsersLk.RUnlock()
+
+	fp, ok := parsers[ienc]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized input encoding: %s", ienc)
+	}
+	p, ok := fp[format]
+	if !ok {
+		return nil, fmt.Errorf("cannot parse %s input into format %s", ienc, format)
+	}
+	return p, nil
+}
+
+// ParseInputs parses every object of the input read from r, in encoding
+// ienc, into nodes of the given format, and calls f with each of them in
+// order. JSON input may hold a stream of objects, separated by newlines or
+// any whitespace, and CBOR input a sequence of concatenated items. Input in
+// other encodings is a single object.
+func ParseInputs(ienc, format string, r io.Reader, f func(node.Node) error) error {
+	p, err := getParser(ienc, format)
+	if err != nil {
+		return err
+	}
+
+	split, ok := splitters[ienc]
+	if !ok {
+		nd, err := p(r)
+		if err != nil {
+			return err
+		}
+		return f(nd)
+	}
+
+	next := split(r)
+	for {
+		obj, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		nd, err := p(bytes.NewReader(obj))
+		if err != nil {
+			return err
+		}
+		if err := f(nd); err != nil {
+			return err
+		}
+	}
+}
+
+// Rehash returns nd hashed with the multihash function mhType instead of its
+// own. Nodes with a CIDv0 are moved to CIDv1, as only sha2-256 can be used
+// with CIDv0.
+func Rehash(nd node.Node, mhType int) (node.Node, error) {
+	pref := nd.Cid().Prefix()
+	if pref.MhType == mhType {
+		return nd, nil
+	}
+
+	pref.Version = 1
+	pref.MhType = mhType
+	pref.MhLength = -1
+
+	c, err := pref.Sum(nd.RawData())
+	if err != nil {
+		return nil, err
+	}
+
+	blk, err := blocks.NewBlockWithCid(nd.RawData(), c)
+	if err != nil {
+		return nil, err
+	}
+	return dag.DecodeBlock(blk)
+}
+
+func splitJSON(r io.Reader) splitter {
+	dec := json.NewDecoder(r)
+	return func() ([]byte, error) {
+		var obj json.RawMessage
+		if err := dec.Decode(&obj); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+}
+
+func parseJSONCbor(r io.Reader) (node.Node, error) {
+	return ipldcbor.FromJson(r)
+}
+
+func parseRaw(r io.Reader) (node.Node, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return dag.NewRawNode(data), nil
+}
+
+func parseCbor(r io.Reader) (node.Node, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ipldcbor.Decode(data)
+}
+
+func parseProtobuf(r io.Reader) (node.Node, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return dag.DecodeProtobuf(data)
+}
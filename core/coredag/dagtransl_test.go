@@ -0,0 +1,105 @@
+package coredag
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	mh "gx/ipfs/QmYDds3421prZgqKbLpEK7T9Aa2eVdQ7o3YarX1LVLdP2J/go-multihash"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+func parseAll(t *testing.T, ienc, format string, data []byte) []node.Node {
+	var out []node.Node
+	err := ParseInputs(ienc, format, bytes.NewReader(data), func(nd node.Node) error {
+		out = append(out, nd)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestParseJSONStream(t *testing.T) {
+	nds := parseAll(t, "json", "cbor", []byte("{\"a\":1}\n{\"b\":2}\n\n{\"c\":[3]}\n"))
+	if len(nds) != 3 {
+		t.Fatalf("expected 3 nodes, got %d", len(nds))
+	}
+
+	// the cbor encoding of each node parses back to the same nodes
+	var enc []byte
+	for _, nd := range nds {
+		enc = append(enc, nd.RawData()...)
+	}
+	back := parseAll(t, "cbor", "cbor", enc)
+	if len(back) != len(nds) {
+		t.Fatalf("expected %d nodes, got %d", len(nds), len(back))
+	}
+	for i := range nds {
+		if !back[i].Cid().Equals(nds[i].Cid()) {
+			t.Fatalf("node %d changed going through cbor", i)
+		}
+	}
+}
+
+func TestParseRawProtobuf(t *testing.T) {
+	pbnd := dag.NodeWithData([]byte("protobuf data"))
+	enc, err := pbnd.EncodeProtobuf(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ienc := range []string{"raw", "protobuf"} {
+		nds := parseAll(t, ienc, "protobuf", enc)
+		if len(nds) != 1 || !nds[0].Cid().Equals(pbnd.Cid()) {
+			t.Fatalf("%s input did not parse to the protobuf node", ienc)
+		}
+	}
+
+	nds := parseAll(t, "raw", "raw", enc)
+	if len(nds) != 1 || nds[0].Cid().Type() != cid.Raw {
+		t.Fatal("raw input did not parse to a raw node")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	nop := func(node.Node) error { return nil }
+
+	if err := ParseInputs("yaml", "cbor", strings.NewReader(""), nop); err == nil {
+		t.Fatal("expected an error for an unknown input encoding")
+	}
+	if err := ParseInputs("json", "protobuf", strings.NewReader("{}"), nop); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+	if err := ParseInputs("json", "cbor", strings.NewReader("{\"a\":1}\n{\"b\""), nop); err == nil {
+		t.Fatal("expected an error for truncated input")
+	}
+	if err := ParseInputs("cbor", "cbor", bytes.NewReader([]byte{0xa1, 0x61, 'a'}), nop); err == nil {
+		t.Fatal("expected an error for truncated cbor")
+	}
+}
+
+func TestRehash(t *testing.T) {
+	for _, nd := range []node.Node{
+		dag.NodeWithData([]byte("protobuf")),
+		dag.NewRawNode([]byte("raw")),
+		parseAll(t, "json", "cbor", []byte("{\"a\":1}"))[0],
+	} {
+		out, err := Rehash(nd, mh.SHA2_512)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pref := out.Cid().Prefix()
+		if pref.MhType != mh.SHA2_512 || pref.Version != 1 || pref.Codec != nd.Cid().Type() {
+			t.Fatalf("unexpected prefix %v for %s", pref, nd.Cid())
+		}
+		if !bytes.Equal(out.RawData(), nd.RawData()) {
+			t.Fatal("rehashing changed the data")
+		}
+	}
+}
@@ -110,6 +110,15 @@ func commandsOption(cctx commands.Context, command *commands.Command) ServeOptio
 		}
 
 		addHeadersFromConfig(cfg, rcfg)
+		cfg.APITokens = func() ([]config.APIToken, error) {
+			// read the config on every request, so that tokens added or
+			// removed through the API apply immediately
+			rcfg, err := n.Repo.Config()
+			if err != nil {
+				return nil, err
+			}
+			return rcfg.API.Tokens, nil
+		}
 		addCORSFromEnv(cfg)
 		addCORSDefaults(cfg)
 		patchCORSVars(cfg, l.Addr())
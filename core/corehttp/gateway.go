@@ -15,6 +15,10 @@ type GatewayConfig struct {
 	Headers      map[string][]string
 	Writable     bool
 	PathPrefixes []string
+
+	// WriteTokens are the bearer tokens allowed to write to /ipns names
+	// backed by local keys when the gateway is writable.
+	WriteTokens []string
 }
 
 func GatewayOption(writable bool, paths ...string) ServeOption {
@@ -28,6 +32,7 @@ func GatewayOption(writable bool, paths ...string) ServeOption {
 			Headers:      cfg.Gateway.HTTPHeaders,
 			Writable:     writable,
 			PathPrefixes: cfg.Gateway.PathPrefixes,
+			WriteTokens:  cfg.Gateway.WriteTokens,
 		}, coreapi.NewUnixfsAPI(n))
 
 		for _, p := range paths {
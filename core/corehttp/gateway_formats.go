@@ -0,0 +1,145 @@
+package corehttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	gopath "path"
+	"strings"
+	"time"
+
+	car "github.com/ipfs/go-ipfs/car"
+	core "github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	path "github.com/ipfs/go-ipfs/path"
+	uarchive "github.com/ipfs/go-ipfs/unixfs/archive"
+)
+
+// Response formats the gateway can serve instead of the default
+// file bytes or directory listing.
+const (
+	formatRaw = "raw"
+	formatTar = "tar"
+	formatCar = "car"
+)
+
+// formatContentTypes maps each response format to its media type. The same
+// media types are recognized in the Accept header of a request.
+var formatContentTypes = map[string]string{
+	formatRaw: "application/vnd.ipld.raw",
+	formatTar: "application/x-tar",
+	formatCar: "application/vnd.ipld.car",
+}
+
+var errUnknownFormat = errors.New("unknown response format")
+
+// responseFormat returns the format requested through the ?format= query
+// parameter or, failing that, the Accept header. It returns an empty string
+// for regular requests.
+func responseFormat(r *http.Request) (string, error) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		if _, ok := formatContentTypes[f]; !ok {
+			return "", errUnknownFormat
+		}
+		return f, nil
+	}
+
+	for _, accept := range r.Header["Accept"] {
+		for _, t := range strings.Split(accept, ",") {
+			mt, _, err := mime.ParseMediaType(t)
+			if err != nil {
+				continue
+			}
+			for f, ct := range formatContentTypes {
+				if mt == ct {
+					return f, nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// serveFormat resolves urlPath and writes it out as a raw block, a tar
+// archive or a CAR stream, depending on format.
+func (i *gatewayHandler) serveFormat(ctx context.Context, w http.ResponseWriter, r *http.Request, urlPath, format string) {
+	p, err := path.ParsePath(urlPath)
+	if err != nil {
+		webError(w, "Invalid path", err, http.StatusBadRequest)
+		return
+	}
+
+	nd, err := core.Resolve(ctx, i.node.Namesys, i.node.Resolver, p)
+	if err != nil {
+		webError(w, "Path Resolve error", err, http.StatusBadRequest)
+		return
+	}
+	c := nd.Cid()
+
+	// the etag names the resolved object rather than the path, so that
+	// /ipns responses can be revalidated too.
+	etag := fmt.Sprintf("%q", c.String()+"."+format)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	i.addUserHeaders(w) // ok, _now_ write user's headers.
+	w.Header().Set("X-IPFS-Path", urlPath)
+	w.Header().Set("Etag", etag)
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("Content-Type", formatContentTypes[format])
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", c, format))
+
+	modtime := time.Now()
+	if strings.HasPrefix(urlPath, ipfsPathPrefix) {
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+		modtime = time.Unix(1, 0)
+	}
+
+	var rd io.Reader
+	switch format {
+	case formatRaw:
+		http.ServeContent(w, r, c.String(), modtime, bytes.NewReader(nd.RawData()))
+		return
+	case formatTar:
+		pbnd, ok := nd.(*dag.ProtoNode)
+		if !ok {
+			webError(w, "Cannot archive non protobuf nodes", dag.ErrNotProtobuf, http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == "HEAD" {
+			return
+		}
+
+		rd, err = uarchive.DagArchive(ctx, pbnd, gopath.Base(urlPath), i.node.DAG, true, gzip.NoCompression)
+		if err != nil {
+			internalWebError(w, err)
+			return
+		}
+	case formatCar:
+		if r.Method == "HEAD" {
+			return
+		}
+
+		piper, pipew := io.Pipe()
+		go func() {
+			pipew.CloseWithError(car.WriteCar(ctx, i.node.DAG, c, pipew))
+		}()
+		defer piper.Close()
+		rd = piper
+	}
+
+	// the status has already been sent once we start streaming, so all we
+	// can do about failures from here on is log them and cut the body short.
+	if _, err := io.Copy(w, rd); err != nil {
+		log.Errorf("error streaming %s of %s: %s", format, urlPath, err)
+	}
+}
@@ -2,7 +2,6 @@ package corehttp
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -38,6 +37,10 @@ type gatewayHandler struct {
 	node   *core.IpfsNode
 	config GatewayConfig
 	api    coreiface.UnixfsAPI
+
+	// used by the writes to /ipns/ paths
+	keyNames  keyNames
+	nameLocks nameLocks
 }
 
 func newGatewayHandler(n *core.IpfsNode, c GatewayConfig, api coreiface.UnixfsAPI) *gatewayHandler {
@@ -84,6 +87,14 @@ func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	if i.config.Writable {
+		if strings.HasPrefix(r.URL.Path, ipnsPathPrefix) {
+			switch r.Method {
+			case "POST", "PUT", "DELETE":
+				i.ipnsWriteHandler(ctx, w, r)
+				return
+			}
+		}
+
 		switch r.Method {
 		case "POST":
 			i.postHandler(ctx, w, r)
@@ -132,6 +143,18 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 
 	urlPath := r.URL.Path
 
+	// Clients verifying content ask for the raw block or an archive of the
+	// DAG, rather than the file bytes, via ?format= or the Accept header.
+	format, err := responseFormat(r)
+	if err != nil {
+		webError(w, "Invalid format", err, http.StatusBadRequest)
+		return
+	}
+	if format != "" {
+		i.serveFormat(ctx, w, r, urlPath, format)
+		return
+	}
+
 	// If the gateway is behind a reverse proxy and mounted at a sub-path,
 	// the prefix header can be set to signal this sub-path.
 	// It will be prepended to links in directory listings and the index.html redirect.
@@ -159,6 +182,13 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 	}
 
 	dr, err := i.api.Cat(ctx, urlPath)
+	if _, ok := err.(path.ErrNoLink); ok {
+		// sites can provide their own not found pages and redirects
+		if i.serveNotFound(ctx, w, r, urlPath, prefix, ipnsHostname) {
+			return
+		}
+	}
+
 	dir := false
 	switch err {
 	case nil:
@@ -337,10 +367,6 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rsegs := rootPath.Segments()
-	if rsegs[0] == ipnsPathPrefix {
-		webError(w, "putHandler: updating named entries not supported", errors.New("WritableGateway: ipns put not supported"), http.StatusBadRequest)
-		return
-	}
 
 	var newnode node.Node
 	if rsegs[len(rsegs)-1] == "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn" {
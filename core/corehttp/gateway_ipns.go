@@ -0,0 +1,283 @@
+package corehttp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	gopath "path"
+	"strings"
+	"sync"
+
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	dagutils "github.com/ipfs/go-ipfs/merkledag/utils"
+	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	peer "gx/ipfs/QmfMmLGoKzCHDN7cGgk64PJr4iipzidDRME8HABSJqvmhC/go-libp2p-peer"
+	ci "gx/ipfs/QmfWDLQjGjVe4fr5CoztYW2DYYjRysMJrFe1RCsXLPTf46/go-libp2p-crypto"
+)
+
+var (
+	errIpnsWriteDisabled = errors.New("WritableGateway: no write tokens configured for ipns")
+	errUnauthorized      = errors.New("WritableGateway: missing or invalid authorization token")
+	errNotLocalKey       = errors.New("WritableGateway: name is not backed by a local key")
+)
+
+// authorizedWrite reports whether the request carries one of the configured
+// write tokens as "Authorization: Bearer <token>".
+func (i *gatewayHandler) authorizedWrite(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := []byte(strings.TrimPrefix(auth, "Bearer "))
+
+	for _, t := range i.config.WriteTokens {
+		if subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// localKey returns the private key behind name, which may either be the
+// name of a key in the keystore ("self" included) or its peer ID. Keys are
+// looked up by name, the peer IDs of the keystore being only listed when
+// name is one not seen before.
+func (i *gatewayHandler) localKey(name string) (ci.PrivKey, peer.ID, error) {
+	if name == "self" || name == i.node.Identity.Pretty() {
+		return i.node.PrivateKey, i.node.Identity, nil
+	}
+
+	ks := i.node.Repo.Keystore()
+	if ks == nil {
+		return nil, "", errNotLocalKey
+	}
+
+	k, err := ks.Get(name)
+	switch err {
+	case nil:
+		pid, err := peer.IDFromPrivateKey(k)
+		if err != nil {
+			return nil, "", err
+		}
+		return k, pid, nil
+	case keystore.ErrNoSuchKey:
+		// maybe a peer ID
+	default:
+		return nil, "", err
+	}
+
+	pid, err := peer.IDB58Decode(name)
+	if err != nil {
+		return nil, "", errNotLocalKey
+	}
+	return i.keyNames.get(ks, pid)
+}
+
+// keyNames caches the names of the keys of the keystore by peer ID.
+type keyNames struct {
+	lk    sync.Mutex
+	names map[peer.ID]string
+}
+
+// get returns the key of the keystore with the given peer ID, listing the
+// keystore again if it is not known yet or its key was removed since.
+func (kn *keyNames) get(ks keystore.Keystore, pid peer.ID) (ci.PrivKey, peer.ID, error) {
+	kn.lk.Lock()
+	defer kn.lk.Unlock()
+
+	if kname, ok := kn.names[pid]; ok {
+		k, err := ks.Get(kname)
+		if err == nil {
+			return k, pid, nil
+		}
+		if err != keystore.ErrNoSuchKey {
+			return nil, "", err
+		}
+	}
+
+	names, err := ks.List()
+	if err != nil {
+		return nil, "", err
+	}
+	kn.names = make(map[peer.ID]string, len(names))
+	var found ci.PrivKey
+	for _, kname := range names {
+		k, err := ks.Get(kname)
+		if err != nil {
+			return nil, "", err
+		}
+		kpid, err := peer.IDFromPrivateKey(k)
+		if err != nil {
+			return nil, "", err
+		}
+		kn.names[kpid] = kname
+		if kpid == pid {
+			found = k
+		}
+	}
+
+	if found == nil {
+		return nil, "", errNotLocalKey
+	}
+	return found, pid, nil
+}
+
+// nameLocks serializes the updates of each ipns name, from the resolve of
+// its current value to the publish of the new one.
+type nameLocks struct {
+	lk    sync.Mutex
+	locks map[peer.ID]*nameLock
+}
+
+type nameLock struct {
+	sync.Mutex
+	waiters int
+}
+
+// lock locks the name of pid, and returns the function unlocking it.
+func (nl *nameLocks) lock(pid peer.ID) func() {
+	nl.lk.Lock()
+	if nl.locks == nil {
+		nl.locks = make(map[peer.ID]*nameLock)
+	}
+	l, ok := nl.locks[pid]
+	if !ok {
+		l = new(nameLock)
+		nl.locks[pid] = l
+	}
+	l.waiters++
+	nl.lk.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		nl.lk.Lock()
+		defer nl.lk.Unlock()
+		if l.waiters--; l.waiters == 0 {
+			delete(nl.locks, pid)
+		}
+	}
+}
+
+// ipnsWriteHandler applies PUT, POST and DELETE requests on
+// /ipns/<key>/<path> to the tree currently published under a local key,
+// and republishes the key with the result.
+func (i *gatewayHandler) ipnsWriteHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if len(i.config.WriteTokens) == 0 {
+		webErrorWithCode(w, "ipnsWriteHandler", errIpnsWriteDisabled, http.StatusMethodNotAllowed)
+		return
+	}
+	if !i.authorizedWrite(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		webErrorWithCode(w, "ipnsWriteHandler", errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	if i.node.Mounts.Ipns != nil && i.node.Mounts.Ipns.IsActive() {
+		webError(w, "ipnsWriteHandler", errors.New("cannot publish while IPNS is mounted"), http.StatusConflict)
+		return
+	}
+
+	segs := path.SplitList(strings.TrimPrefix(r.URL.Path, ipnsPathPrefix))
+	name := segs[0]
+	fpath := strings.TrimSuffix(path.Join(segs[1:]), "/")
+	if fpath == "" {
+		webError(w, "ipnsWriteHandler: a file path below the name is required", errors.New("no path given"), http.StatusBadRequest)
+		return
+	}
+
+	k, pid, err := i.localKey(name)
+	if err != nil {
+		webError(w, "ipnsWriteHandler", err, http.StatusForbidden)
+		return
+	}
+
+	unlock := i.nameLocks.lock(pid)
+	defer unlock()
+
+	root, err := i.currentIpnsRoot(ctx, pid)
+	if err != nil {
+		webError(w, "ipnsWriteHandler: could not resolve current root", err, http.StatusBadGateway)
+		return
+	}
+
+	e := dagutils.NewDagEditor(root, i.node.DAG)
+	switch r.Method {
+	case "PUT", "POST":
+		var newnode node.Node
+		if strings.HasSuffix(r.URL.Path, "/") {
+			newnode = ft.EmptyDirNode()
+		} else {
+			newnode, err = i.newDagFromReader(r.Body)
+			if err != nil {
+				webError(w, "ipnsWriteHandler: Could not create DAG from request", err, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		err = e.InsertNodeAtPath(ctx, fpath, newnode, ft.EmptyDirNode)
+		if err != nil {
+			webError(w, "ipnsWriteHandler: InsertNodeAtPath failed", err, http.StatusInternalServerError)
+			return
+		}
+	case "DELETE":
+		err = e.RmLink(ctx, fpath)
+		if err != nil {
+			if err == dag.ErrLinkNotFound {
+				webErrorWithCode(w, "ipnsWriteHandler: Could not delete link", err, http.StatusNotFound)
+				return
+			}
+			webError(w, "ipnsWriteHandler: Could not delete link", err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	nnode, err := e.Finalize(i.node.DAG)
+	if err != nil {
+		webError(w, "ipnsWriteHandler: could not get node", err, http.StatusInternalServerError)
+		return
+	}
+	ncid := nnode.Cid()
+
+	if err := i.node.Namesys.Publish(ctx, k, path.FromCid(ncid)); err != nil {
+		webError(w, "ipnsWriteHandler: could not publish", err, http.StatusInternalServerError)
+		return
+	}
+
+	i.addUserHeaders(w) // ok, _now_ write user's headers.
+	w.Header().Set("IPFS-Hash", ncid.String())
+
+	location := gopath.Join(ipnsPathPrefix, name, fpath)
+	if r.Method == "DELETE" {
+		location = gopath.Dir(location)
+	}
+	http.Redirect(w, r, location, http.StatusCreated)
+}
+
+// currentIpnsRoot returns the directory currently published under pid.
+// Names have to be published once with 'ipfs name publish' before they can
+// be written to, so that a failed resolve never replaces a site with an
+// empty directory.
+func (i *gatewayHandler) currentIpnsRoot(ctx context.Context, pid peer.ID) (*dag.ProtoNode, error) {
+	p, err := i.node.Namesys.Resolve(ctx, ipnsPathPrefix+pid.Pretty())
+	if err != nil {
+		return nil, err
+	}
+
+	nd, err := i.node.Resolver.ResolvePath(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil, dag.ErrNotProtobuf
+	}
+	return pbnd, nil
+}
@@ -0,0 +1,209 @@
+package corehttp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	gopath "path"
+	"strconv"
+	"strings"
+	"time"
+
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+const (
+	// notFoundFile is served, with a 404 status, in place of a missing
+	// file. It is looked up in every directory from the missing file's
+	// parent up to the site root.
+	notFoundFile = "ipfs-404.html"
+
+	// redirectsFile holds the redirect rules of a site, at its root.
+	redirectsFile = "_redirects"
+
+	// maxRedirectsFileSize bounds how much of a _redirects file is read.
+	maxRedirectsFileSize = 64 * 1024
+)
+
+// redirectRule is a single line of a _redirects file:
+//
+//	<from> <to> [<status>]
+//
+// A <from> ending in /* matches everything below it, and the matched part
+// replaces :splat in <to>. A 200 status serves <to> in place of <from>, a
+// 404 status serves <to> as the not found page, and 3xx statuses redirect.
+type redirectRule struct {
+	From   string
+	To     string
+	Status int
+}
+
+// match returns the target for p if the rule applies to it.
+func (rr redirectRule) match(p string) (string, bool) {
+	if base := strings.TrimSuffix(rr.From, "/*"); base != rr.From {
+		if p != base && !strings.HasPrefix(p, base+"/") {
+			return "", false
+		}
+		splat := strings.TrimPrefix(strings.TrimPrefix(p, base), "/")
+		return strings.Replace(rr.To, ":splat", splat, -1), true
+	}
+
+	if p == rr.From || p == rr.From+"/" {
+		return rr.To, true
+	}
+	return "", false
+}
+
+// parseRedirects parses the contents of a _redirects file. Blank lines and
+// lines starting with # are ignored.
+func parseRedirects(r io.Reader) ([]redirectRule, error) {
+	var rules []redirectRule
+
+	s := bufio.NewScanner(r)
+	for ln := 1; s.Scan(); ln++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("_redirects line %d: expected '<from> <to> [<status>]'", ln)
+		}
+
+		rule := redirectRule{From: fields[0], To: fields[1], Status: http.StatusMovedPermanently}
+		if !strings.HasPrefix(rule.From, "/") {
+			return nil, fmt.Errorf("_redirects line %d: %q is not an absolute path", ln, rule.From)
+		}
+
+		if len(fields) == 3 {
+			code, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("_redirects line %d: invalid status %q", ln, fields[2])
+			}
+			switch {
+			case code == http.StatusOK, code == http.StatusNotFound:
+			case code >= 300 && code < 400:
+			default:
+				return nil, fmt.Errorf("_redirects line %d: unsupported status %d", ln, code)
+			}
+			rule.Status = code
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, s.Err()
+}
+
+// splitSitePath splits /ipfs/<cid>/a/b or /ipns/<name>/a/b into the site
+// root and the path below it.
+func splitSitePath(urlPath string) (string, string, bool) {
+	segs := path.SplitList(urlPath)
+	if len(segs) < 3 || segs[0] != "" || segs[2] == "" {
+		return "", "", false
+	}
+	return "/" + segs[1] + "/" + segs[2], "/" + path.Join(segs[3:]), true
+}
+
+// serveNotFound handles a request for a missing file in a site, the
+// directory at /ipfs/<cid> or /ipns/<name>. Sites may rewrite or redirect
+// such requests with a _redirects file; failing that, the nearest
+// ipfs-404.html is served. It returns false when neither applies, leaving
+// the response untouched.
+func (i *gatewayHandler) serveNotFound(ctx context.Context, w http.ResponseWriter, r *http.Request, urlPath, prefix string, ipnsHostname bool) bool {
+	root, rel, ok := splitSitePath(urlPath)
+	if !ok {
+		return false
+	}
+
+	// the absolute paths of the redirect targets are below the site root,
+	// which is served at / on its own hostname (see IPNSHostnameOption)
+	base := prefix + root
+	if ipnsHostname {
+		base = prefix
+	}
+	if i.serveRedirect(ctx, w, r, root, rel, base) {
+		return true
+	}
+
+	for dir := gopath.Dir(rel); ; dir = gopath.Dir(dir) {
+		if i.serveFile(ctx, w, r, gopath.Join(root, dir, notFoundFile), http.StatusNotFound) {
+			return true
+		}
+		if dir == "/" {
+			return false
+		}
+	}
+}
+
+// serveRedirect applies the first rule of the site's _redirects file
+// matching rel. Redirects to absolute paths are prefixed with base, the
+// path the site root is served at.
+func (i *gatewayHandler) serveRedirect(ctx context.Context, w http.ResponseWriter, r *http.Request, root, rel, base string) bool {
+	rd, err := i.api.Cat(ctx, root+"/"+redirectsFile)
+	if err != nil {
+		return false
+	}
+	defer rd.Close()
+
+	rules, err := parseRedirects(io.LimitReader(rd, maxRedirectsFileSize))
+	if err != nil {
+		log.Warningf("ignoring %s/%s: %s", root, redirectsFile, err)
+		return false
+	}
+
+	for _, rule := range rules {
+		to, ok := rule.match(rel)
+		if !ok {
+			continue
+		}
+
+		switch rule.Status {
+		case http.StatusOK, http.StatusNotFound:
+			if i.serveFile(ctx, w, r, root+to, rule.Status) {
+				return true
+			}
+			log.Debugf("%s/%s: target %s of %s cannot be served", root, redirectsFile, to, rule.From)
+		default:
+			if strings.HasPrefix(to, "/") {
+				to = base + to
+			}
+			i.addUserHeaders(w)
+			http.Redirect(w, r, to, rule.Status)
+			return true
+		}
+	}
+	return false
+}
+
+// serveFile writes the file at p with the given status, returning false
+// without writing anything if p is not a file.
+func (i *gatewayHandler) serveFile(ctx context.Context, w http.ResponseWriter, r *http.Request, p string, status int) bool {
+	rd, err := i.api.Cat(ctx, p)
+	if err != nil {
+		return false
+	}
+	defer rd.Close()
+
+	i.addUserHeaders(w)
+	w.Header().Set("X-IPFS-Path", p)
+
+	if status == http.StatusOK {
+		http.ServeContent(w, r, gopath.Base(p), time.Now(), rd)
+		return true
+	}
+
+	ctype := mime.TypeByExtension(gopath.Ext(p))
+	if ctype == "" {
+		ctype = "text/html; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.WriteHeader(status)
+	if r.Method != "HEAD" {
+		io.Copy(w, rd)
+	}
+	return true
+}
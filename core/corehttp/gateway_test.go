@@ -12,14 +12,21 @@ import (
 
 	core "github.com/ipfs/go-ipfs/core"
 	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	importer "github.com/ipfs/go-ipfs/importer"
+	chunk "github.com/ipfs/go-ipfs/importer/chunk"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	dag "github.com/ipfs/go-ipfs/merkledag"
+	dagutils "github.com/ipfs/go-ipfs/merkledag/utils"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	path "github.com/ipfs/go-ipfs/path"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+	ft "github.com/ipfs/go-ipfs/unixfs"
 
 	id "gx/ipfs/QmQHmMFyhfp2ZXnbYWqAWhEideDCNDM6hzJwqCU29Y5zV2/go-libp2p/p2p/protocol/identify"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+	peer "gx/ipfs/QmfMmLGoKzCHDN7cGgk64PJr4iipzidDRME8HABSJqvmhC/go-libp2p-peer"
 	ci "gx/ipfs/QmfWDLQjGjVe4fr5CoztYW2DYYjRysMJrFe1RCsXLPTf46/go-libp2p-crypto"
 )
 
@@ -38,7 +45,12 @@ func (m mockNamesys) ResolveN(ctx context.Context, name string, depth int) (valu
 }
 
 func (m mockNamesys) Publish(ctx context.Context, name ci.PrivKey, value path.Path) error {
-	return errors.New("not implemented for mockNamesys")
+	pid, err := peer.IDFromPrivateKey(name)
+	if err != nil {
+		return err
+	}
+	m["/ipns/"+pid.Pretty()] = value
+	return nil
 }
 
 func (m mockNamesys) PublishWithEOL(ctx context.Context, name ci.PrivKey, value path.Path, _ time.Time) error {
@@ -96,6 +108,7 @@ func newTestServerAndNode(t *testing.T, ns mockNamesys) (*httptest.Server, *core
 		t.Fatal(err)
 	}
 	cfg.Gateway.PathPrefixes = []string{"/good-prefix"}
+	cfg.Gateway.SubdomainHosts = []string{"example.org"}
 
 	// need this variable here since we need to construct handler with
 	// listener, and server with handler. yay cycles.
@@ -169,6 +182,350 @@ func TestGatewayGet(t *testing.T) {
 	}
 }
 
+func TestGatewayFormats(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	k, dagn, err := coreunix.AddWrapped(n, strings.NewReader("fnord"), "fnord.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns["/ipns/example.com"] = path.FromString("/ipfs/" + k)
+
+	for _, test := range []struct {
+		path   string
+		accept string
+		status int
+		ctype  string
+	}{
+		{"/ipfs/" + k + "?format=raw", "", http.StatusOK, "application/vnd.ipld.raw"},
+		{"/ipfs/" + k, "application/vnd.ipld.raw", http.StatusOK, "application/vnd.ipld.raw"},
+		{"/ipfs/" + k + "?format=tar", "", http.StatusOK, "application/x-tar"},
+		{"/ipfs/" + k, "text/html, application/x-tar;q=0.9", http.StatusOK, "application/x-tar"},
+		{"/ipfs/" + k + "?format=car", "", http.StatusOK, "application/vnd.ipld.car"},
+		{"/ipns/example.com?format=car", "", http.StatusOK, "application/vnd.ipld.car"},
+		{"/ipfs/" + k + "?format=zip", "", http.StatusBadRequest, ""},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != test.status {
+			t.Fatalf("got %d, expected %d from %s", res.StatusCode, test.status, test.path)
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		if ct := res.Header.Get("Content-Type"); ct != test.ctype {
+			t.Fatalf("got content type %q, expected %q from %s", ct, test.ctype, test.path)
+		}
+
+		switch test.ctype {
+		case "application/vnd.ipld.raw":
+			if string(body) != string(dagn.RawData()) {
+				t.Fatalf("raw block from %s does not match the stored block", test.path)
+			}
+			if cc := res.Header.Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+				t.Fatalf("expected immutable caching for %s, got %q", test.path, cc)
+			}
+		case "application/x-tar":
+			if !strings.Contains(string(body), "fnord.txt") {
+				t.Fatalf("tar from %s does not contain the wrapped file", test.path)
+			}
+		case "application/vnd.ipld.car":
+			if !strings.Contains(string(body), "fnord") {
+				t.Fatalf("car from %s does not contain the file data", test.path)
+			}
+		}
+	}
+}
+
+func TestSubdomainGateway(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	k, err := coreunix.Add(n, strings.NewReader("fnord"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns["/ipns/example.com"] = path.FromString("/ipfs/" + k)
+
+	c, err := cid.Decode(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	label := subdomainLabel(c)
+	if strings.ToLower(label) != label {
+		t.Fatalf("subdomain label %q is not lowercase", label)
+	}
+
+	for _, test := range []struct {
+		host     string
+		path     string
+		status   int
+		text     string
+		location string
+	}{
+		{label + ".ipfs.example.org", "/", http.StatusOK, "fnord", ""},
+		{k + ".ipfs.example.org", "/", http.StatusOK, "fnord", ""},
+		{"example.com.ipns.example.org", "/", http.StatusOK, "fnord", ""},
+		{"example.org", "/ipfs/" + k, http.StatusMovedPermanently, "", "http://" + label + ".ipfs.example.org/"},
+		{"example.org", "/ipfs/" + k + "/a/b?x=y", http.StatusMovedPermanently, "", "http://" + label + ".ipfs.example.org/a/b?x=y"},
+		{"example.org", "/ipns/example.com", http.StatusMovedPermanently, "", "http://example.com.ipns.example.org/"},
+		{"localhost:5001", "/ipfs/" + k, http.StatusOK, "fnord", ""},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = test.host
+
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		urlstr := "http://" + test.host + test.path
+		if res.StatusCode != test.status {
+			t.Fatalf("got %d, expected %d from %s", res.StatusCode, test.status, urlstr)
+		}
+		if test.location != "" {
+			if loc := res.Header.Get("Location"); loc != test.location {
+				t.Fatalf("got location %q, expected %q from %s", loc, test.location, urlstr)
+			}
+			continue
+		}
+		if string(body) != test.text {
+			t.Fatalf("unexpected response body from %s: expected %q; got %q", urlstr, test.text, body)
+		}
+	}
+}
+
+func TestWritableIpnsGateway(t *testing.T) {
+	ns := mockNamesys{}
+	n, err := newNodeWithMockNamesys(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks := keystore.NewMemKeystore()
+	n.Repo.(*repo.Mock).K = ks
+	sk, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("site", sk); err != nil {
+		t.Fatal(err)
+	}
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gateway.WriteTokens = []string{"secret"}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener, GatewayOption(true, "/ipfs", "/ipns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// names have to be published before they can be written to
+	root := ft.EmptyDirNode()
+	if _, err := n.DAG.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	ns["/ipns/"+pid.Pretty()] = path.FromCid(root.Cid())
+
+	do := func(method, p, token, body string) (*http.Response, string) {
+		req, err := http.NewRequest(method, ts.URL+p, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, string(b)
+	}
+
+	if res, _ := do("PUT", "/ipns/site/docs/index.html", "", "hello"); res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected %d without a token, got %d", http.StatusUnauthorized, res.StatusCode)
+	}
+	if res, _ := do("PUT", "/ipns/site/docs/index.html", "wrong", "hello"); res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected %d with a bad token, got %d", http.StatusUnauthorized, res.StatusCode)
+	}
+	if res, _ := do("PUT", "/ipns/other/docs/index.html", "secret", "hello"); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected %d for a name without a local key, got %d", http.StatusForbidden, res.StatusCode)
+	}
+
+	res, _ := do("PUT", "/ipns/site/docs/index.html", "secret", "hello")
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, res.StatusCode)
+	}
+	if loc := res.Header.Get("Location"); loc != "/ipns/site/docs/index.html" {
+		t.Fatalf("unexpected location %q", loc)
+	}
+	if ns["/ipns/"+pid.Pretty()].String() != "/ipfs/"+res.Header.Get("IPFS-Hash") {
+		t.Fatalf("name was not republished: %s", ns["/ipns/"+pid.Pretty()])
+	}
+
+	if res, body := do("GET", "/ipns/"+pid.Pretty()+"/docs/index.html", "", ""); res.StatusCode != http.StatusOK || body != "hello" {
+		t.Fatalf("expected to read back the written file, got %d %q", res.StatusCode, body)
+	}
+
+	if res, _ := do("DELETE", "/ipns/"+pid.Pretty()+"/docs/index.html", "secret", ""); res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, res.StatusCode)
+	}
+	if res, _ := do("GET", "/ipns/"+pid.Pretty()+"/docs/index.html", "", ""); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected deleted file to be gone, got %d", res.StatusCode)
+	}
+}
+
+func TestGatewaySiteNotFoundAndRedirects(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	files := map[string]string{
+		"_redirects":         "# site rules\n/old/* /new/:splat 302\n/app/* /app/index.html 200\n/gone /custom-404.html 404\n",
+		"new/page.html":      "new page",
+		"app/index.html":     "app",
+		"custom-404.html":    "custom",
+		"ipfs-404.html":      "root not found",
+		"docs/ipfs-404.html": "docs not found",
+		"docs/index.html":    "docs",
+	}
+
+	e := dagutils.NewDagEditor(ft.EmptyDirNode(), n.DAG)
+	for p, content := range files {
+		nd, err := importer.BuildDagFromReader(n.DAG, chunk.DefaultSplitter(strings.NewReader(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.InsertNodeAtPath(n.Context(), p, nd, ft.EmptyDirNode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root, err := e.Finalize(n.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := root.Cid().String()
+	ns["/ipns/example.net"] = path.FromString("/ipfs/" + k)
+
+	for _, test := range []struct {
+		host     string
+		path     string
+		status   int
+		text     string
+		location string
+	}{
+		{"example.net", "/old/page.html", http.StatusFound, "", "/new/page.html"},
+		{"example.net", "/new/page.html", http.StatusOK, "new page", ""},
+		{"example.net", "/app/some/route", http.StatusOK, "app", ""},
+		{"example.net", "/gone", http.StatusNotFound, "custom", ""},
+		{"example.net", "/docs/missing/deeper", http.StatusNotFound, "docs not found", ""},
+		{"example.net", "/missing", http.StatusNotFound, "root not found", ""},
+		// the same rules apply below /ipfs/<cid> and /ipns/<name>
+		{"localhost:5001", "/ipfs/" + k + "/old/page.html", http.StatusFound, "", "/ipfs/" + k + "/new/page.html"},
+		{"localhost:5001", "/ipfs/" + k + "/app/some/route", http.StatusOK, "app", ""},
+		{"localhost:5001", "/ipns/example.net/gone", http.StatusNotFound, "custom", ""},
+		{"localhost:5001", "/ipns/example.net/old/page.html", http.StatusFound, "", "/ipns/example.net/new/page.html"},
+		{"localhost:5001", "/ipfs/" + k + "/missing", http.StatusNotFound, "root not found", ""},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = test.host
+
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		urlstr := "http://" + test.host + test.path
+		if res.StatusCode != test.status {
+			t.Fatalf("got %d, expected %d from %s", res.StatusCode, test.status, urlstr)
+		}
+		if test.location != "" {
+			if loc := res.Header.Get("Location"); loc != test.location {
+				t.Fatalf("got location %q, expected %q from %s", loc, test.location, urlstr)
+			}
+			continue
+		}
+		if string(body) != test.text {
+			t.Fatalf("unexpected response body from %s: expected %q; got %q", urlstr, test.text, body)
+		}
+	}
+}
+
+func TestParseRedirects(t *testing.T) {
+	rules, err := parseRedirects(strings.NewReader("\n# comment\n/a /b\n/c/* /d/:splat 307\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(rules))
+	}
+	if rules[0].Status != http.StatusMovedPermanently {
+		t.Fatalf("expected default status 301, got %d", rules[0].Status)
+	}
+	if to, ok := rules[1].match("/c/x/y"); !ok || to != "/d/x/y" {
+		t.Fatalf("splat did not match: %q %v", to, ok)
+	}
+	if _, ok := rules[1].match("/cx"); ok {
+		t.Fatal("splat matched a sibling path")
+	}
+
+	for _, bad := range []string{"/a", "a /b", "/a /b 500", "/a /b c"} {
+		if _, err := parseRedirects(strings.NewReader(bad)); err == nil {
+			t.Fatalf("expected an error parsing %q", bad)
+		}
+	}
+}
+
 func TestIPNSHostnameRedirect(t *testing.T) {
 	ns := mockNamesys{}
 	ts, n := newTestServerAndNode(t, ns)
@@ -1,6 +1,7 @@
 package corehttp
 
 import (
+	"encoding/base32"
 	"net"
 	"net/http"
 	"strings"
@@ -8,19 +9,46 @@ import (
 	"context"
 	"github.com/ipfs/go-ipfs/core"
 	isd "gx/ipfs/QmaeHSCBd9XjXxmgHEiKkHtLcMCb2eZsPLKT7bHgBfBkqw/go-is-domain"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
 // IPNSHostnameOption rewrites an incoming request if its Host: header contains
 // an IPNS name.
 // The rewritten request points at the resolved name on the gateway handler.
+//
+// Hosts of the form <cid>.ipfs.<gateway> and <name>.ipns.<gateway>, where
+// <gateway> is one of Gateway.SubdomainHosts, are rewritten the same way, and
+// path-style requests to <gateway> itself are redirected to such a subdomain,
+// so that every site gets its own origin.
 func IPNSHostnameOption() ServeOption {
 	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		subdomainHosts := cfg.Gateway.SubdomainHosts
+
 		childMux := http.NewServeMux()
 		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			ctx, cancel := context.WithCancel(n.Context())
 			defer cancel()
 
 			host := strings.SplitN(r.Host, ":", 2)[0]
+
+			if prefix, ok := subdomainPath(host, subdomainHosts); ok {
+				r.Header["X-Ipns-Original-Path"] = []string{r.URL.Path}
+				r.URL.Path = prefix + r.URL.Path
+				childMux.ServeHTTP(w, r)
+				return
+			}
+
+			if isSubdomainHost(host, subdomainHosts) {
+				if u, ok := subdomainRedirect(r); ok {
+					http.Redirect(w, r, u, http.StatusMovedPermanently)
+					return
+				}
+			}
+
 			if len(host) > 0 && isd.IsDomain(host) {
 				name := "/ipns/" + host
 				if _, err := n.Namesys.Resolve(ctx, name); err == nil {
@@ -33,3 +61,105 @@ func IPNSHostnameOption() ServeOption {
 		return childMux, nil
 	}
 }
+
+// subdomainBase32 is the lowercase, unpadded base32 used for CIDs in
+// subdomains, which browsers and DNS treat case-insensitively.
+var subdomainBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// subdomainLabel encodes c as a base32 CIDv1 multibase string fit for use as
+// a DNS label.
+func subdomainLabel(c *cid.Cid) string {
+	v1 := cid.NewCidV1(c.Type(), c.Hash())
+	return "b" + strings.ToLower(subdomainBase32.EncodeToString(v1.Bytes()))
+}
+
+// cidFromSubdomainLabel decodes a CID from a subdomain label produced by
+// subdomainLabel, or from any string cid.Decode understands.
+func cidFromSubdomainLabel(label string) (*cid.Cid, error) {
+	if strings.HasPrefix(label, "b") {
+		data, err := subdomainBase32.DecodeString(strings.ToUpper(label[1:]))
+		if err != nil {
+			return nil, err
+		}
+		return cid.Cast(data)
+	}
+	return cid.Decode(label)
+}
+
+func isSubdomainHost(host string, gateways []string) bool {
+	for _, gw := range gateways {
+		if host == gw {
+			return true
+		}
+	}
+	return false
+}
+
+// subdomainPath returns the /ipfs/<cid> or /ipns/<name> path addressed by a
+// subdomain of one of the gateways.
+func subdomainPath(host string, gateways []string) (string, bool) {
+	for _, gw := range gateways {
+		if label := strings.TrimSuffix(host, ".ipfs."+gw); label != host {
+			if label == "" || strings.Contains(label, ".") {
+				return "", false
+			}
+			c, err := cidFromSubdomainLabel(label)
+			if err != nil {
+				return "", false
+			}
+			return ipfsPathPrefix + c.String(), true
+		}
+
+		if label := strings.TrimSuffix(host, ".ipns."+gw); label != host {
+			if label == "" {
+				return "", false
+			}
+			return ipnsPathPrefix + label, true
+		}
+	}
+	return "", false
+}
+
+// subdomainRedirect returns the subdomain URL equivalent to a path-style
+// request for /ipfs/<cid>/... or /ipns/<name>/... on a subdomain gateway.
+func subdomainRedirect(r *http.Request) (string, bool) {
+	parts := strings.SplitN(r.URL.Path, "/", 4)
+	if len(parts) < 3 || parts[0] != "" || parts[2] == "" {
+		return "", false
+	}
+
+	var label string
+	switch parts[1] {
+	case "ipfs":
+		c, err := cid.Decode(parts[2])
+		if err != nil {
+			return "", false
+		}
+		label = subdomainLabel(c)
+	case "ipns":
+		// peer IDs are case sensitive, only DNSLink names survive a
+		// trip through a hostname
+		if !isd.IsDomain(parts[2]) {
+			return "", false
+		}
+		label = parts[2]
+	default:
+		return "", false
+	}
+
+	rest := "/"
+	if len(parts) == 4 {
+		rest += parts[3]
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	u := scheme + "://" + label + "." + parts[1] + "." + r.Host + rest
+	if r.URL.RawQuery != "" {
+		u += "?" + r.URL.RawQuery
+	}
+	return u, true
+}
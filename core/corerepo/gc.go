@@ -3,12 +3,17 @@ package corerepo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
+	bserv "github.com/ipfs/go-ipfs/blockservice"
 	"github.com/ipfs/go-ipfs/core"
-	mfs "github.com/ipfs/go-ipfs/mfs"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
 	gc "github.com/ipfs/go-ipfs/pin/gc"
 	repo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
 
 	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
@@ -19,13 +24,30 @@ var log = logging.Logger("corerepo")
 
 var ErrMaxStorageExceeded = errors.New("Maximum storage limit exceeded. Maybe unpin some files?")
 
+// ErrNoBlockUse is returned when evicting blocks from a node which does not
+// track their use, as it is not in the lru GC mode.
+var ErrNoBlockUse = errors.New("the use of blocks is not tracked, set Datastore.GCMode to \"lru\"")
+
+// ErrNoRefCounts is returned when using the reference count index of a
+// node which does not keep one.
+var ErrNoRefCounts = errors.New("the reference count index is disabled, set Datastore.RefCounts to true")
+
+// lruPeriod is the longest time between two checks of the storage usage in
+// the lru GC mode, as evictions are meant to be small and frequent.
+const lruPeriod = time.Minute
+
+// KeyRemoved is an output of a collection: a removed block, a progress
+// report, or the error the collection stopped on.
 type KeyRemoved struct {
-	Key *cid.Cid
+	Key      *cid.Cid     `json:",omitempty"`
+	Progress *gc.Progress `json:",omitempty"`
+	Error    string       `json:",omitempty"`
 }
 
 type GC struct {
 	Node       *core.IpfsNode
 	Repo       repo.Repo
+	Mode       string
 	StorageMax uint64
 	StorageGC  uint64
 	SlackGB    uint64
@@ -51,6 +73,15 @@ func NewGC(n *core.IpfsNode) (*GC, error) {
 		cfg.Datastore.StorageGCWatermark = 90
 	}
 
+	switch cfg.Datastore.GCMode {
+	case "":
+		cfg.Datastore.GCMode = config.GCModeFull
+	case config.GCModeFull, config.GCModeLRU:
+	default:
+		return nil, fmt.Errorf("unknown GC mode %q, must be %q or %q",
+			cfg.Datastore.GCMode, config.GCModeFull, config.GCModeLRU)
+	}
+
 	storageMax, err := humanize.ParseBytes(cfg.Datastore.StorageMax)
 	if err != nil {
 		return nil, err
@@ -67,62 +98,222 @@ func NewGC(n *core.IpfsNode) (*GC, error) {
 	return &GC{
 		Node:       n,
 		Repo:       r,
+		Mode:       cfg.Datastore.GCMode,
 		StorageMax: storageMax,
 		StorageGC:  storageGC,
 		SlackGB:    slackGB,
 	}, nil
 }
 
-func BestEffortRoots(filesRoot *mfs.Root) ([]*cid.Cid, error) {
-	rootDag, err := filesRoot.GetValue().GetNode()
+// BestEffortRoots returns the roots whose locally available blocks are kept
+// by garbage collection: the files roots and the snapshots of the default
+// one.
+func BestEffortRoots(n *core.IpfsNode) ([]*cid.Cid, error) {
+	froots, err := n.ListFilesRoots()
 	if err != nil {
 		return nil, err
 	}
 
-	return []*cid.Cid{rootDag.Cid()}, nil
+	var roots []*cid.Cid
+	for _, fr := range froots {
+		roots = append(roots, fr.Root)
+	}
+
+	snaps, err := snapshotRoots(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(roots, snaps...), nil
+}
+
+// bestEffortRootsFunc returns the best effort roots of n as a gc.RootsFunc,
+// as the files roots may change during a collection.
+func bestEffortRootsFunc(n *core.IpfsNode) gc.RootsFunc {
+	return func() ([]*cid.Cid, error) {
+		return BestEffortRoots(n)
+	}
+}
+
+// gcLinks returns the link service collections of n mark blocks through.
+// When the use of blocks is tracked, reading them to mark them does not
+// count as using them.
+func gcLinks(n *core.IpfsNode) dag.LinkService {
+	if n.BlockUse == nil {
+		return n.DAG
+	}
+	bs := n.BlockUse.Untracked()
+	return dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
 }
 
 func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel() // in case error occurs during operation
-	roots, err := BestEffortRoots(n.FilesRoot)
-	if err != nil {
-		return err
+
+	opts := gc.Options{Datastore: n.Repo.Datastore()}
+	for r := range gc.Collect(ctx, n.Blockstore, gcLinks(n), n.Pinning, bestEffortRootsFunc(n), opts) {
+		if r.Error != nil {
+			return r.Error
+		}
 	}
-	rmed, err := gc.GC(ctx, n.Blockstore, n.DAG, n.Pinning, roots)
+	return ctx.Err()
+}
+
+// GarbageCollectAsync runs a collection with the default options, and
+// sends the removed keys on the returned channel.
+func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) (<-chan *KeyRemoved, error) {
+	rmed, err := GarbageCollectWithOptions(n, ctx, gc.Options{})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for {
-		select {
-		case _, ok := <-rmed:
-			if !ok {
-				return nil
+	out := make(chan *KeyRemoved)
+	go func() {
+		defer close(out)
+		for r := range rmed {
+			if r.Progress != nil {
+				continue
+			}
+			select {
+			case out <- r:
+			case <-ctx.Done():
+				return
 			}
-		case <-ctx.Done():
-			return ctx.Err()
 		}
+	}()
+	return out, nil
+}
+
+// GarbageCollectWithOptions runs a collection with the given options, and
+// sends the removed keys, the progress reports and the error it stops on,
+// if any, on the returned channel. The marked set is kept in the repo
+// datastore unless opts gives another one.
+func GarbageCollectWithOptions(n *core.IpfsNode, ctx context.Context, opts gc.Options) (<-chan *KeyRemoved, error) {
+	if opts.Datastore == nil {
+		opts.Datastore = n.Repo.Datastore()
 	}
+	results := gc.Collect(ctx, n.Blockstore, gcLinks(n), n.Pinning, bestEffortRootsFunc(n), opts)
+
+	out := make(chan *KeyRemoved)
+	go func() {
+		defer close(out)
+		for r := range results {
+			kr := &KeyRemoved{Key: r.KeyRemoved, Progress: r.Progress}
+			if r.Error != nil {
+				kr.Error = r.Error.Error()
+			}
 
+			select {
+			case out <- kr:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
 }
 
-func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) (<-chan *KeyRemoved, error) {
-	roots, err := BestEffortRoots(n.FilesRoot)
+// GarbageCollectReleased runs a collection with the given options, going
+// only through the blocks released by the removal of recursive pins since
+// the last one, as recorded by the reference count index. Once it went
+// through all of them, they are forgotten.
+func GarbageCollectReleased(n *core.IpfsNode, ctx context.Context, opts gc.Options) (<-chan *KeyRemoved, error) {
+	refs := n.Pinning.RefCounts()
+	if refs == nil {
+		return nil, ErrNoRefCounts
+	}
+	if !refs.Valid() {
+		return nil, pin.ErrRefCountsInvalid
+	}
+
+	// the keys listed are recorded, and only read once the sweep went
+	// through all of them
+	var listed []*cid.Cid
+	opts.Keys = func(ctx context.Context) (<-chan *cid.Cid, error) {
+		keys, err := refs.Released(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make(chan *cid.Cid)
+		go func() {
+			defer close(out)
+			for k := range keys {
+				listed = append(listed, k)
+				select {
+				case out <- k:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+
+	results, err := GarbageCollectWithOptions(n, ctx, opts)
 	if err != nil {
 		return nil, err
 	}
-	rmed, err := gc.GC(ctx, n.Blockstore, n.DAG, n.Pinning, roots)
+
+	out := make(chan *KeyRemoved)
+	go func() {
+		defer close(out)
+		complete := true
+		for r := range results {
+			if r.Error != "" || (r.Progress != nil && r.Progress.BudgetExhausted) {
+				complete = false
+			}
+			select {
+			case out <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if !complete || ctx.Err() != nil {
+			return
+		}
+		if err := refs.Forget(listed); err != nil {
+			select {
+			case out <- &KeyRemoved{Error: err.Error()}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out, nil
+}
+
+// RebuildRefCounts rebuilds the reference count index of n from its
+// recursive pins, which must not change meanwhile.
+func RebuildRefCounts(n *core.IpfsNode, ctx context.Context) error {
+	refs := n.Pinning.RefCounts()
+	if refs == nil {
+		return ErrNoRefCounts
+	}
+	return refs.Rebuild(ctx, n.DAG, n.Pinning.RecursiveKeys())
+}
+
+// GarbageCollectDryRun sends the keys of the blocks a collection would
+// remove on the returned channel, followed by a progress report of their
+// count and size, without removing them.
+func GarbageCollectDryRun(n *core.IpfsNode, ctx context.Context) (<-chan *KeyRemoved, error) {
+	roots, err := BestEffortRoots(n)
 	if err != nil {
 		return nil, err
 	}
+	results := gc.DryRun(ctx, n.Blockstore, gcLinks(n), n.Pinning, roots)
 
 	out := make(chan *KeyRemoved)
 	go func() {
 		defer close(out)
-		for k := range rmed {
+		for r := range results {
+			kr := &KeyRemoved{Key: r.KeyRemoved, Progress: r.Progress}
+			if r.Error != nil {
+				kr.Error = r.Error.Error()
+			}
+
 			select {
-			case out <- &KeyRemoved{k}:
+			case out <- kr:
 			case <-ctx.Done():
 				return
 			}
@@ -131,6 +322,49 @@ func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) (<-chan *KeyRemo
 	return out, nil
 }
 
+// Evict removes the least recently used blocks that a collection would
+// remove, until their total size reaches size, and returns the final
+// progress of the collection. The node must track the use of its blocks.
+// Like any collection, it waits for the one running, if any, to be done.
+//
+// Like a full collection, an eviction first marks all the blocks to keep,
+// however few it removes. With a valid reference count index, see
+// Datastore.RefCounts, the recursive pins are not walked for it.
+func Evict(n *core.IpfsNode, ctx context.Context, size uint64) (*gc.Progress, error) {
+	if n.BlockUse == nil {
+		return nil, ErrNoBlockUse
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opts := gc.Options{
+		Datastore: n.Repo.Datastore(),
+		MaxBytes:  size,
+		Keys:      n.BlockUse.LeastRecentlyUsed,
+	}
+	var last *gc.Progress
+	for r := range gc.Collect(ctx, n.Blockstore, gcLinks(n), n.Pinning, bestEffortRootsFunc(n), opts) {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if r.Progress != nil {
+			last = r.Progress
+		}
+	}
+	return last, ctx.Err()
+}
+
+// Why returns the roots keeping the block c from being collected, with the
+// path from each of them to it.
+func Why(n *core.IpfsNode, ctx context.Context, c *cid.Cid) ([]gc.Reason, error) {
+	roots, err := BestEffortRoots(n)
+	if err != nil {
+		return nil, err
+	}
+	return gc.Why(ctx, n.Pinning, gcLinks(n), roots, c)
+}
+
 func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
 	cfg, err := node.Repo.Config()
 	if err != nil {
@@ -155,6 +389,13 @@ func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
 		return err
 	}
 
+	if gc.Mode == config.GCModeLRU && period > lruPeriod {
+		period = lruPeriod
+		if !cfg.Datastore.RefCounts {
+			log.Warning("each eviction walks all the recursive pins, set Datastore.RefCounts to true to avoid it")
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -187,6 +428,10 @@ func (gc *GC) maybeGC(ctx context.Context, offset uint64) error {
 			log.Warningf("pre-GC: %s", ErrMaxStorageExceeded)
 		}
 
+		if gc.Mode == config.GCModeLRU {
+			return gc.evict(ctx, storage+offset-gc.StorageGC)
+		}
+
 		// Do GC here
 		log.Info("Watermark exceeded. Starting repo GC...")
 		defer log.EventBegin(ctx, "repoGC").Done()
@@ -198,3 +443,17 @@ func (gc *GC) maybeGC(ctx context.Context, offset uint64) error {
 	}
 	return nil
 }
+
+// evict evicts the least recently used blocks to free size bytes, getting
+// back under the watermark.
+func (gc *GC) evict(ctx context.Context, size uint64) error {
+	log.Infof("Watermark exceeded. Evicting %s of least recently used blocks...", humanize.Bytes(size))
+	defer log.EventBegin(ctx, "repoEvict").Done()
+
+	p, err := Evict(gc.Node, ctx, size)
+	if err != nil {
+		return err
+	}
+	log.Infof("Evicted %d blocks, %s.", p.Removed, humanize.Bytes(p.RemovedBytes))
+	return nil
+}
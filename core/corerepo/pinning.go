@@ -24,7 +24,7 @@ import (
 	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
-func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
+func resolvePins(ctx context.Context, n *core.IpfsNode, paths []string) ([]node.Node, error) {
 	dagnodes := make([]node.Node, 0)
 	for _, fpath := range paths {
 		p, err := path.ParsePath(fpath)
@@ -38,6 +38,14 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 		}
 		dagnodes = append(dagnodes, dagnode)
 	}
+	return dagnodes, nil
+}
+
+func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
+	dagnodes, err := resolvePins(ctx, n, paths)
+	if err != nil {
+		return nil, err
+	}
 
 	var out []*cid.Cid
 	for _, dagnode := range dagnodes {
@@ -52,7 +60,7 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 		out = append(out, c)
 	}
 
-	err := n.Pinning.Flush()
+	err = n.Pinning.Flush()
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +68,28 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 	return out, nil
 }
 
+// PinToDepth pins the objects at the given paths along with their
+// descendants down to depth.
+func PinToDepth(n *core.IpfsNode, ctx context.Context, paths []string, depth int) ([]*cid.Cid, error) {
+	dagnodes, err := resolvePins(ctx, n, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*cid.Cid
+	for _, dagnode := range dagnodes {
+		if err := n.Pinning.PinToDepth(ctx, dagnode, depth); err != nil {
+			return nil, fmt.Errorf("pin: %s", err)
+		}
+		out = append(out, dagnode.Cid())
+	}
+
+	if err := n.Pinning.Flush(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
 
 	var unpinned []*cid.Cid
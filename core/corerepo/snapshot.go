@@ -0,0 +1,377 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// snapshotsKey is the datastore key under which the snapshots of the
+// files root are recorded.
+var snapshotsKey = ds.NewKey("/local/filesroot-snapshots")
+
+// snapshotTimeFormat is used in the names of automatic snapshots.
+const snapshotTimeFormat = "2006-01-02T15:04:05Z"
+
+var (
+	ErrSnapshotExists   = errors.New("a snapshot with that name already exists")
+	ErrSnapshotNotFound = errors.New("no snapshot with that name")
+)
+
+// snapshotLock serializes updates of the snapshot record.
+var snapshotLock sync.Mutex
+
+// Snapshot is a named record of a past files root.
+type Snapshot struct {
+	Name    string
+	Root    *cid.Cid
+	Created time.Time
+
+	// Period is set on automatic snapshots to the period of the policy
+	// that took them: "hourly", "daily" or "weekly".
+	Period string
+}
+
+// snapshotRecord is the datastore encoding of a Snapshot.
+type snapshotRecord struct {
+	Name    string
+	Root    string
+	Created time.Time
+	Period  string `json:",omitempty"`
+}
+
+func validSnapshotName(name string) error {
+	if name == "" || strings.ContainsAny(name, "/ \t\n") {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return nil
+}
+
+// loadSnapshots reads all snapshots, oldest first.
+func loadSnapshots(n *core.IpfsNode) ([]*Snapshot, error) {
+	val, err := n.Repo.Datastore().Get(snapshotsKey)
+	switch {
+	case err == ds.ErrNotFound:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	data, ok := val.([]byte)
+	if !ok {
+		return nil, errors.New("invalid snapshot record in datastore")
+	}
+
+	var recs []snapshotRecord
+	if err := json.Unmarshal(data, &recs); err != nil {
+		return nil, fmt.Errorf("invalid snapshot record in datastore: %s", err)
+	}
+
+	out := make([]*Snapshot, 0, len(recs))
+	for _, rec := range recs {
+		c, err := cid.Decode(rec.Root)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %s: %s", rec.Name, err)
+		}
+		out = append(out, &Snapshot{
+			Name:    rec.Name,
+			Root:    c,
+			Created: rec.Created,
+			Period:  rec.Period,
+		})
+	}
+
+	sort.Stable(snapshotsByTime(out))
+	return out, nil
+}
+
+func storeSnapshots(n *core.IpfsNode, snaps []*Snapshot) error {
+	recs := make([]snapshotRecord, 0, len(snaps))
+	for _, s := range snaps {
+		recs = append(recs, snapshotRecord{
+			Name:    s.Name,
+			Root:    s.Root.String(),
+			Created: s.Created,
+			Period:  s.Period,
+		})
+	}
+
+	data, err := json.Marshal(recs)
+	if err != nil {
+		return err
+	}
+	return n.Repo.Datastore().Put(snapshotsKey, data)
+}
+
+type snapshotsByTime []*Snapshot
+
+func (s snapshotsByTime) Len() int           { return len(s) }
+func (s snapshotsByTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s snapshotsByTime) Less(i, j int) bool { return s[i].Created.Before(s[j].Created) }
+
+func findSnapshot(snaps []*Snapshot, name string) int {
+	for i, s := range snaps {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// CreateSnapshot records the current files root under name.
+func CreateSnapshot(n *core.IpfsNode, name string) (*Snapshot, error) {
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+	return createSnapshot(n, name, "", time.Now())
+}
+
+func createSnapshot(n *core.IpfsNode, name, period string, now time.Time) (*Snapshot, error) {
+	if err := validSnapshotName(name); err != nil {
+		return nil, err
+	}
+
+	snaps, err := loadSnapshots(n)
+	if err != nil {
+		return nil, err
+	}
+	if findSnapshot(snaps, name) >= 0 {
+		return nil, ErrSnapshotExists
+	}
+
+	root, err := n.GetFilesRoot(core.DefaultFilesRoot)
+	if err != nil {
+		return nil, err
+	}
+	if err := root.Flush(); err != nil {
+		return nil, err
+	}
+
+	nd, err := root.GetValue().GetNode()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{
+		Name:    name,
+		Root:    nd.Cid(),
+		Created: now.UTC(),
+		Period:  period,
+	}
+
+	if err := storeSnapshots(n, append(snaps, s)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetSnapshot returns the snapshot called name.
+func GetSnapshot(n *core.IpfsNode, name string) (*Snapshot, error) {
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+	return getSnapshot(n, name)
+}
+
+func getSnapshot(n *core.IpfsNode, name string) (*Snapshot, error) {
+	snaps, err := loadSnapshots(n)
+	if err != nil {
+		return nil, err
+	}
+
+	i := findSnapshot(snaps, name)
+	if i < 0 {
+		return nil, ErrSnapshotNotFound
+	}
+	return snaps[i], nil
+}
+
+// ListSnapshots returns all snapshots, oldest first.
+func ListSnapshots(n *core.IpfsNode) ([]*Snapshot, error) {
+	return loadSnapshots(n)
+}
+
+// RemoveSnapshot deletes the snapshot called name. Its content becomes
+// eligible for garbage collection unless referenced elsewhere.
+func RemoveSnapshot(n *core.IpfsNode, name string) error {
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+	return removeSnapshots(n, name)
+}
+
+func removeSnapshots(n *core.IpfsNode, names ...string) error {
+	snaps, err := loadSnapshots(n)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		i := findSnapshot(snaps, name)
+		if i < 0 {
+			return ErrSnapshotNotFound
+		}
+		snaps = append(snaps[:i], snaps[i+1:]...)
+	}
+	return storeSnapshots(n, snaps)
+}
+
+// RestoreSnapshot makes the snapshot called name the files root again. The
+// root being replaced is itself recorded as a snapshot first, so that a
+// restore can always be undone; that snapshot is returned.
+func RestoreSnapshot(ctx context.Context, n *core.IpfsNode, name string) (*Snapshot, error) {
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+
+	s, err := getSnapshot(n, name)
+	if err != nil {
+		return nil, err
+	}
+
+	nd, err := n.DAG.Get(ctx, s.Root)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %s", name, err)
+	}
+
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil, dag.ErrNotProtobuf
+	}
+
+	now := time.Now()
+	prev, err := createSnapshot(n, "pre-restore-"+now.UTC().Format(snapshotTimeFormat), "", now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := n.SetFilesRoot(pbnd); err != nil {
+		return nil, err
+	}
+	return prev, nil
+}
+
+// snapshotRoots returns the roots of all snapshots, so that garbage
+// collection keeps them around.
+func snapshotRoots(n *core.IpfsNode) ([]*cid.Cid, error) {
+	snaps, err := loadSnapshots(n)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := make([]*cid.Cid, 0, len(snaps))
+	for _, s := range snaps {
+		roots = append(roots, s.Root)
+	}
+	return roots, nil
+}
+
+// snapshotPeriods lists the periods of the automatic snapshot policy.
+var snapshotPeriods = []struct {
+	Name   string
+	Length time.Duration
+	Keep   func(config.SnapshotPolicy) int
+}{
+	{"hourly", time.Hour, func(p config.SnapshotPolicy) int { return p.Hourly }},
+	{"daily", 24 * time.Hour, func(p config.SnapshotPolicy) int { return p.Daily }},
+	{"weekly", 7 * 24 * time.Hour, func(p config.SnapshotPolicy) int { return p.Weekly }},
+}
+
+// PeriodicSnapshots takes automatic snapshots of the files root according
+// to the Files.Snapshots config until ctx is done. It returns right away if
+// the policy is disabled.
+func PeriodicSnapshots(ctx context.Context, node *core.IpfsNode) error {
+	cfg, err := node.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	policy := cfg.Files.Snapshots
+	if policy.Hourly <= 0 && policy.Daily <= 0 && policy.Weekly <= 0 {
+		return nil
+	}
+
+	for {
+		if err := applySnapshotPolicy(node, policy, time.Now()); err != nil {
+			log.Error("automatic snapshot failed: ", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Minute):
+		}
+	}
+}
+
+// applySnapshotPolicy takes, for every period enabled in policy, a
+// snapshot if none was taken in the current period and the root changed
+// since the last one, and drops the snapshots exceeding the period's count.
+func applySnapshotPolicy(n *core.IpfsNode, policy config.SnapshotPolicy, now time.Time) error {
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+
+	snaps, err := loadSnapshots(n)
+	if err != nil {
+		return err
+	}
+
+	root, err := n.GetFilesRoot(core.DefaultFilesRoot)
+	if err != nil {
+		return err
+	}
+	nd, err := root.GetValue().GetNode()
+	if err != nil {
+		return err
+	}
+	current := nd.Cid()
+
+	for _, period := range snapshotPeriods {
+		keep := period.Keep(policy)
+		if keep <= 0 {
+			continue
+		}
+
+		var taken []*Snapshot
+		for _, s := range snaps {
+			if s.Period == period.Name {
+				taken = append(taken, s)
+			}
+		}
+
+		if len(taken) == 0 || needsSnapshot(taken[len(taken)-1], current, period.Length, now) {
+			name := period.Name + "-" + now.UTC().Format(snapshotTimeFormat)
+			s, err := createSnapshot(n, name, period.Name, now)
+			if err != nil {
+				return err
+			}
+			taken = append(taken, s)
+		}
+
+		if len(taken) > keep {
+			var drop []string
+			for _, s := range taken[:len(taken)-keep] {
+				drop = append(drop, s.Name)
+			}
+			if err := removeSnapshots(n, drop...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func needsSnapshot(last *Snapshot, current *cid.Cid, period time.Duration, now time.Time) bool {
+	if last.Root.Equals(current) {
+		return false
+	}
+	return !now.UTC().Truncate(period).Equal(last.Created.Truncate(period))
+}
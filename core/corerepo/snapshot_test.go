@@ -0,0 +1,133 @@
+package corerepo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+func mkdir(t *testing.T, n *core.IpfsNode, p string) {
+	if err := mfs.Mkdir(n.FilesRoot, p, false, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n, err := core.NewNode(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	mkdir(t, n, "/keep")
+	s, err := CreateSnapshot(n, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateSnapshot(n, "first"); err != ErrSnapshotExists {
+		t.Fatalf("expected ErrSnapshotExists, got %v", err)
+	}
+	if _, err := CreateSnapshot(n, "a/b"); err == nil {
+		t.Fatal("expected an error for a name containing a slash")
+	}
+
+	if err := n.FilesRoot.GetValue().(*mfs.Directory).Unlink("keep"); err != nil {
+		t.Fatal(err)
+	}
+	mkdir(t, n, "/other")
+
+	roots, err := BestEffortRoots(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 || !roots[1].Equals(s.Root) {
+		t.Fatalf("snapshot root missing from gc roots: %v", roots)
+	}
+
+	prev, err := RestoreSnapshot(ctx, n, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mfs.Lookup(n.FilesRoot, "/keep"); err != nil {
+		t.Fatal("restored root is missing /keep: ", err)
+	}
+	if _, err := mfs.Lookup(n.FilesRoot, "/other"); err == nil {
+		t.Fatal("restored root still has /other")
+	}
+
+	// the replaced root must be recoverable
+	if _, err := RestoreSnapshot(ctx, n, prev.Name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Lookup(n.FilesRoot, "/other"); err != nil {
+		t.Fatal("undoing the restore lost /other: ", err)
+	}
+
+	if err := RemoveSnapshot(n, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveSnapshot(n, "first"); err != ErrSnapshotNotFound {
+		t.Fatalf("expected ErrSnapshotNotFound, got %v", err)
+	}
+}
+
+func TestSnapshotPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n, err := core.NewNode(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	policy := config.SnapshotPolicy{Hourly: 2, Daily: 1}
+	now := time.Date(2017, 3, 1, 10, 30, 0, 0, time.UTC)
+
+	count := func(period string) int {
+		snaps, err := ListSnapshots(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := 0
+		for _, s := range snaps {
+			if s.Period == period {
+				c++
+			}
+		}
+		return c
+	}
+
+	for i, dir := range []string{"/a", "/b", "/c"} {
+		mkdir(t, n, dir)
+		if err := applySnapshotPolicy(n, policy, now.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if c := count("hourly"); c != 2 {
+		t.Fatalf("expected 2 hourly snapshots, got %d", c)
+	}
+	if c := count("daily"); c != 1 {
+		t.Fatalf("expected 1 daily snapshot, got %d", c)
+	}
+
+	// nothing changed, so no new snapshot is taken
+	snaps, _ := ListSnapshots(n)
+	if err := applySnapshotPolicy(n, policy, now.Add(5*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := ListSnapshots(n)
+	if len(after) != len(snaps) {
+		t.Fatalf("unchanged root was snapshotted again")
+	}
+}
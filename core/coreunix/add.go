@@ -68,13 +68,7 @@ type AddedObject struct {
 }
 
 func NewAdder(ctx context.Context, p pin.Pinner, bs bstore.GCBlockstore, ds dag.DAGService) (*Adder, error) {
-	mr, err := mfs.NewRoot(ctx, ds, unixfs.EmptyDirNode(), nil)
-	if err != nil {
-		return nil, err
-	}
-
 	return &Adder{
-		mr:         mr,
 		ctx:        ctx,
 		pinning:    p,
 		blockstore: bs,
@@ -104,12 +98,45 @@ type Adder struct {
 	Silent     bool
 	Wrap       bool
 	Chunker    string
-	root       node.Node
-	mr         *mfs.Root
-	unlocker   bs.Unlocker
-	tempRoot   *cid.Cid
+
+	// PreserveMode and PreserveMtime record the permission bits and
+	// modification times of added files in their unixfs nodes. They are
+	// off by default, as they change the resulting hashes.
+	PreserveMode  bool
+	PreserveMtime bool
+
+	// Prefix sets the CID version and hash function of the added nodes,
+	// CIDv0 with sha2-256 if nil
+	Prefix *cid.Prefix
+
+	// InlineLimit is the size up to which nodes are inlined in their cid
+	// with the identity hash. Zero disables inlining.
+	InlineLimit int
+
+	root     node.Node
+	mr       *mfs.Root
+	unlocker bs.Unlocker
+	tempRoot *cid.Cid
+}
+
+func (adder *Adder) mfsRoot() (*mfs.Root, error) {
+	if adder.mr != nil {
+		return adder.mr, nil
+	}
+
+	rnode := unixfs.EmptyDirNode()
+	rnode.SetPrefix(adder.Prefix)
+	rnode.SetInlineLimit(adder.InlineLimit)
+	mr, err := mfs.NewRoot(adder.ctx, adder.dagService, rnode, nil)
+	if err != nil {
+		return nil, err
+	}
+	adder.mr = mr
+	return mr, nil
 }
 
+// SetMfsRoot sets the mfs root the added files are put in. Its root
+// directory should use the prefix of the adder.
 func (adder *Adder) SetMfsRoot(r *mfs.Root) {
 	adder.mr = r
 }
@@ -121,9 +148,11 @@ func (adder Adder) add(reader io.Reader) (node.Node, error) {
 		return nil, err
 	}
 	params := ihelper.DagBuilderParams{
-		Dagserv:   adder.dagService,
-		RawLeaves: adder.RawLeaves,
-		Maxlinks:  ihelper.DefaultLinksPerBlock,
+		Dagserv:     adder.dagService,
+		RawLeaves:   adder.RawLeaves,
+		Maxlinks:    ihelper.DefaultLinksPerBlock,
+		Prefix:      adder.Prefix,
+		InlineLimit: adder.InlineLimit,
 	}
 
 	if adder.Trickle {
@@ -139,7 +168,11 @@ func (adder *Adder) RootNode() (node.Node, error) {
 		return adder.root, nil
 	}
 
-	root, err := adder.mr.GetValue().GetNode()
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return nil, err
+	}
+	root, err := mr.GetValue().GetNode()
 	if err != nil {
 		return nil, err
 	}
@@ -185,7 +218,11 @@ func (adder *Adder) PinRoot() error {
 }
 
 func (adder *Adder) Finalize() (node.Node, error) {
-	root := adder.mr.GetValue()
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return nil, err
+	}
+	root := mr.GetValue()
 
 	// cant just call adder.RootNode() here as we need the name for printing
 	rootNode, err := root.GetNode()
@@ -197,7 +234,7 @@ func (adder *Adder) Finalize() (node.Node, error) {
 	if !adder.Wrap {
 		name = rootNode.Links()[0].Name
 
-		dir, ok := adder.mr.GetValue().(*mfs.Directory)
+		dir, ok := mr.GetValue().(*mfs.Directory)
 		if !ok {
 			return nil, fmt.Errorf("root is not a directory")
 		}
@@ -213,7 +250,7 @@ func (adder *Adder) Finalize() (node.Node, error) {
 		return nil, err
 	}
 
-	err = adder.mr.Close()
+	err = mr.Close()
 	if err != nil {
 		return nil, err
 	}
@@ -342,14 +379,18 @@ func (adder *Adder) addNode(node node.Node, path string) error {
 		path = node.Cid().String()
 	}
 
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return err
+	}
 	dir := gopath.Dir(path)
 	if dir != "." {
-		if err := mfs.Mkdir(adder.mr, dir, true, false); err != nil {
+		if err := mfs.Mkdir(mr, dir, true, false); err != nil {
 			return err
 		}
 	}
 
-	if err := mfs.PutNode(adder.mr, path, node); err != nil {
+	if err := mfs.PutNode(mr, path, node); err != nil {
 		return err
 	}
 
@@ -391,6 +432,8 @@ func (adder *Adder) addFile(file files.File) error {
 		}
 
 		dagnode := dag.NodeWithData(sdata)
+		dagnode.SetPrefix(adder.Prefix)
+		dagnode.SetInlineLimit(adder.InlineLimit)
 		_, err = adder.dagService.Add(dagnode)
 		if err != nil {
 			return err
@@ -417,6 +460,13 @@ func (adder *Adder) addFile(file files.File) error {
 		return err
 	}
 
+	if meta := adder.metadata(file); !meta.IsZero() {
+		dagnode, err = adder.setFileMetadata(dagnode, meta)
+		if err != nil {
+			return err
+		}
+	}
+
 	// patch it into the root
 	return adder.addNode(dagnode, file.FileName())
 }
@@ -424,11 +474,30 @@ func (adder *Adder) addFile(file files.File) error {
 func (adder *Adder) addDir(dir files.File) error {
 	log.Infof("adding directory: %s", dir.FileName())
 
-	err := mfs.Mkdir(adder.mr, dir.FileName(), true, false)
+	mr, err := adder.mfsRoot()
+	if err != nil {
+		return err
+	}
+	err = mfs.Mkdir(mr, dir.FileName(), true, false)
 	if err != nil {
 		return err
 	}
 
+	if meta := adder.metadata(dir); !meta.IsZero() {
+		fsn, err := mfs.Lookup(mr, dir.FileName())
+		if err != nil {
+			return err
+		}
+		if meta.HasMode {
+			if err := mfs.SetMode(fsn, meta.Mode); err != nil {
+				return err
+			}
+		}
+		if err := mfs.SetModTime(fsn, meta.ModTime); err != nil {
+			return err
+		}
+	}
+
 	for {
 		file, err := dir.NextFile()
 		if err != nil && err != io.EOF {
@@ -452,6 +521,50 @@ func (adder *Adder) addDir(dir files.File) error {
 	return nil
 }
 
+// metadata returns the mode and modification time of file to record, as
+// selected by PreserveMode and PreserveMtime.
+func (adder *Adder) metadata(file files.File) unixfs.UnixMeta {
+	var meta unixfs.UnixMeta
+	if !adder.PreserveMode && !adder.PreserveMtime {
+		return meta
+	}
+
+	mode, mtime, ok := files.ModeAndTime(file)
+	if !ok {
+		return meta
+	}
+
+	if adder.PreserveMode {
+		meta.Mode, meta.HasMode = mode, true
+	}
+	if adder.PreserveMtime {
+		meta.ModTime = mtime
+	}
+	return meta
+}
+
+// setFileMetadata returns a copy of the root of a file with the given mode
+// and modification time.
+func (adder *Adder) setFileMetadata(nd node.Node, meta unixfs.UnixMeta) (node.Node, error) {
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		log.Warningf("cannot record mode and mtime in raw node %s", nd.Cid())
+		return nd, nil
+	}
+
+	data, err := unixfs.WithUnixMeta(pbnd.Data(), meta)
+	if err != nil {
+		return nil, err
+	}
+
+	pbnd = pbnd.Copy().(*dag.ProtoNode)
+	pbnd.SetData(data)
+	if _, err := adder.dagService.Add(pbnd); err != nil {
+		return nil, err
+	}
+	return pbnd, nil
+}
+
 func (adder *Adder) maybePauseForGC() error {
 	if adder.unlocker != nil && adder.blockstore.GCRequested() {
 		err := adder.PinRoot()
@@ -21,6 +21,7 @@ import (
 	"github.com/ipfs/go-ipfs/repo/config"
 	pi "github.com/ipfs/go-ipfs/thirdparty/posinfo"
 	"github.com/ipfs/go-ipfs/thirdparty/testutil"
+	"github.com/ipfs/go-ipfs/unixfs"
 
 	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
@@ -275,3 +276,160 @@ func (fi *dummyFileInfo) Mode() os.FileMode  { return 0 }
 func (fi *dummyFileInfo) ModTime() time.Time { return fi.modTime }
 func (fi *dummyFileInfo) IsDir() bool        { return false }
 func (fi *dummyFileInfo) Sys() interface{}   { return nil }
+
+func TestAddPreserveMetadata(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: "Qmfoo", // required by offline node
+			},
+		},
+		D: testutil.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "ipfs-add-metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fpath := dir + "/tool"
+	if err := ioutil.WriteFile(fpath, []byte("#!/bin/sh\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1490000000, 0)
+	if err := os.Chmod(fpath, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(fpath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := files.NewSerialFile("bin", dir, false, stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.PreserveMode = true
+	adder.PreserveMtime = true
+
+	if err := adder.AddFile(file); err != nil {
+		t.Fatal(err)
+	}
+	root, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootpb, err := unixfs.FromBytes(root.(*dag.ProtoNode).Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := unixfs.GetUnixMeta(rootpb); !m.HasMode || m.Mode != stat.Mode().Perm() {
+		t.Fatalf("directory mode %o was not preserved, got %o", stat.Mode().Perm(), m.Mode)
+	}
+
+	child, err := root.Links()[0].GetNode(context.Background(), node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	childpb, err := unixfs.FromBytes(child.(*dag.ProtoNode).Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := unixfs.GetUnixMeta(childpb)
+	if m.Mode != 0750 || !m.ModTime.Equal(mtime) {
+		t.Fatalf("file has mode %o and mtime %s", m.Mode, m.ModTime)
+	}
+}
+
+func TestAddInline(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: "Qmfoo", // required by offline node
+			},
+		},
+		D: testutil.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "ipfs-add-inline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.Mkdir(dir+"/empty", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dir+"/small", []byte("tiny"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dir+"/large", bytes.Repeat([]byte("a"), 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := files.NewSerialFile("dir", dir, false, stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.InlineLimit = 32
+
+	if err := adder.AddFile(file); err != nil {
+		t.Fatal(err)
+	}
+	root, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inlined := map[string]bool{"empty": true, "small": true, "large": false}
+	for _, lnk := range root.Links() {
+		if blockstore.IsIdentity(lnk.Cid) != inlined[lnk.Name] {
+			t.Fatalf("%s: expected inlined to be %t", lnk.Name, inlined[lnk.Name])
+		}
+
+		nd, err := lnk.GetNode(context.Background(), node.DAG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !nd.Cid().Equals(lnk.Cid) {
+			t.Fatalf("%s: node read back has a different cid", lnk.Name)
+		}
+	}
+
+	keys, err := node.Blockstore.AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := range keys {
+		if blockstore.IsIdentity(k) {
+			t.Fatalf("inlined node %s was stored", k)
+		}
+	}
+}
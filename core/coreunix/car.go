@@ -0,0 +1,50 @@
+package coreunix
+
+import (
+	"context"
+	"io"
+
+	car "github.com/ipfs/go-ipfs/car"
+	core "github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// ExportCar writes the DAG under root to w as a CAR archive. If sel is not
+// nil, only the part of the DAG it selects is written.
+func ExportCar(ctx context.Context, n *core.IpfsNode, root *cid.Cid, sel *dag.Selector, w io.Writer) error {
+	return car.WriteCarSelected(ctx, n.DAG, root, sel, w)
+}
+
+// ImportCar adds the blocks of the CAR archive read from r to the node, and
+// returns the roots named by the archive. If pin is true, the roots are
+// pinned recursively, which requires the archive to hold their whole DAGs
+// when the node is offline.
+func ImportCar(ctx context.Context, n *core.IpfsNode, r io.Reader, pin bool) ([]*cid.Cid, error) {
+	defer n.Blockstore.PinLock().Unlock()
+
+	roots, err := car.LoadCar(n.DAG, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !pin {
+		return roots, nil
+	}
+
+	for _, c := range roots {
+		nd, err := n.DAG.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if err := n.Pinning.Pin(ctx, nd, true); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := n.Pinning.Flush(); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
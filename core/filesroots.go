@@ -0,0 +1,287 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	merkledag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// DefaultFilesRoot is the name of the files root kept in IpfsNode.FilesRoot.
+const DefaultFilesRoot = "default"
+
+var (
+	ErrFilesRootExists   = errors.New("a files root with that name already exists")
+	ErrFilesRootNotFound = errors.New("no files root with that name")
+)
+
+// filesRootsKey holds the list of named files roots. The CID of each root
+// is stored under its own key below it.
+var filesRootsKey = ds.NewKey("/local/filesroots")
+
+// FilesRootInfo describes a files root.
+type FilesRootInfo struct {
+	Name string
+
+	// Key is the name of the keystore key the root is published with
+	// through IPNS on every change, if any.
+	Key string
+
+	Root *cid.Cid
+}
+
+// filesRootRecord is the datastore encoding of a named files root.
+type filesRootRecord struct {
+	Name string
+	Key  string `json:",omitempty"`
+}
+
+func namedFilesRootKey(name string) ds.Key {
+	return filesRootsKey.ChildString(name)
+}
+
+func (n *IpfsNode) filesRootRecords() ([]filesRootRecord, error) {
+	val, err := n.Repo.Datastore().Get(filesRootsKey)
+	switch {
+	case err == ds.ErrNotFound:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	data, ok := val.([]byte)
+	if !ok {
+		return nil, errors.New("invalid files roots record in datastore")
+	}
+
+	var recs []filesRootRecord
+	if err := json.Unmarshal(data, &recs); err != nil {
+		return nil, fmt.Errorf("invalid files roots record in datastore: %s", err)
+	}
+	return recs, nil
+}
+
+func (n *IpfsNode) storeFilesRootRecords(recs []filesRootRecord) error {
+	data, err := json.Marshal(recs)
+	if err != nil {
+		return err
+	}
+	return n.Repo.Datastore().Put(filesRootsKey, data)
+}
+
+func findFilesRoot(recs []filesRootRecord, name string) int {
+	for i, rec := range recs {
+		if rec.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetFilesRoot returns the files root called name, loading it on first
+// use. An empty name stands for the default root.
+func (n *IpfsNode) GetFilesRoot(name string) (*mfs.Root, error) {
+	n.filesRootsLk.Lock()
+	defer n.filesRootsLk.Unlock()
+
+	if name == "" || name == DefaultFilesRoot {
+		return n.FilesRoot, nil
+	}
+
+	if r, ok := n.filesRoots[name]; ok {
+		return r, nil
+	}
+
+	recs, err := n.filesRootRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	i := findFilesRoot(recs, name)
+	if i < 0 {
+		return nil, ErrFilesRootNotFound
+	}
+
+	val, err := n.Repo.Datastore().Get(namedFilesRootKey(name))
+	if err != nil {
+		return nil, fmt.Errorf("files root %s: %s", name, err)
+	}
+
+	c, err := cid.Cast(val.([]byte))
+	if err != nil {
+		return nil, err
+	}
+
+	nd, err := n.DAG.Get(n.Context(), c)
+	if err != nil {
+		return nil, fmt.Errorf("error loading files root %s from DAG: %s", name, err)
+	}
+
+	pbnd, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		return nil, merkledag.ErrNotProtobuf
+	}
+
+	return n.openFilesRoot(recs[i], pbnd)
+}
+
+// openFilesRoot starts an mfs.Root for rec. Callers hold filesRootsLk.
+func (n *IpfsNode) openFilesRoot(rec filesRootRecord, nd *merkledag.ProtoNode) (*mfs.Root, error) {
+	dsk := namedFilesRootKey(rec.Name)
+	pf := func(ctx context.Context, c *cid.Cid) error {
+		if err := n.Repo.Datastore().Put(dsk, c.Bytes()); err != nil {
+			return err
+		}
+		if rec.Key == "" {
+			return nil
+		}
+		if n.Namesys == nil {
+			log.Warningf("files root %s: not publishing to key %s while offline", rec.Name, rec.Key)
+			return nil
+		}
+
+		k, err := n.GetKey(rec.Key)
+		if err != nil {
+			return err
+		}
+		return n.Namesys.Publish(ctx, k, path.FromCid(c))
+	}
+
+	r, err := mfs.NewRoot(n.Context(), n.DAG, nd, pf)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.filesRoots == nil {
+		n.filesRoots = make(map[string]*mfs.Root)
+	}
+	n.filesRoots[rec.Name] = r
+	return r, nil
+}
+
+// CreateFilesRoot creates a new, empty files root called name. If key is
+// not empty, every change of the root is published under that keystore key.
+func (n *IpfsNode) CreateFilesRoot(name, key string) (*mfs.Root, error) {
+	if name == "" || name == DefaultFilesRoot || strings.ContainsAny(name, "/ \t\n") {
+		return nil, fmt.Errorf("invalid files root name %q", name)
+	}
+	if key != "" {
+		if _, err := n.GetKey(key); err != nil {
+			return nil, fmt.Errorf("key %s: %s", key, err)
+		}
+	}
+
+	n.filesRootsLk.Lock()
+	defer n.filesRootsLk.Unlock()
+
+	recs, err := n.filesRootRecords()
+	if err != nil {
+		return nil, err
+	}
+	if findFilesRoot(recs, name) >= 0 {
+		return nil, ErrFilesRootExists
+	}
+
+	nd := ft.EmptyDirNode()
+	c, err := n.DAG.Add(nd)
+	if err != nil {
+		return nil, fmt.Errorf("failure writing to dagstore: %s", err)
+	}
+
+	if err := n.Repo.Datastore().Put(namedFilesRootKey(name), c.Bytes()); err != nil {
+		return nil, err
+	}
+
+	rec := filesRootRecord{Name: name, Key: key}
+	if err := n.storeFilesRootRecords(append(recs, rec)); err != nil {
+		return nil, err
+	}
+
+	return n.openFilesRoot(rec, nd)
+}
+
+// RemoveFilesRoot forgets the files root called name. Its content becomes
+// eligible for garbage collection unless referenced elsewhere.
+func (n *IpfsNode) RemoveFilesRoot(name string) error {
+	if name == "" || name == DefaultFilesRoot {
+		return errors.New("cannot remove the default files root")
+	}
+
+	n.filesRootsLk.Lock()
+	defer n.filesRootsLk.Unlock()
+
+	recs, err := n.filesRootRecords()
+	if err != nil {
+		return err
+	}
+
+	i := findFilesRoot(recs, name)
+	if i < 0 {
+		return ErrFilesRootNotFound
+	}
+
+	if r, ok := n.filesRoots[name]; ok {
+		if err := r.Close(); err != nil {
+			return err
+		}
+		delete(n.filesRoots, name)
+	}
+
+	if err := n.storeFilesRootRecords(append(recs[:i], recs[i+1:]...)); err != nil {
+		return err
+	}
+	return n.Repo.Datastore().Delete(namedFilesRootKey(name))
+}
+
+// ListFilesRoots describes the default files root followed by the named
+// ones.
+func (n *IpfsNode) ListFilesRoots() ([]FilesRootInfo, error) {
+	n.filesRootsLk.Lock()
+	defer n.filesRootsLk.Unlock()
+
+	def, err := n.FilesRoot.GetValue().GetNode()
+	if err != nil {
+		return nil, err
+	}
+	out := []FilesRootInfo{{Name: DefaultFilesRoot, Root: def.Cid()}}
+
+	recs, err := n.filesRootRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rec := range recs {
+		info := FilesRootInfo{Name: rec.Name, Key: rec.Key}
+
+		// loaded roots may hold changes not yet written to the datastore
+		if r, ok := n.filesRoots[rec.Name]; ok {
+			nd, err := r.GetValue().GetNode()
+			if err != nil {
+				return nil, err
+			}
+			info.Root = nd.Cid()
+		} else {
+			val, err := n.Repo.Datastore().Get(namedFilesRootKey(rec.Name))
+			if err != nil {
+				return nil, fmt.Errorf("files root %s: %s", rec.Name, err)
+			}
+			c, err := cid.Cast(val.([]byte))
+			if err != nil {
+				return nil, err
+			}
+			info.Root = c
+		}
+
+		out = append(out, info)
+	}
+	return out, nil
+}
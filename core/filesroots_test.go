@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	mfs "github.com/ipfs/go-ipfs/mfs"
+)
+
+func TestNamedFilesRoots(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n, err := NewNode(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	if _, err := n.GetFilesRoot("app"); err != ErrFilesRootNotFound {
+		t.Fatalf("expected ErrFilesRootNotFound, got %v", err)
+	}
+
+	r, err := n.CreateFilesRoot("app", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.CreateFilesRoot("app", ""); err != ErrFilesRootExists {
+		t.Fatalf("expected ErrFilesRootExists, got %v", err)
+	}
+	if _, err := n.CreateFilesRoot(DefaultFilesRoot, ""); err == nil {
+		t.Fatal("expected an error creating a root named like the default one")
+	}
+
+	if err := mfs.Mkdir(r, "/data", false, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Lookup(n.FilesRoot, "/data"); err == nil {
+		t.Fatal("named root is not separate from the default one")
+	}
+
+	// drop the loaded root so that the next lookup reads it back from the
+	// datastore
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	n.filesRootsLk.Lock()
+	delete(n.filesRoots, "app")
+	n.filesRootsLk.Unlock()
+
+	r, err = n.GetFilesRoot("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Lookup(r, "/data"); err != nil {
+		t.Fatal("named root was not persisted: ", err)
+	}
+
+	roots, err := n.ListFilesRoots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 || roots[0].Name != DefaultFilesRoot || roots[1].Name != "app" {
+		t.Fatalf("unexpected roots: %v", roots)
+	}
+
+	if err := n.RemoveFilesRoot("app"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.GetFilesRoot("app"); err != ErrFilesRootNotFound {
+		t.Fatalf("expected ErrFilesRootNotFound after removal, got %v", err)
+	}
+}
@@ -227,6 +227,27 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 		return nil, errors.New("bitswap is closed")
 	default:
 	}
+
+	// blocks inlined in identity cids are never requested from the network
+	var inline []blocks.Block
+	var wanted []*cid.Cid
+	for _, k := range keys {
+		if blk, ok := blockstore.IdentityBlock(k); ok {
+			inline = append(inline, blk)
+		} else {
+			wanted = append(wanted, k)
+		}
+	}
+	if len(wanted) == 0 {
+		out := make(chan blocks.Block, len(inline))
+		for _, blk := range inline {
+			out <- blk
+		}
+		close(out)
+		return out, nil
+	}
+	keys = wanted
+
 	promise := bs.notifications.Subscribe(ctx, keys...)
 
 	for _, k := range keys {
@@ -257,6 +278,13 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 			// can't just defer this call on its own, arguments are resolved *when* the defer is created
 			bs.CancelWants(remaining.Keys())
 		}()
+		for _, blk := range inline {
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
 		for {
 			select {
 			case blk, ok := <-promise:
@@ -298,6 +326,12 @@ func (bs *Bitswap) HasBlock(blk blocks.Block) error {
 	default:
 	}
 
+	// identity blocks are neither stored nor announced, as the data is in
+	// their cid
+	if blockstore.IsIdentity(blk.Cid()) {
+		return nil
+	}
+
 	err := bs.blockstore.Put(blk)
 	if err != nil {
 		log.Errorf("Error writing block to datastore: %s", err)
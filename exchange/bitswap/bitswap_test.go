@@ -18,6 +18,7 @@ import (
 	detectrace "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-detect-race"
 
 	p2ptestutil "gx/ipfs/QmWdGJY4fcsfhLHucEfivw8J71yUqNUFbzdU1jnJBnN5Xh/go-libp2p-netutil"
+	mh "gx/ipfs/QmYDds3421prZgqKbLpEK7T9Aa2eVdQ7o3YarX1LVLdP2J/go-multihash"
 	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
@@ -451,3 +452,46 @@ func TestWantlistCleanup(t *testing.T) {
 		t.Fatal("should only have keys[0] in wantlist")
 	}
 }
+
+func TestIdentityBlocksNotRequested(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	bswap := sg.Instances(1)[0].Exchange
+
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: mh.ID, MhLength: -1}
+	idc, err := pref.Sum([]byte("tiny"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := bg.Next().Cid()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// no peer has the block, it still comes right away
+	blk, err := bswap.GetBlock(ctx, idc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(blk.RawData(), []byte("tiny")) {
+		t.Fatal("got the wrong data for an identity block")
+	}
+
+	out, err := bswap.GetBlocks(ctx, []*cid.Cid{idc, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk = <-out
+	if !blk.Cid().Equals(idc) {
+		t.Fatal("expected the identity block first")
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	wl := bswap.GetWantlist()
+	if len(wl) != 1 || !wl[0].Equals(other) {
+		t.Fatalf("only the regular block should be wanted, got %v", wl)
+	}
+}
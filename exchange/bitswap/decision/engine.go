@@ -238,6 +238,11 @@ func (e *Engine) MessageReceived(p peer.ID, m bsmsg.BitSwapMessage) error {
 			l.CancelWant(entry.Cid)
 			e.peerRequestQueue.Remove(entry.Cid, p)
 		} else {
+			if bstore.IsIdentity(entry.Cid) {
+				// the data is in the cid, the peer already has it
+				log.Debugf("%s wants inlined block %s, ignoring", p, entry.Cid)
+				continue
+			}
 			log.Debugf("wants %s - %d", entry.Cid, entry.Priority)
 			l.Wants(entry.Cid, entry.Priority)
 			if exists, err := e.bs.Has(entry.Cid); err == nil && exists {
@@ -247,6 +247,41 @@ func TestMultipleDirs(t *testing.T) {
 	mnt.Close()
 }
 
+func TestSymlink(t *testing.T) {
+	node, mnt := setupIpnsTest(t, nil)
+
+	link := mnt.Dir + "/local/link"
+	if err := os.Symlink("../some/target", link); err != nil {
+		t.Fatal(err)
+	}
+
+	checkLink := func() {
+		fi, err := os.Lstat(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			t.Fatalf("expected a symlink, got mode %s", fi.Mode())
+		}
+
+		target, err := os.Readlink(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target != "../some/target" {
+			t.Fatalf("expected target ../some/target, got %s", target)
+		}
+	}
+	checkLink()
+
+	mnt.Close()
+
+	_, mnt = setupIpnsTest(t, node)
+	defer mnt.Close()
+
+	checkLink()
+}
+
 // Test to make sure the filesystem reports file sizes correctly
 func TestFileSizeReporting(t *testing.T) {
 	if testing.Short() {
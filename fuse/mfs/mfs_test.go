@@ -0,0 +1,269 @@
+// +build !nofuse
+
+package mfs
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+
+	fstest "github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse/fs/fstestutil"
+
+	core "github.com/ipfs/go-ipfs/core"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	ci "github.com/ipfs/go-ipfs/thirdparty/testutil/ci"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	u "gx/ipfs/Qmb912gdngC1UWwTkhuW8knyRbcWeu5kqkxBpveLmW8bSr/go-ipfs-util"
+)
+
+func maybeSkipFuseTests(t *testing.T) {
+	if ci.NoFuse() {
+		t.Skip("Skipping FUSE tests")
+	}
+}
+
+func randBytes(size int) []byte {
+	b := make([]byte, size)
+	u.NewTimeSeededRand().Read(b)
+	return b
+}
+
+type mountWrap struct {
+	*fstest.Mount
+	Fs *FileSystem
+}
+
+func (m *mountWrap) Close() error {
+	m.Fs.Destroy()
+	m.Mount.Close()
+	return nil
+}
+
+func setupMfsTest(t *testing.T, node *core.IpfsNode) (*core.IpfsNode, *mountWrap) {
+	maybeSkipFuseTests(t)
+
+	var err error
+	if node == nil {
+		node, err = core.NewNode(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs := NewFileSystem(func() (*mfs.Root, error) {
+		return node.GetFilesRoot(core.DefaultFilesRoot)
+	})
+	mnt, err := fstest.MountedT(t, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return node, &mountWrap{
+		Mount: mnt,
+		Fs:    fs,
+	}
+}
+
+// readMfs reads a file straight from the files root, bypassing the mount
+func readMfs(t *testing.T, node *core.IpfsNode, path string) []byte {
+	fsn, err := mfs.Lookup(node.FilesRoot, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := fsn.(*mfs.File).Open(mfs.OpenReadOnly, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	data, err := ioutil.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestMfsWriteFlushesToFilesRoot(t *testing.T) {
+	node, mnt := setupMfsTest(t, nil)
+	defer mnt.Close()
+
+	if err := os.Mkdir(mnt.Dir+"/docs", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	data := randBytes(5000)
+	if err := ioutil.WriteFile(mnt.Dir+"/docs/file", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(readMfs(t, node, "/docs/file"), data) {
+		t.Fatal("file written through the mount differs in the files root")
+	}
+
+	// changes made through 'ipfs files' show up in the mount
+	if err := mfs.Mkdir(node.FilesRoot, "/docs/sub", false, true); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(mnt.Dir + "/docs/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() {
+		t.Fatal("expected a directory")
+	}
+}
+
+func TestMfsFsync(t *testing.T) {
+	node, mnt := setupMfsTest(t, nil)
+	defer mnt.Close()
+
+	f, err := os.Create(mnt.Dir + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	data := randBytes(3000)
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is still open, but its data reached the files root
+	fsn, err := mfs.Lookup(node.FilesRoot, "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := fsn.(*mfs.File).Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(data)) {
+		t.Fatalf("fsync did not flush the data to the files root, size %d", size)
+	}
+}
+
+func TestMfsPersistence(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	node, mnt := setupMfsTest(t, nil)
+
+	data := randBytes(127)
+	if err := ioutil.WriteFile(mnt.Dir+"/afile", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("afile", mnt.Dir+"/alink"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(mnt.Dir+"/afile", mnt.Dir+"/renamed"); err != nil {
+		t.Fatal(err)
+	}
+	mnt.Close()
+
+	_, mnt = setupMfsTest(t, node)
+	defer mnt.Close()
+
+	rbuf, err := ioutil.ReadFile(mnt.Dir + "/renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rbuf, data) {
+		t.Fatal("file data changed between mounts")
+	}
+	if _, err := os.Stat(mnt.Dir + "/afile"); !os.IsNotExist(err) {
+		t.Fatal("renamed file still exists under its old name")
+	}
+
+	target, err := os.Readlink(mnt.Dir + "/alink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "afile" {
+		t.Fatalf("expected symlink to afile, got %s", target)
+	}
+}
+
+func TestMfsRemove(t *testing.T) {
+	node, mnt := setupMfsTest(t, nil)
+	defer mnt.Close()
+
+	if err := os.MkdirAll(mnt.Dir+"/a/b", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(mnt.Dir + "/a"); err == nil {
+		t.Fatal("removing a non-empty directory should fail")
+	}
+	if err := os.RemoveAll(mnt.Dir + "/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Lookup(node.FilesRoot, "/a"); err != os.ErrNotExist {
+		t.Fatalf("expected /a to be gone from the files root, got %v", err)
+	}
+}
+
+func TestMfsBusyWhileOpen(t *testing.T) {
+	_, mnt := setupMfsTest(t, nil)
+	defer mnt.Close()
+
+	fname := mnt.Dir + "/file"
+	if err := ioutil.WriteFile(fname, randBytes(1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// neither can go through the read only handle
+	if err := os.Truncate(fname, 10); !isBusy(err) {
+		t.Fatalf("expected truncate to fail with EBUSY, got %v", err)
+	}
+	if err := os.Chmod(fname, 0600); !isBusy(err) {
+		t.Fatalf("expected chmod to fail with EBUSY, got %v", err)
+	}
+}
+
+func isBusy(err error) bool {
+	if perr, ok := err.(*os.PathError); ok {
+		err = perr.Err
+	}
+	return err == syscall.EBUSY
+}
+
+func TestMfsFollowsReplacedRoot(t *testing.T) {
+	node, mnt := setupMfsTest(t, nil)
+	defer mnt.Close()
+
+	if err := ioutil.WriteFile(mnt.Dir+"/before", []byte("before"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	nd := ft.EmptyDirNode()
+	if _, err := node.DAG.Add(nd); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.SetFilesRoot(nd); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(mnt.Dir + "/before"); !os.IsNotExist(err) {
+		t.Fatal("the mount still shows the replaced root")
+	}
+
+	data := randBytes(100)
+	if err := ioutil.WriteFile(mnt.Dir+"/after", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readMfs(t, node, "/after"), data) {
+		t.Fatal("file written after the root was replaced is not in the new root")
+	}
+}
@@ -0,0 +1,698 @@
+// +build linux darwin freebsd netbsd openbsd
+// +build !nofuse
+
+// package fuse/mfs implements a read-write fuse filesystem exposing the
+// files root of a node, the tree managed with 'ipfs files'.
+package mfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"sync"
+	"syscall"
+
+	"context"
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse"
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse/fs"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+)
+
+func init() {
+	if os.Getenv("IPFS_FUSE_DEBUG") != "" {
+		fuse.Debug = func(msg interface{}) {
+			fmt.Println(msg)
+		}
+	}
+}
+
+var log = logging.Logger("fuse/mfs")
+
+// FileSystem is the read-write fuse filesystem over an mfs root.
+type FileSystem struct {
+	getRoot func() (*mfs.Root, error)
+}
+
+// NewFileSystem returns a filesystem exposing the mfs root returned by
+// getRoot. It is called again on every operation, so that the filesystem
+// follows the root when it is replaced, as by a snapshot restore.
+func NewFileSystem(getRoot func() (*mfs.Root, error)) *FileSystem {
+	return &FileSystem{getRoot: getRoot}
+}
+
+// Root returns the node at the top of the mfs root, usually a directory.
+func (f *FileSystem) Root() (fs.Node, error) {
+	e := &entry{fs: f}
+	fsn, err := e.node()
+	if err != nil {
+		return nil, err
+	}
+	return e.wrap(fsn)
+}
+
+// Destroy flushes pending changes to the mfs root. The root itself belongs
+// to its owner and is left open.
+func (f *FileSystem) Destroy() {
+	root, err := f.getRoot()
+	if err == nil {
+		err = root.Flush()
+	}
+	if err != nil {
+		log.Errorf("error flushing files root: %s", err)
+	}
+}
+
+// entry locates a node of the filesystem by its path under the mfs root.
+// The mfs node is looked up again when the root was replaced.
+type entry struct {
+	fs   *FileSystem
+	path string
+
+	lk   sync.Mutex
+	root *mfs.Root
+	fsn  mfs.FSNode
+}
+
+// node returns the mfs node at the path of e in the current root.
+func (e *entry) node() (mfs.FSNode, error) {
+	root, err := e.fs.getRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	e.lk.Lock()
+	defer e.lk.Unlock()
+
+	if e.fsn != nil && e.root == root {
+		return e.fsn, nil
+	}
+
+	var fsn mfs.FSNode
+	if e.path == "" {
+		fsn = root.GetValue()
+	} else if fsn, err = mfs.Lookup(root, e.path); err != nil {
+		return nil, fuse.ENOENT
+	}
+
+	e.root, e.fsn = root, fsn
+	return fsn, nil
+}
+
+// child returns the entry of the node called name under e, already
+// resolved to fsn.
+func (e *entry) child(name string, fsn mfs.FSNode) *entry {
+	e.lk.Lock()
+	root := e.root
+	e.lk.Unlock()
+	return &entry{fs: e.fs, path: gopath.Join("/", e.path, name), root: root, fsn: fsn}
+}
+
+// wrap returns the fuse node for fsn, found at the path of e.
+func (e *entry) wrap(fsn mfs.FSNode) (fs.Node, error) {
+	switch fsn := fsn.(type) {
+	case *mfs.Directory:
+		return &Directory{entry: e}, nil
+	case *mfs.File:
+		if target, err := fsn.Readlink(); err == nil {
+			return &Link{Target: target}, nil
+		}
+		return &FileNode{entry: e}, nil
+	default:
+		return nil, fmt.Errorf("unexpected mfs node type: %T", fsn)
+	}
+}
+
+// Directory is a wrapper over an mfs directory to satisfy the fuse fs interface
+type Directory struct {
+	*entry
+}
+
+func (d *Directory) dir() (*mfs.Directory, error) {
+	fsn, err := d.node()
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return nil, fuse.Errno(syscall.ENOTDIR)
+	}
+	return dir, nil
+}
+
+// FileNode is a wrapper over an mfs file to satisfy the fuse fs interface
+type FileNode struct {
+	*entry
+
+	// open handles, so that fsync can flush the data they hold
+	hlk     sync.Mutex
+	handles map[*File]struct{}
+}
+
+func (fi *FileNode) file() (*mfs.File, error) {
+	fsn, err := fi.node()
+	if err != nil {
+		return nil, err
+	}
+	f, ok := fsn.(*mfs.File)
+	if !ok {
+		return nil, fuse.Errno(syscall.EISDIR)
+	}
+	return f, nil
+}
+
+// File is an open file handle
+type File struct {
+	node *FileNode
+
+	// mfs file descriptors are not safe for concurrent use
+	lk sync.Mutex
+	fd mfs.FileDescriptor
+}
+
+// Link is a symbolic link
+type Link struct {
+	Target string
+}
+
+// attrModeAndTime fills in the permission bits and modification time of a
+// from those recorded in fsn, falling back on defmode.
+func attrModeAndTime(a *fuse.Attr, fsn mfs.FSNode, defmode os.FileMode) error {
+	m, err := mfs.UnixMeta(fsn)
+	if err != nil {
+		return err
+	}
+	if !m.HasMode {
+		m.Mode = defmode
+	}
+	a.Mode |= m.Mode
+	a.Mtime = m.ModTime
+	a.Uid = uint32(os.Getuid())
+	a.Gid = uint32(os.Getgid())
+	return nil
+}
+
+// Attr returns the attributes of a given node.
+func (d *Directory) Attr(ctx context.Context, a *fuse.Attr) error {
+	dir, err := d.dir()
+	if err != nil {
+		return err
+	}
+	a.Mode = os.ModeDir
+	return attrModeAndTime(a, dir, 0755)
+}
+
+// Lookup performs a lookup under this node.
+func (d *Directory) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	dir, err := d.dir()
+	if err != nil {
+		return nil, err
+	}
+
+	child, err := dir.Child(name)
+	if err != nil {
+		return nil, fuse.ENOENT
+	}
+	return d.child(name, child).wrap(child)
+}
+
+// ReadDirAll reads the link structure as directory entries
+func (d *Directory) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	dir, err := d.dir()
+	if err != nil {
+		return nil, err
+	}
+
+	listing, err := dir.List()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]fuse.Dirent, 0, len(listing))
+	for _, entry := range listing {
+		dirent := fuse.Dirent{Name: entry.Name}
+
+		switch mfs.NodeType(entry.Type) {
+		case mfs.TDir:
+			dirent.Type = fuse.DT_Dir
+		case mfs.TFile:
+			dirent.Type = fuse.DT_File
+			if child, err := dir.Child(entry.Name); err == nil {
+				if fi, ok := child.(*mfs.File); ok && fi.IsSymlink() {
+					dirent.Type = fuse.DT_Link
+				}
+			}
+		}
+
+		entries = append(entries, dirent)
+	}
+	return entries, nil
+}
+
+// Mkdir creates a directory under this one
+func (d *Directory) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	dir, err := d.dir()
+	if err != nil {
+		return nil, err
+	}
+
+	child, err := dir.Mkdir(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := child.Flush(); err != nil {
+		return nil, err
+	}
+	return &Directory{entry: d.child(req.Name, child)}, nil
+}
+
+// Create creates and opens a new, empty file
+func (d *Directory) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	dir, err := d.dir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nd := dag.NodeWithData(ft.FilePBData(nil, 0))
+	if err := dir.AddChild(req.Name, nd); err != nil {
+		return nil, nil, err
+	}
+
+	child, err := dir.Child(req.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fi, ok := child.(*mfs.File)
+	if !ok {
+		return nil, nil, errors.New("child creation failed")
+	}
+
+	node := &FileNode{entry: d.child(req.Name, fi)}
+	h, err := node.open(req.Flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	return node, h, nil
+}
+
+// Symlink creates a symbolic link under this directory
+func (d *Directory) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	dir, err := d.dir()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dir.Symlink(req.NewName, req.Target); err != nil {
+		return nil, err
+	}
+	if err := dir.Flush(); err != nil {
+		return nil, err
+	}
+	return &Link{Target: req.Target}, nil
+}
+
+// Remove removes a file or an empty directory
+func (d *Directory) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	dir, err := d.dir()
+	if err != nil {
+		return err
+	}
+
+	child, err := dir.Child(req.Name)
+	if err != nil {
+		return fuse.ENOENT
+	}
+
+	if cdir, ok := child.(*mfs.Directory); ok && len(cdir.ListNames()) > 0 {
+		return fuse.Errno(syscall.ENOTEMPTY)
+	}
+
+	if err := dir.Unlink(req.Name); err != nil {
+		return err
+	}
+	return dir.Flush()
+}
+
+// Rename moves a child of this directory under newDir
+func (d *Directory) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	target, ok := newDir.(*Directory)
+	if !ok {
+		return fuse.EPERM
+	}
+
+	dir, err := d.dir()
+	if err != nil {
+		return err
+	}
+	tdir, err := target.dir()
+	if err != nil {
+		return err
+	}
+
+	cur, err := dir.Child(req.OldName)
+	if err != nil {
+		return fuse.ENOENT
+	}
+
+	nd, err := cur.GetNode()
+	if err != nil {
+		return err
+	}
+
+	if err := dir.Unlink(req.OldName); err != nil {
+		return err
+	}
+
+	// rename(2) replaces an existing target
+	if _, err := tdir.Child(req.NewName); err == nil {
+		if err := tdir.Unlink(req.NewName); err != nil {
+			return err
+		}
+	}
+
+	if err := tdir.AddChild(req.NewName, nd); err != nil {
+		return err
+	}
+
+	if err := dir.Flush(); err != nil {
+		return err
+	}
+	return tdir.Flush()
+}
+
+// Fsync flushes the directory up to the files root
+func (d *Directory) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	dir, err := d.dir()
+	if err != nil {
+		return err
+	}
+	return dir.Flush()
+}
+
+// Attr returns the attributes of a given node.
+func (fi *FileNode) Attr(ctx context.Context, a *fuse.Attr) error {
+	f, err := fi.file()
+	if err != nil {
+		return err
+	}
+
+	size, err := fi.size(f)
+	if err != nil {
+		return fmt.Errorf("fuse/mfs: failed to get file size: %s", err)
+	}
+	a.Size = uint64(size)
+	return attrModeAndTime(a, f, 0644)
+}
+
+// size returns the size of the file, including data written to open
+// handles but not yet flushed
+func (fi *FileNode) size(f *mfs.File) (int64, error) {
+	fi.hlk.Lock()
+	defer fi.hlk.Unlock()
+
+	for h := range fi.handles {
+		h.lk.Lock()
+		size, err := h.fd.Size()
+		h.lk.Unlock()
+		if err == nil {
+			return size, nil
+		}
+	}
+	return f.Size()
+}
+
+// Open opens the file for reading or writing
+func (fi *FileNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	return fi.open(req.Flags)
+}
+
+func (fi *FileNode) open(flags fuse.OpenFlags) (*File, error) {
+	var mfsflag int
+	switch {
+	case flags.IsReadOnly():
+		mfsflag = mfs.OpenReadOnly
+	case flags.IsWriteOnly():
+		mfsflag = mfs.OpenWriteOnly
+	case flags.IsReadWrite():
+		mfsflag = mfs.OpenReadWrite
+	default:
+		return nil, errors.New("unsupported flag type")
+	}
+
+	f, err := fi.file()
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := f.Open(mfsflag, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if flags&fuse.OpenTruncate != 0 && !flags.IsReadOnly() {
+		if err := fd.Truncate(0); err != nil {
+			fd.Close()
+			return nil, err
+		}
+	}
+
+	if flags&fuse.OpenAppend != 0 && !flags.IsReadOnly() {
+		if _, err := fd.Seek(0, os.SEEK_END); err != nil {
+			fd.Close()
+			return nil, err
+		}
+	}
+
+	h := &File{node: fi, fd: fd}
+
+	fi.hlk.Lock()
+	if fi.handles == nil {
+		fi.handles = make(map[*File]struct{})
+	}
+	fi.handles[h] = struct{}{}
+	fi.hlk.Unlock()
+
+	return h, nil
+}
+
+// Setattr changes the size, permission bits or modification time of the
+// file
+func (fi *FileNode) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if req.Valid.Size() {
+		if err := fi.truncate(int64(req.Size)); err != nil {
+			return err
+		}
+	}
+
+	if req.Valid.Mode() || req.Valid.Mtime() {
+		fi.hlk.Lock()
+		open := len(fi.handles)
+		fi.hlk.Unlock()
+
+		// the metadata is part of the file node, which can't be replaced
+		// while handles are open on it
+		if open > 0 {
+			return fuse.Errno(syscall.EBUSY)
+		}
+
+		f, err := fi.file()
+		if err != nil {
+			return err
+		}
+		if req.Valid.Mode() {
+			if err := mfs.SetMode(f, req.Mode.Perm()); err != nil {
+				return err
+			}
+		}
+		if req.Valid.Mtime() {
+			if err := mfs.SetModTime(f, req.Mtime); err != nil {
+				return err
+			}
+		}
+	}
+
+	return fi.Attr(ctx, &resp.Attr)
+}
+
+// truncate changes the size of the file, through an open writable handle if
+// there is one. Other open handles would block opening one, so it fails
+// with EBUSY then.
+func (fi *FileNode) truncate(size int64) error {
+	fi.hlk.Lock()
+	open := len(fi.handles)
+	for h := range fi.handles {
+		h.lk.Lock()
+		err := h.fd.Truncate(size)
+		h.lk.Unlock()
+		if err == nil {
+			fi.hlk.Unlock()
+			return nil
+		}
+	}
+	fi.hlk.Unlock()
+
+	if open > 0 {
+		return fuse.Errno(syscall.EBUSY)
+	}
+
+	f, err := fi.file()
+	if err != nil {
+		return err
+	}
+	fd, err := f.Open(mfs.OpenWriteOnly, true)
+	if err != nil {
+		return err
+	}
+	if err := fd.Truncate(size); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
+
+// Fsync flushes the data written to the open handles of the file up to the
+// files root
+func (fi *FileNode) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	fi.hlk.Lock()
+	var handles []*File
+	for h := range fi.handles {
+		handles = append(handles, h)
+	}
+	fi.hlk.Unlock()
+
+	for _, h := range handles {
+		if err := h.flush(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	size, err := h.fd.Size()
+	if err != nil {
+		return err
+	}
+	if req.Offset >= size {
+		resp.Data = resp.Data[:0]
+		return nil
+	}
+
+	if _, err := h.fd.Seek(req.Offset, os.SEEK_SET); err != nil {
+		return err
+	}
+
+	readsize := min(req.Size, int(size-req.Offset))
+	n, err := h.fd.CtxReadFull(ctx, resp.Data[:readsize])
+	resp.Data = resp.Data[:n]
+	return err
+}
+
+func (h *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	wrote, err := h.fd.WriteAt(req.Data, req.Offset)
+	if err != nil {
+		return err
+	}
+	resp.Size = wrote
+	return nil
+}
+
+// Flush is called on every close of the handle and flushes its data up to
+// the files root
+func (h *File) Flush(ctx context.Context, req *fuse.FlushRequest) error {
+	return h.flush(ctx)
+}
+
+func (h *File) flush(ctx context.Context) error {
+	errs := make(chan error, 1)
+	go func() {
+		h.lk.Lock()
+		defer h.lk.Unlock()
+		errs <- h.fd.Flush()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release closes the handle
+func (h *File) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	h.node.hlk.Lock()
+	delete(h.node.handles, h)
+	h.node.hlk.Unlock()
+
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	return h.fd.Close()
+}
+
+// Attr returns the attributes of a symbolic link
+func (l *Link) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Mode = os.ModeSymlink | 0777
+	a.Uid = uint32(os.Getuid())
+	a.Gid = uint32(os.Getgid())
+	return nil
+}
+
+// Readlink returns the target of a symbolic link
+func (l *Link) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
+	return l.Target, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// to check that our nodes implement all the interfaces we want
+type mfsDirectory interface {
+	fs.Node
+	fs.HandleReadDirAller
+	fs.NodeCreater
+	fs.NodeFsyncer
+	fs.NodeMkdirer
+	fs.NodeRemover
+	fs.NodeRenamer
+	fs.NodeStringLookuper
+	fs.NodeSymlinker
+}
+
+var _ mfsDirectory = (*Directory)(nil)
+
+type mfsFileNode interface {
+	fs.Node
+	fs.NodeFsyncer
+	fs.NodeOpener
+	fs.NodeSetattrer
+}
+
+var _ mfsFileNode = (*FileNode)(nil)
+
+type mfsFile interface {
+	fs.HandleFlusher
+	fs.HandleReader
+	fs.HandleReleaser
+	fs.HandleWriter
+}
+
+var _ mfsFile = (*File)(nil)
+
+var _ fs.NodeReadlinker = (*Link)(nil)
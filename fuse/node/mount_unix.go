@@ -11,6 +11,7 @@ import (
 
 	core "github.com/ipfs/go-ipfs/core"
 	ipns "github.com/ipfs/go-ipfs/fuse/ipns"
+	mfs "github.com/ipfs/go-ipfs/fuse/mfs"
 	mount "github.com/ipfs/go-ipfs/fuse/mount"
 	rofs "github.com/ipfs/go-ipfs/fuse/readonly"
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
@@ -34,7 +35,9 @@ var platformFuseChecks = func(*core.IpfsNode) error {
 	return nil
 }
 
-func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
+// Mount mounts /ipfs at fsdir and /ipns at nsdir. The files root is also
+// mounted at mfsdir, unless it is empty.
+func Mount(node *core.IpfsNode, fsdir, nsdir, mfsdir string) error {
 	// check if we already have live mounts.
 	// if the user said "Mount", then there must be something wrong.
 	// so, close them and try again.
@@ -44,6 +47,9 @@ func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
 	if node.Mounts.Ipns != nil && node.Mounts.Ipns.IsActive() {
 		node.Mounts.Ipns.Unmount()
 	}
+	if node.Mounts.Mfs != nil && node.Mounts.Mfs.IsActive() {
+		node.Mounts.Mfs.Unmount()
+	}
 
 	if err := platformFuseChecks(node); err != nil {
 		return err
@@ -54,24 +60,35 @@ func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
 		return err
 	}
 
+	if mfsdir != "" {
+		mfsmount, err := mfs.Mount(node, mfsdir)
+		if err != nil {
+			log.Errorf("error mounting: %s", err)
+			node.Mounts.Ipfs.Unmount()
+			node.Mounts.Ipns.Unmount()
+			return fmtFuseErr(err, mfsdir)
+		}
+		node.Mounts.Mfs = mfsmount
+	}
+
 	return nil
 }
 
-func doMount(node *core.IpfsNode, fsdir, nsdir string) error {
-	fmtFuseErr := func(err error, mountpoint string) error {
-		s := err.Error()
-		if strings.Contains(s, fuseNoDirectory) {
-			s = strings.Replace(s, `fusermount: "fusermount:`, "", -1)
-			s = strings.Replace(s, `\n", exit status 1`, "", -1)
-			return errors.New(s)
-		}
-		if s == fuseExitStatus1 {
-			s = fmt.Sprintf("fuse failed to access mountpoint %s", mountpoint)
-			return errors.New(s)
-		}
-		return err
+func fmtFuseErr(err error, mountpoint string) error {
+	s := err.Error()
+	if strings.Contains(s, fuseNoDirectory) {
+		s = strings.Replace(s, `fusermount: "fusermount:`, "", -1)
+		s = strings.Replace(s, `\n", exit status 1`, "", -1)
+		return errors.New(s)
+	}
+	if s == fuseExitStatus1 {
+		s = fmt.Sprintf("fuse failed to access mountpoint %s", mountpoint)
+		return errors.New(s)
 	}
+	return err
+}
 
+func doMount(node *core.IpfsNode, fsdir, nsdir string) error {
 	// this sync stuff is so that both can be mounted simultaneously.
 	var fsmount mount.Mount
 	var nsmount mount.Mount
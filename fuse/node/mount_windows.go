@@ -4,7 +4,7 @@ import (
 	"github.com/ipfs/go-ipfs/core"
 )
 
-func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
+func Mount(node *core.IpfsNode, fsdir, nsdir, mfsdir string) error {
 	// TODO
 	// currently a no-op, but we don't want to return an error
 	return nil
@@ -0,0 +1,125 @@
+package chunk
+
+import (
+	"io"
+)
+
+const (
+	// buzhash boundaries are looked for in a window of this many bytes
+	buzWindow = 32
+
+	buzMin  = 128 << 10
+	buzMax  = 512 << 10
+	buzMask = 1<<17 - 1
+)
+
+// buzTable holds the byte hashes of the buzhash rolling hash. It is derived
+// from a fixed seed, as chunk boundaries, and so the resulting hashes, must
+// never change.
+var buzTable = func() [256]uint32 {
+	var t [256]uint32
+	gen := splitmix64(0x62757a68617368) // "buzhash"
+	for i := range t {
+		t[i] = uint32(gen() >> 32)
+	}
+	return t
+}()
+
+// splitmix64 returns a simple deterministic generator of 64 bit values,
+// used to build the tables of the content-defined chunkers.
+func splitmix64(seed uint64) func() uint64 {
+	return func() uint64 {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		return z ^ (z >> 31)
+	}
+}
+
+func rotl32(x uint32, n uint) uint32 {
+	return x<<n | x>>(32-n)
+}
+
+// Buzhash is a content-defined splitter using a cyclic polynomial rolling
+// hash over a 32 byte window. It is much faster than Rabin and cuts chunks
+// of 128KiB to 512KiB, 256KiB on average.
+type Buzhash struct {
+	r   io.Reader
+	buf cdcBuffer
+}
+
+func NewBuzhash(r io.Reader) *Buzhash {
+	return &Buzhash{
+		r:   r,
+		buf: cdcBuffer{r: r, buf: make([]byte, buzMax)},
+	}
+}
+
+func (b *Buzhash) Reader() io.Reader {
+	return b.r
+}
+
+func (b *Buzhash) NextBytes() ([]byte, error) {
+	data, err := b.buf.fill()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) <= buzMin {
+		return b.buf.take(len(data)), nil
+	}
+
+	var state uint32
+	i := buzMin - buzWindow
+	for ; i < buzMin; i++ {
+		state = rotl32(state, 1) ^ buzTable[data[i]]
+	}
+
+	// the byte leaving the window was rotated a full turn, so xoring its
+	// hash again removes it from the state
+	for ; state&buzMask != 0 && i < len(data); i++ {
+		state = rotl32(state, 1) ^ buzTable[data[i-buzWindow]] ^ buzTable[data[i]]
+	}
+
+	return b.buf.take(i), nil
+}
+
+// cdcBuffer keeps the data read ahead by content-defined chunkers, which
+// need to see up to a maximum chunk size of data to place a boundary.
+type cdcBuffer struct {
+	r   io.Reader
+	buf []byte
+	n   int
+	err error
+}
+
+// fill reads until the buffer is full or the reader is exhausted, and
+// returns the buffered data. It returns io.EOF once all data was taken.
+func (c *cdcBuffer) fill() ([]byte, error) {
+	if c.err == nil && c.n < len(c.buf) {
+		n, err := io.ReadFull(c.r, c.buf[c.n:])
+		c.n += n
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			c.err = io.EOF
+		default:
+			c.err = err
+			return nil, err
+		}
+	}
+
+	if c.n == 0 {
+		return nil, c.err
+	}
+	return c.buf[:c.n], nil
+}
+
+// take removes and returns the first n buffered bytes.
+func (c *cdcBuffer) take(n int) []byte {
+	out := make([]byte, n)
+	copy(out, c.buf[:n])
+	c.n = copy(c.buf, c.buf[n:c.n])
+	return out
+}
@@ -0,0 +1,107 @@
+package chunk
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// cdcTestData returns size bytes of deterministic pseudo-random data, so
+// that chunk boundaries can be checked against fixed vectors.
+func cdcTestData(size int) []byte {
+	gen := splitmix64(42)
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = byte(gen())
+	}
+	return buf
+}
+
+func chunkSizes(t *testing.T, s Splitter, data []byte) []int {
+	var sizes []int
+	var chunks [][]byte
+	for {
+		chunk, err := s.NextBytes()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, len(chunk))
+		chunks = append(chunks, chunk)
+	}
+
+	if !bytes.Equal(bytes.Join(chunks, nil), data) {
+		t.Fatal("data was chunked incorrectly")
+	}
+	return sizes
+}
+
+func checkSizes(t *testing.T, got, expected []int) {
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d chunks, got %d: %v", len(expected), len(got), got)
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Fatalf("chunk %d: expected size %d, got %d", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestBuzhashBoundaries(t *testing.T) {
+	data := cdcTestData(4 << 20)
+
+	// chunk boundaries determine hashes and must never change
+	expected := []int{
+		255924, 234101, 289902, 200369, 186675, 157124, 295086, 164457, 134772,
+		283805, 483466, 184530, 233165, 141415, 173821, 463651, 162915, 149126,
+	}
+
+	checkSizes(t, chunkSizes(t, NewBuzhash(bytes.NewReader(data)), data), expected)
+}
+
+func TestBuzhashSmallInput(t *testing.T) {
+	for _, size := range []int{0, 1, buzMin, buzMin + 1} {
+		data := cdcTestData(size)
+		sizes := chunkSizes(t, NewBuzhash(bytes.NewReader(data)), data)
+		if size > 0 && len(sizes) != 1 {
+			t.Fatalf("expected a single chunk for %d bytes, got %v", size, sizes)
+		}
+	}
+}
+
+func TestBuzhashFromString(t *testing.T) {
+	s, err := FromString(bytes.NewReader(nil), "buzhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*Buzhash); !ok {
+		t.Fatalf("expected a buzhash splitter, got %T", s)
+	}
+}
+
+func BenchmarkBuzhash(b *testing.B) {
+	benchmarkSplitter(b, func(r io.Reader) Splitter {
+		return NewBuzhash(r)
+	})
+}
+
+func benchmarkSplitter(b *testing.B, gen SplitterGen) {
+	data := cdcTestData(16 << 20)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		s := gen(bytes.NewReader(data))
+		for {
+			_, err := s.NextBytes()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
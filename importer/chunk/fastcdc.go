@@ -0,0 +1,101 @@
+package chunk
+
+import (
+	"fmt"
+	"io"
+)
+
+// gearTable holds the byte hashes of the gear rolling hash used by FastCDC,
+// derived from a fixed seed like buzTable.
+var gearTable = func() [256]uint64 {
+	var t [256]uint64
+	gen := splitmix64(0x66617374636463) // "fastcdc"
+	for i := range t {
+		t[i] = gen()
+	}
+	return t
+}()
+
+// FastCDC is a content-defined splitter implementing FastCDC: a gear rolling
+// hash with normalized chunking. Below the average size a harder boundary
+// condition is used, and above it an easier one, so that chunk sizes stay
+// close to the average.
+type FastCDC struct {
+	r   io.Reader
+	buf cdcBuffer
+
+	min, avg int
+
+	// the gear hash shifts left, so its top bits depend on the most
+	// bytes: the masks select those
+	maskS, maskL uint64
+}
+
+// NewFastCDC returns a FastCDC splitter cutting chunks of min to max bytes,
+// avg on average.
+func NewFastCDC(r io.Reader, min, avg, max int) (*FastCDC, error) {
+	if min <= 0 || min >= avg || avg >= max {
+		return nil, fmt.Errorf("fastcdc sizes must satisfy 0 < min < avg < max, got %d, %d, %d", min, avg, max)
+	}
+	if max > ChunkSizeLimit {
+		return nil, fmt.Errorf("fastcdc maximum size %d exceeds the block size limit of %d", max, ChunkSizeLimit)
+	}
+
+	bits := uint(0)
+	for 1<<(bits+1) <= avg {
+		bits++
+	}
+	if bits < 4 {
+		return nil, fmt.Errorf("fastcdc average size %d is too small", avg)
+	}
+
+	return &FastCDC{
+		r:     r,
+		buf:   cdcBuffer{r: r, buf: make([]byte, max)},
+		min:   min,
+		avg:   avg,
+		maskS: topBits(bits + 2),
+		maskL: topBits(bits - 2),
+	}, nil
+}
+
+func topBits(n uint) uint64 {
+	return ^uint64(0) << (64 - n)
+}
+
+func (f *FastCDC) Reader() io.Reader {
+	return f.r
+}
+
+func (f *FastCDC) NextBytes() ([]byte, error) {
+	data, err := f.buf.fill()
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(data)
+	if n <= f.min {
+		return f.buf.take(n), nil
+	}
+
+	normal := f.avg
+	if n < normal {
+		normal = n
+	}
+
+	var fp uint64
+	i := f.min
+	for ; i < normal; i++ {
+		fp = fp<<1 + gearTable[data[i]]
+		if fp&f.maskS == 0 {
+			return f.buf.take(i + 1), nil
+		}
+	}
+	for ; i < n; i++ {
+		fp = fp<<1 + gearTable[data[i]]
+		if fp&f.maskL == 0 {
+			return f.buf.take(i + 1), nil
+		}
+	}
+	return f.buf.take(n), nil
+}
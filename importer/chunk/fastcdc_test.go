@@ -0,0 +1,73 @@
+package chunk
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestFastCDCBoundaries(t *testing.T) {
+	data := cdcTestData(1 << 17)
+
+	// chunk boundaries determine hashes and must never change
+	expected := []int{
+		4931, 4187, 4056, 2370, 4297, 5392, 4666, 2223, 4740, 4205, 4816,
+		4828, 2685, 4231, 4920, 4757, 2337, 5362, 4881, 5213, 4544, 6085,
+		2465, 4239, 4442, 4146, 5122, 5322, 4133, 4582, 895,
+	}
+
+	s, err := NewFastCDC(bytes.NewReader(data), 1024, 4096, 16384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSizes(t, chunkSizes(t, s, data), expected)
+
+	// after an insertion at the start, boundaries resynchronize right away
+	s, err = NewFastCDC(bytes.NewReader(data[1000:]), 1024, 4096, 16384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shifted := chunkSizes(t, s, data[1000:])
+	checkSizes(t, shifted[3:], expected[3:])
+}
+
+func TestFastCDCDefaultBoundaries(t *testing.T) {
+	data := cdcTestData(4 << 20)
+
+	expected := []int{
+		271937, 293417, 283057, 411098, 286731, 262693, 412239, 275726,
+		285403, 272174, 416379, 398447, 275574, 49429,
+	}
+
+	s, err := FromString(bytes.NewReader(data), "fastcdc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSizes(t, chunkSizes(t, s, data), expected)
+}
+
+func TestFastCDCFromString(t *testing.T) {
+	good := []string{"fastcdc", "fastcdc-8192", "fastcdc-1024-4096-16384", "fastcdc-min:1024-avg:4096-max:16384"}
+	for _, str := range good {
+		if _, err := FromString(bytes.NewReader(nil), str); err != nil {
+			t.Fatalf("%s: %s", str, err)
+		}
+	}
+
+	bad := []string{"fastcdc-4096-1024-16384", "fastcdc-1024-4096", "fastcdc-avg:1024-4096-16384", "fastcdc-x", "fastcdc-1024-4096-2097152", "fastcdc-1048576"}
+	for _, str := range bad {
+		if _, err := FromString(bytes.NewReader(nil), str); err == nil {
+			t.Fatalf("%s: expected an error", str)
+		}
+	}
+}
+
+func BenchmarkFastCDC(b *testing.B) {
+	benchmarkSplitter(b, func(r io.Reader) Splitter {
+		s, err := NewFastCDC(r, 64<<10, 256<<10, 1<<20)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return s
+	})
+}
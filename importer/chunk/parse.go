@@ -24,6 +24,12 @@ func FromString(r io.Reader, chunker string) (Splitter, error) {
 	case strings.HasPrefix(chunker, "rabin"):
 		return parseRabinString(r, chunker)
 
+	case chunker == "buzhash":
+		return NewBuzhash(r), nil
+
+	case strings.HasPrefix(chunker, "fastcdc"):
+		return parseFastCDCString(r, chunker)
+
 	default:
 		return nil, fmt.Errorf("unrecognized chunker option: %s", chunker)
 	}
@@ -41,36 +47,69 @@ func parseRabinString(r io.Reader, chunker string) (Splitter, error) {
 		}
 		return NewRabin(r, uint64(size)), nil
 	case 4:
-		sub := strings.Split(parts[1], ":")
-		if len(sub) > 1 && sub[0] != "min" {
-			return nil, errors.New("first label must be min")
-		}
-		min, err := strconv.Atoi(sub[len(sub)-1])
+		min, avg, max, err := parseMinAvgMax(parts[1:])
 		if err != nil {
 			return nil, err
 		}
 
-		sub = strings.Split(parts[2], ":")
-		if len(sub) > 1 && sub[0] != "avg" {
-			log.Error("sub == ", sub)
-			return nil, errors.New("second label must be avg")
-		}
-		avg, err := strconv.Atoi(sub[len(sub)-1])
+		return NewRabinMinMax(r, uint64(min), uint64(avg), uint64(max)), nil
+	default:
+		return nil, errors.New("incorrect format (expected 'rabin' 'rabin-[avg]' or 'rabin-[min]-[avg]-[max]'")
+	}
+}
+
+func parseFastCDCString(r io.Reader, chunker string) (Splitter, error) {
+	parts := strings.Split(chunker, "-")
+	switch len(parts) {
+	case 1:
+		avg := int(DefaultBlockSize)
+		return NewFastCDC(r, avg/4, avg, avg*4)
+	case 2:
+		avg, err := strconv.Atoi(parts[1])
 		if err != nil {
 			return nil, err
 		}
-
-		sub = strings.Split(parts[3], ":")
-		if len(sub) > 1 && sub[0] != "max" {
-			return nil, errors.New("final label must be max")
-		}
-		max, err := strconv.Atoi(sub[len(sub)-1])
+		return NewFastCDC(r, avg/4, avg, avg*4)
+	case 4:
+		min, avg, max, err := parseMinAvgMax(parts[1:])
 		if err != nil {
 			return nil, err
 		}
-
-		return NewRabinMinMax(r, uint64(min), uint64(avg), uint64(max)), nil
+		return NewFastCDC(r, min, avg, max)
 	default:
-		return nil, errors.New("incorrect format (expected 'rabin' 'rabin-[avg]' or 'rabin-[min]-[avg]-[max]'")
+		return nil, errors.New("incorrect format (expected 'fastcdc' 'fastcdc-[avg]' or 'fastcdc-[min]-[avg]-[max]'")
+	}
+}
+
+// parseMinAvgMax parses the three sizes of a content-defined chunker
+// string, each optionally labeled as in 'min:1024'.
+func parseMinAvgMax(parts []string) (min, avg, max int, err error) {
+	sub := strings.Split(parts[0], ":")
+	if len(sub) > 1 && sub[0] != "min" {
+		return 0, 0, 0, errors.New("first label must be min")
+	}
+	min, err = strconv.Atoi(sub[len(sub)-1])
+	if err != nil {
+		return 0, 0, 0, err
 	}
+
+	sub = strings.Split(parts[1], ":")
+	if len(sub) > 1 && sub[0] != "avg" {
+		return 0, 0, 0, errors.New("second label must be avg")
+	}
+	avg, err = strconv.Atoi(sub[len(sub)-1])
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	sub = strings.Split(parts[2], ":")
+	if len(sub) > 1 && sub[0] != "max" {
+		return 0, 0, 0, errors.New("final label must be max")
+	}
+	max, err = strconv.Atoi(sub[len(sub)-1])
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return min, avg, max, nil
 }
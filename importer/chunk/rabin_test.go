@@ -78,3 +78,9 @@ func TestRabinChunkReuse(t *testing.T) {
 		t.Log("too many spare chunks made")
 	}
 }
+
+func BenchmarkRabin(b *testing.B) {
+	benchmarkSplitter(b, func(r io.Reader) Splitter {
+		return NewRabin(r, uint64(DefaultBlockSize))
+	})
+}
@@ -11,6 +11,10 @@ var log = logging.Logger("chunk")
 
 var DefaultBlockSize int64 = 1024 * 256
 
+// ChunkSizeLimit is the largest chunk a splitter may be configured to emit,
+// the size limit of the blocks the importer builds.
+const ChunkSizeLimit = 1048576 // 1 MB
+
 type Splitter interface {
 	Reader() io.Reader
 	NextBytes() ([]byte, error)
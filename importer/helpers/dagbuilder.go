@@ -9,6 +9,7 @@ import (
 	dag "github.com/ipfs/go-ipfs/merkledag"
 
 	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
 // DagBuilderHelper wraps together a bunch of objects needed to
@@ -23,6 +24,8 @@ type DagBuilderHelper struct {
 	batch     *dag.Batch
 	fullPath  string
 	stat      os.FileInfo
+	prefix    *cid.Prefix
+	inline    int
 }
 
 type DagBuilderParams struct {
@@ -35,6 +38,15 @@ type DagBuilderParams struct {
 
 	// DAGService to write blocks to (required)
 	Dagserv dag.DAGService
+
+	// Prefix sets the CID version and hash function of the created nodes,
+	// CIDv0 with sha2-256 if nil
+	Prefix *cid.Prefix
+
+	// InlineLimit is the size up to which nodes are inlined in their cid
+	// with the identity hash, instead of being stored as separate blocks.
+	// Zero disables inlining.
+	InlineLimit int
 }
 
 // Generate a new DagBuilderHelper from the given params, which data source comes
@@ -46,6 +58,8 @@ func (dbp *DagBuilderParams) New(spl chunk.Splitter) *DagBuilderHelper {
 		rawLeaves: dbp.RawLeaves,
 		maxlinks:  dbp.Maxlinks,
 		batch:     dbp.Dagserv.Batch(),
+		prefix:    dbp.Prefix,
+		inline:    dbp.InlineLimit,
 	}
 	if fi, ok := spl.Reader().(files.FileInfo); ok {
 		db.fullPath = fi.FullPath()
@@ -134,17 +148,44 @@ func (db *DagBuilderHelper) GetNextDataNode() (*UnixfsNode, error) {
 	}
 
 	if db.rawLeaves {
+		inline := db.inline > 0 && len(data) <= db.inline
+		if db.prefix == nil && !inline {
+			return &UnixfsNode{
+				rawnode: dag.NewRawNode(data),
+				raw:     true,
+			}, nil
+		}
+
+		prefix := dag.InlineCidPrefix(cid.Raw)
+		if !inline {
+			prefix = *db.prefix
+		}
+		rawnode, err := dag.NewRawNodeWPrefix(data, prefix)
+		if err != nil {
+			return nil, err
+		}
 		return &UnixfsNode{
-			rawnode: dag.NewRawNode(data),
+			rawnode: rawnode,
 			raw:     true,
 		}, nil
 	} else {
 		blk := NewUnixfsBlock()
+		blk.SetPrefix(db.prefix)
+		blk.SetInlineLimit(db.inline)
 		blk.SetData(data)
 		return blk, nil
 	}
 }
 
+// NewUnixfsNode creates a new Unixfs node to represent a file, with the CID
+// prefix and inline limit of the builder.
+func (db *DagBuilderHelper) NewUnixfsNode() *UnixfsNode {
+	n := NewUnixfsNode()
+	n.SetPrefix(db.prefix)
+	n.SetInlineLimit(db.inline)
+	return n
+}
+
 func (db *DagBuilderHelper) SetPosInfo(node *UnixfsNode, offset uint64) {
 	if db.stat != nil {
 		node.SetPosInfo(offset, db.fullPath, db.stat)
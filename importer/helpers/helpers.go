@@ -11,10 +11,11 @@ import (
 	ft "github.com/ipfs/go-ipfs/unixfs"
 
 	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
 // BlockSizeLimit specifies the maximum size an imported block can have.
-var BlockSizeLimit = 1048576 // 1 MB
+var BlockSizeLimit = chunk.ChunkSizeLimit
 
 // rough estimates on expected sizes
 var roughDataBlockSize = chunk.DefaultBlockSize
@@ -136,6 +137,17 @@ func (n *UnixfsNode) RemoveChild(index int, dbh *DagBuilderHelper) {
 	n.node.SetLinks(append(n.node.Links()[:index], n.node.Links()[index+1:]...))
 }
 
+// SetPrefix sets the CID version and hash function of the node, which
+// default to CIDv0 with sha2-256 when prefix is nil.
+func (n *UnixfsNode) SetPrefix(prefix *cid.Prefix) {
+	n.node.SetPrefix(prefix)
+}
+
+// SetInlineLimit sets the size up to which the node is inlined in its cid.
+func (n *UnixfsNode) SetInlineLimit(limit int) {
+	n.node.SetInlineLimit(limit)
+}
+
 func (n *UnixfsNode) SetData(data []byte) {
 	n.ufmt.Data = data
 }
@@ -7,13 +7,18 @@ import (
 	"io/ioutil"
 	"testing"
 
+	bal "github.com/ipfs/go-ipfs/importer/balanced"
 	chunk "github.com/ipfs/go-ipfs/importer/chunk"
+	h "github.com/ipfs/go-ipfs/importer/helpers"
+	trickle "github.com/ipfs/go-ipfs/importer/trickle"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	mh "gx/ipfs/QmYDds3421prZgqKbLpEK7T9Aa2eVdQ7o3YarX1LVLdP2J/go-multihash"
 	u "gx/ipfs/Qmb912gdngC1UWwTkhuW8knyRbcWeu5kqkxBpveLmW8bSr/go-ipfs-util"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
 func getBalancedDag(t testing.TB, size int64, blksize int64) (node.Node, dag.DAGService) {
@@ -62,6 +67,59 @@ func TestBalancedDag(t *testing.T) {
 	}
 }
 
+func TestPrefixPropagates(t *testing.T) {
+	pref := dag.V1CidPrefix()
+	pref.MhType = mh.SHA2_512
+
+	layouts := map[string]func(*h.DagBuilderHelper) (node.Node, error){
+		"balanced": bal.BalancedLayout,
+		"trickle":  trickle.TrickleLayout,
+	}
+	for name, layout := range layouts {
+		for _, raw := range []bool{false, true} {
+			ds := mdtest.Mock()
+			buf := make([]byte, 100000)
+			u.NewTimeSeededRand().Read(buf)
+
+			dbp := h.DagBuilderParams{
+				Dagserv:   ds,
+				Maxlinks:  4,
+				RawLeaves: raw,
+				Prefix:    &pref,
+			}
+			nd, err := layout(dbp.New(chunk.NewSizeSplitter(bytes.NewReader(buf), 512)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = dag.EnumerateChildren(context.Background(), ds, nd.Cid(), func(c *cid.Cid) bool {
+				if c.Prefix().Version != 1 || c.Prefix().MhType != mh.SHA2_512 {
+					t.Fatalf("%s, raw leaves %t: node %s does not use the prefix", name, raw, c)
+				}
+				return true
+			}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nd.Cid().Prefix().MhType != mh.SHA2_512 {
+				t.Fatalf("%s: root does not use the prefix", name)
+			}
+
+			dr, err := uio.NewDagReader(context.Background(), nd, ds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := ioutil.ReadAll(dr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, buf) {
+				t.Fatal("bad read")
+			}
+		}
+	}
+}
+
 func BenchmarkBalancedReadSmallBlock(b *testing.B) {
 	b.StopTimer()
 	nbytes := int64(10000000)
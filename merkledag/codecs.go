@@ -0,0 +1,110 @@
+package merkledag
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	ipldcbor "gx/ipfs/QmbuuwTd9x4NReZ7sxtiKk7wFcfDUo54MfWBdtF5MRCPGR/go-ipld-cbor"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// DecodeBlockFunc decodes a block of a given codec into a node.
+type DecodeBlockFunc func(blocks.Block) (node.Node, error)
+
+var (
+	codecsLk sync.RWMutex
+	codecs   = make(map[uint64]DecodeBlockFunc)
+)
+
+func init() {
+	RegisterCodec(cid.DagProtobuf, decodeProtobufBlock)
+	RegisterCodec(cid.Raw, decodeRawBlock)
+	RegisterCodec(cid.DagCBOR, decodeCborBlock)
+}
+
+// RegisterCodec makes the DAG service decode blocks whose CID has the given
+// codec with dec. It is meant to be called at init time by the packages
+// implementing codecs, and replaces any decoder already registered for the
+// codec.
+func RegisterCodec(codec uint64, dec DecodeBlockFunc) {
+	codecsLk.Lock()
+	defer codecsLk.Unlock()
+	codecs[codec] = dec
+}
+
+// DecodeBlock decodes a block into a node, with the decoder registered for
+// the codec of its cid.
+func DecodeBlock(b blocks.Block) (node.Node, error) {
+	c := b.Cid()
+
+	codecsLk.RLock()
+	dec, ok := codecs[c.Type()]
+	codecsLk.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unrecognized object type: %d", c.Type())
+	}
+
+	return dec(b)
+}
+
+func decodeProtobufBlock(b blocks.Block) (node.Node, error) {
+	c := b.Cid()
+
+	decnd, err := DecodeProtobuf(b.RawData())
+	if err != nil {
+		if strings.Contains(err.Error(), "Unmarshal failed") {
+			return nil, fmt.Errorf("The block referred to by '%s' was not a valid merkledag node", c)
+		}
+		return nil, fmt.Errorf("Failed to decode Protocol Buffers: %v", err)
+	}
+
+	decnd.cached = c
+	decnd.Prefix = c.Prefix()
+	return decnd, nil
+}
+
+func decodeRawBlock(b blocks.Block) (node.Node, error) {
+	return &RawNode{b}, nil
+}
+
+func decodeCborBlock(b blocks.Block) (node.Node, error) {
+	nd, err := ipldcbor.Decode(b.RawData())
+	if err != nil {
+		return nil, err
+	}
+
+	// ipldcbor always hashes with sha2-256
+	if !nd.Cid().Equals(b.Cid()) {
+		return &cborNode{Node: nd, cid: b.Cid()}, nil
+	}
+	return nd, nil
+}
+
+// cborNode is a cbor node keeping the cid of the block it was decoded from,
+// for blocks hashed with another function than the default one.
+type cborNode struct {
+	*ipldcbor.Node
+	cid *cid.Cid
+}
+
+func (n *cborNode) Cid() *cid.Cid {
+	return n.cid
+}
+
+func (n *cborNode) String() string {
+	return fmt.Sprintf("[Block %s]", n.cid)
+}
+
+func (n *cborNode) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"block": n.cid.String(),
+	}
+}
+
+func (n *cborNode) Copy() node.Node {
+	return &cborNode{Node: n.Node.Copy().(*ipldcbor.Node), cid: n.cid}
+}
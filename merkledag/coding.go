@@ -85,10 +85,7 @@ func (n *ProtoNode) EncodeProtobuf(force bool) ([]byte, error) {
 	}
 
 	if n.cached == nil {
-		if n.Prefix.Codec == 0 { // unset
-			n.Prefix = defaultCidPrefix
-		}
-		c, err := n.Prefix.Sum(n.encoded)
+		c, err := n.sumPrefix(n.encoded).Sum(n.encoded)
 		if err != nil {
 			return nil, err
 		}
@@ -4,7 +4,6 @@ package merkledag
 import (
 	"context"
 	"fmt"
-	"strings"
 	"sync"
 
 	blocks "github.com/ipfs/go-ipfs/blocks"
@@ -13,7 +12,6 @@ import (
 
 	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
-	ipldcbor "gx/ipfs/QmbuuwTd9x4NReZ7sxtiKk7wFcfDUo54MfWBdtF5MRCPGR/go-ipld-cbor"
 	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
@@ -86,32 +84,7 @@ func (n *dagService) Get(ctx context.Context, c *cid.Cid) (node.Node, error) {
 		return nil, fmt.Errorf("Failed to get block for %s: %v", c, err)
 	}
 
-	return decodeBlock(b)
-}
-
-func decodeBlock(b blocks.Block) (node.Node, error) {
-	c := b.Cid()
-
-	switch c.Type() {
-	case cid.DagProtobuf:
-		decnd, err := DecodeProtobuf(b.RawData())
-		if err != nil {
-			if strings.Contains(err.Error(), "Unmarshal failed") {
-				return nil, fmt.Errorf("The block referred to by '%s' was not a valid merkledag node", c)
-			}
-			return nil, fmt.Errorf("Failed to decode Protocol Buffers: %v", err)
-		}
-
-		decnd.cached = b.Cid()
-		decnd.Prefix = b.Cid().Prefix()
-		return decnd, nil
-	case cid.Raw:
-		return NewRawNode(b.RawData()), nil
-	case cid.DagCBOR:
-		return ipldcbor.Decode(b.RawData())
-	default:
-		return nil, fmt.Errorf("unrecognized object type: %s", c.Type())
-	}
+	return DecodeBlock(b)
 }
 
 func (n *dagService) GetLinks(ctx context.Context, c *cid.Cid) ([]*node.Link, error) {
@@ -177,7 +150,7 @@ func (ds *dagService) GetMany(ctx context.Context, keys []*cid.Cid) <-chan *Node
 					return
 				}
 
-				nd, err := decodeBlock(b)
+				nd, err := DecodeBlock(b)
 				if err != nil {
 					out <- &NodeOption{Err: err}
 					return
@@ -24,6 +24,7 @@ import (
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	mh "gx/ipfs/QmYDds3421prZgqKbLpEK7T9Aa2eVdQ7o3YarX1LVLdP2J/go-multihash"
 	u "gx/ipfs/Qmb912gdngC1UWwTkhuW8knyRbcWeu5kqkxBpveLmW8bSr/go-ipfs-util"
 	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
@@ -504,3 +505,275 @@ func TestCidRawDoesnNeedData(t *testing.T) {
 		t.Fatal("raw node shouldn't have any links")
 	}
 }
+
+func TestPrefixFor(t *testing.T) {
+	cases := []struct {
+		hash    string
+		version int
+		expVer  uint64
+		expType int
+		fail    bool
+	}{
+		{"", -1, 0, mh.SHA2_256, false},
+		{"sha2-256", 1, 1, mh.SHA2_256, false},
+		{"SHA2-512", -1, 1, mh.SHA2_512, false},
+		{"sha2-512", 0, 0, 0, true},
+		{"nosuchhash", -1, 0, 0, true},
+		{"id", 1, 0, 0, true},
+		{"identity", -1, 0, 0, true},
+		{"", 2, 0, 0, true},
+	}
+
+	for _, c := range cases {
+		pref, err := PrefixFor(c.hash, c.version)
+		if c.fail {
+			if err == nil {
+				t.Fatalf("%q, %d: expected an error", c.hash, c.version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pref.Version != c.expVer || pref.MhType != c.expType || pref.Codec != cid.DagProtobuf {
+			t.Fatalf("%q, %d: unexpected prefix %v", c.hash, c.version, pref)
+		}
+	}
+}
+
+func TestSetPrefixSurvivesCopy(t *testing.T) {
+	nd := NodeWithData([]byte("fooooo"))
+	pref := V1CidPrefix()
+	pref.MhType = mh.SHA2_512
+	nd.SetPrefix(&pref)
+
+	c := nd.Cid()
+	if c.Prefix().Version != 1 || c.Prefix().MhType != mh.SHA2_512 {
+		t.Fatal("cid does not use the prefix of the node")
+	}
+	if !nd.Copy().Cid().Equals(c) {
+		t.Fatal("copy of the node has a different cid")
+	}
+
+	nd.SetPrefix(nil)
+	if nd.Cid().Prefix().Version != 0 {
+		t.Fatal("a nil prefix should restore CIDv0")
+	}
+}
+
+func TestInlineLimit(t *testing.T) {
+	ds := NewDAGService(dstest.Bserv())
+
+	small := NodeWithData([]byte("tiny"))
+	small.SetInlineLimit(32)
+	if small.Cid().Prefix().MhType != mh.ID {
+		t.Fatal("small node was not inlined")
+	}
+
+	big := NodeWithData(bytes.Repeat([]byte("a"), 100))
+	big.SetInlineLimit(32)
+	if big.Cid().Prefix().MhType != mh.SHA2_256 {
+		t.Fatal("big node was inlined")
+	}
+
+	if err := big.AddNodeLink("small", small); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.Add(small); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.Add(big); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ds.Get(context.Background(), big.Links()[0].Cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.(*ProtoNode).Data(), []byte("tiny")) {
+		t.Fatal("inlined node has the wrong data")
+	}
+
+	// an empty node is not inlined without a limit
+	if new(ProtoNode).Cid().Prefix().MhType == mh.ID {
+		t.Fatal("node inlined without a limit")
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	const codec = 0x300001 // private use range
+
+	data := []byte("custom codec data")
+	h, err := mh.Sum(data, mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk, err := blocks.NewBlockWithCid(data, cid.NewCidV1(codec, h))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecodeBlock(blk); err == nil {
+		t.Fatal("expected an error decoding a block of an unknown codec")
+	}
+
+	RegisterCodec(codec, func(b blocks.Block) (node.Node, error) {
+		return &RawNode{b}, nil
+	})
+
+	ds := dstest.Mock()
+	if _, err := ds.Add(&RawNode{blk}); err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := ds.Get(context.Background(), blk.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(blk.Cid()) || !bytes.Equal(nd.RawData(), data) {
+		t.Fatal("block decoded with the registered codec differs")
+	}
+}
+
+func TestWalkSelector(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+
+	// root -a-> A -x-> AX -> leaf
+	//            -y-> AY
+	//      -b-> B -x-> BX
+	leaf := NodeWithData([]byte("leaf"))
+	ax := NodeWithData([]byte("ax"))
+	ay := NodeWithData([]byte("ay"))
+	bx := NodeWithData([]byte("bx"))
+	a := NodeWithData([]byte("a"))
+	b := NodeWithData([]byte("b"))
+	root := NodeWithData([]byte("root"))
+
+	for _, l := range []struct {
+		from *ProtoNode
+		name string
+		to   *ProtoNode
+	}{
+		{ax, "", leaf},
+		{a, "x", ax},
+		{a, "y", ay},
+		{b, "x", bx},
+		{root, "a", a},
+		{root, "b", b},
+	} {
+		if err := l.from.AddNodeLinkClean(l.name, l.to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, nd := range []*ProtoNode{leaf, ax, ay, bx, a, b, root} {
+		if _, err := ds.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := map[string]string{
+		leaf.Cid().KeyString(): "leaf",
+		ax.Cid().KeyString():   "ax",
+		ay.Cid().KeyString():   "ay",
+		bx.Cid().KeyString():   "bx",
+		a.Cid().KeyString():    "a",
+		b.Cid().KeyString():    "b",
+	}
+
+	for _, tc := range []struct {
+		depth       int
+		path, links string
+		exp         string
+	}{
+		{-1, "", "", "a ax leaf ay b bx"},
+		{1, "", "", "a b"},
+		{2, "", "", "a ax ay b bx"},
+		{-1, "a/x", "", "a ax leaf"},
+		{-1, "*/x", "", "a ax leaf b bx"},
+		{-1, "", "[ax]", "a ax leaf"},
+		{1, "a/x", "", "a"},
+	} {
+		sel, err := NewSelector(tc.depth, tc.path, tc.links)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		err = Walk(ctx, ds, root.Cid(), sel, func(_ *cid.Cid, lnk *node.Link, _ int) (bool, error) {
+			got = append(got, names[lnk.Cid.KeyString()])
+			return true, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := strings.Join(got, " "); s != tc.exp {
+			t.Fatalf("depth %d, path %q, links %q: got %q, expected %q", tc.depth, tc.path, tc.links, s, tc.exp)
+		}
+	}
+
+	if _, err := NewSelector(-1, "[", ""); err == nil {
+		t.Fatal("expected an error for a malformed pattern")
+	}
+}
+
+func TestWalkShared(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+
+	// root -a-> P -> S -> leaf
+	//      -b-> S
+	leaf := NodeWithData([]byte("leaf"))
+	s := NodeWithData([]byte("s"))
+	p := NodeWithData([]byte("p"))
+	root := NodeWithData([]byte("root"))
+	for _, l := range []struct {
+		from *ProtoNode
+		name string
+		to   *ProtoNode
+	}{
+		{s, "", leaf},
+		{p, "", s},
+		{root, "a", p},
+		{root, "b", s},
+	} {
+		if err := l.from.AddNodeLinkClean(l.name, l.to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, nd := range []*ProtoNode{leaf, s, p, root} {
+		if _, err := ds.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := map[string]string{
+		leaf.Cid().KeyString(): "leaf",
+		s.Cid().KeyString():    "s",
+		p.Cid().KeyString():    "p",
+	}
+
+	for _, tc := range []struct {
+		depth int
+		exp   string
+	}{
+		// s is only gone through once
+		{-1, "p s leaf s"},
+		// s is reached without depth left under p, then again from root
+		{2, "p s s leaf"},
+		// s is gone through again from root, with more depth left
+		{3, "p s leaf s leaf"},
+	} {
+		var got []string
+		err := Walk(ctx, ds, root.Cid(), &Selector{MaxDepth: tc.depth}, func(_ *cid.Cid, lnk *node.Link, _ int) (bool, error) {
+			got = append(got, names[lnk.Cid.KeyString()])
+			return true, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := strings.Join(got, " "); s != tc.exp {
+			t.Fatalf("depth %d: got %q, expected %q", tc.depth, s, tc.exp)
+		}
+	}
+}
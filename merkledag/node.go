@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
 	mh "gx/ipfs/QmYDds3421prZgqKbLpEK7T9Aa2eVdQ7o3YarX1LVLdP2J/go-multihash"
@@ -13,6 +14,10 @@ import (
 var ErrNotProtobuf = fmt.Errorf("expected protobuf dag node")
 var ErrLinkNotFound = fmt.Errorf("no link by that name")
 
+// ErrIdentityHash is returned when asking for the identity hash function,
+// which would put whole nodes, whatever their size, in their CIDs.
+var ErrIdentityHash = fmt.Errorf("the identity hash puts whole nodes in their CIDs, use --inline to inline small ones")
+
 // Node represents a node in the IPFS Merkle DAG.
 // nodes have opaque data and a set of navigable links.
 type ProtoNode struct {
@@ -26,6 +31,9 @@ type ProtoNode struct {
 
 	// Prefix specifies cid version and hashing function
 	Prefix cid.Prefix
+
+	// nodes encoded in at most inlineLimit bytes are inlined in their cid
+	inlineLimit int
 }
 
 var defaultCidPrefix = cid.Prefix{
@@ -35,6 +43,83 @@ var defaultCidPrefix = cid.Prefix{
 	Version:  0,
 }
 
+// V0CidPrefix returns the prefix of CIDv0 nodes, hashed with sha2-256.
+func V0CidPrefix() cid.Prefix {
+	return defaultCidPrefix
+}
+
+// V1CidPrefix returns the prefix of CIDv1 nodes hashed with sha2-256.
+func V1CidPrefix() cid.Prefix {
+	return cid.Prefix{
+		Codec:    cid.DagProtobuf,
+		MhLength: -1,
+		MhType:   mh.SHA2_256,
+		Version:  1,
+	}
+}
+
+// InlineCidPrefix returns the prefix of cids with the given codec that
+// inline their data with the identity multihash.
+func InlineCidPrefix(codec uint64) cid.Prefix {
+	return cid.Prefix{
+		Codec:    codec,
+		MhLength: -1,
+		MhType:   mh.ID,
+		Version:  1,
+	}
+}
+
+// PrefixForCidVersion returns the prefix of nodes with the given CID version.
+func PrefixForCidVersion(version int) (cid.Prefix, error) {
+	switch version {
+	case 0:
+		return V0CidPrefix(), nil
+	case 1:
+		return V1CidPrefix(), nil
+	default:
+		return cid.Prefix{}, fmt.Errorf("unknown CID version: %d", version)
+	}
+}
+
+// PrefixFor returns the prefix of nodes hashed with the named multihash
+// function, in the given CID version. A negative version selects CIDv0 for
+// sha2-256, the only function CIDv0 supports, and CIDv1 otherwise. An empty
+// hash name selects sha2-256. The identity hash is refused, nodes are only
+// inlined below a size limit, with InlineCidPrefix.
+func PrefixFor(hash string, version int) (cid.Prefix, error) {
+	mhType := mh.SHA2_256
+	if hash != "" {
+		t, ok := mh.Names[strings.ToLower(hash)]
+		if !ok {
+			return cid.Prefix{}, fmt.Errorf("unrecognized hash function: %s", hash)
+		}
+		if t == mh.ID {
+			return cid.Prefix{}, ErrIdentityHash
+		}
+		mhType = t
+	}
+
+	if version < 0 {
+		version = 0
+		if mhType != mh.SHA2_256 {
+			version = 1
+		}
+	}
+
+	prefix, err := PrefixForCidVersion(version)
+	if err != nil {
+		return cid.Prefix{}, err
+	}
+
+	if mhType != mh.SHA2_256 {
+		if version == 0 {
+			return cid.Prefix{}, fmt.Errorf("CIDv0 only supports sha2-256, use CIDv1 for %s", hash)
+		}
+		prefix.MhType = mhType
+	}
+	return prefix, nil
+}
+
 type LinkSlice []*node.Link
 
 func (ls LinkSlice) Len() int           { return len(ls) }
@@ -158,6 +243,9 @@ func (n *ProtoNode) Copy() node.Node {
 		nnode.links = make([]*node.Link, len(n.links))
 		copy(nnode.links, n.links)
 	}
+
+	nnode.Prefix = n.Prefix
+	nnode.inlineLimit = n.inlineLimit
 	return nnode
 }
 
@@ -254,16 +342,50 @@ func (n *ProtoNode) MarshalJSON() ([]byte, error) {
 	return json.Marshal(out)
 }
 
-func (n *ProtoNode) Cid() *cid.Cid {
-	if n.encoded != nil && n.cached != nil {
-		return n.cached
+// SetPrefix sets the CID version and hash function of the node. A nil
+// prefix restores the default, CIDv0 with sha2-256.
+func (n *ProtoNode) SetPrefix(prefix *cid.Prefix) {
+	if prefix == nil {
+		n.Prefix = defaultCidPrefix
+	} else {
+		n.Prefix = *prefix
+		n.Prefix.Codec = cid.DagProtobuf
 	}
+	n.encoded = nil
+	n.cached = nil
+}
 
-	if n.Prefix.Codec == 0 {
+// SetInlineLimit makes the node inline its data in its cid, using the
+// identity hash instead of the one of its prefix, when it encodes to at most
+// limit bytes. A limit of zero disables inlining.
+func (n *ProtoNode) SetInlineLimit(limit int) {
+	n.inlineLimit = limit
+	n.cached = nil
+}
+
+// InlineLimit returns the size up to which the node is inlined in its cid.
+func (n *ProtoNode) InlineLimit() int {
+	return n.inlineLimit
+}
+
+// sumPrefix returns the prefix the node, encoded to data, is hashed with.
+func (n *ProtoNode) sumPrefix(data []byte) cid.Prefix {
+	if n.Prefix.Codec == 0 { // unset
 		n.Prefix = defaultCidPrefix
 	}
+	if n.inlineLimit > 0 && len(data) <= n.inlineLimit {
+		return InlineCidPrefix(cid.DagProtobuf)
+	}
+	return n.Prefix
+}
+
+func (n *ProtoNode) Cid() *cid.Cid {
+	if n.encoded != nil && n.cached != nil {
+		return n.cached
+	}
 
-	c, err := n.Prefix.Sum(n.RawData())
+	data := n.RawData()
+	c, err := n.sumPrefix(data).Sum(data)
 	if err != nil {
 		// programmer error
 		panic(err)
@@ -20,6 +20,26 @@ func NewRawNode(data []byte) *RawNode {
 	return &RawNode{blk}
 }
 
+// NewRawNodeWPrefix creates a raw node hashed with the function of the given
+// prefix. Raw nodes only exist as CIDv1, so the version is raised if needed.
+func NewRawNodeWPrefix(data []byte, prefix cid.Prefix) (*RawNode, error) {
+	prefix.Codec = cid.Raw
+	if prefix.Version == 0 {
+		prefix.Version = 1
+	}
+
+	c, err := prefix.Sum(data)
+	if err != nil {
+		return nil, err
+	}
+
+	blk, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return &RawNode{blk}, nil
+}
+
 func (rn *RawNode) Links() []*node.Link {
 	return nil
 }
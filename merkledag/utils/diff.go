@@ -3,6 +3,7 @@ package dagutils
 import (
 	"fmt"
 	"path"
+	"strings"
 
 	dag "github.com/ipfs/go-ipfs/merkledag"
 
@@ -14,6 +15,7 @@ const (
 	Add = iota
 	Remove
 	Mod
+	Rename
 )
 
 type Change struct {
@@ -21,6 +23,9 @@ type Change struct {
 	Path   string
 	Before *cid.Cid
 	After  *cid.Cid
+
+	// From is the path a renamed entry had before being moved to Path
+	From string `json:",omitempty"`
 }
 
 func (c *Change) String() string {
@@ -31,6 +36,8 @@ func (c *Change) String() string {
 		return fmt.Sprintf("Removed %s from %s", c.Before.String(), c.Path)
 	case Mod:
 		return fmt.Sprintf("Changed %s to %s at %s", c.Before.String(), c.After.String(), c.Path)
+	case Rename:
+		return fmt.Sprintf("Moved %s from %s to %s", c.After.String(), c.From, c.Path)
 	default:
 		panic("nope")
 	}
@@ -46,12 +53,7 @@ func ApplyChange(ctx context.Context, ds dag.DAGService, nd *dag.ProtoNode, cs [
 				return nil, err
 			}
 
-			childpb, ok := child.(*dag.ProtoNode)
-			if !ok {
-				return nil, dag.ErrNotProtobuf
-			}
-
-			err = e.InsertNodeAtPath(ctx, c.Path, childpb, nil)
+			err = e.InsertNodeAtPath(ctx, c.Path, child, nil)
 			if err != nil {
 				return nil, err
 			}
@@ -62,8 +64,12 @@ func ApplyChange(ctx context.Context, ds dag.DAGService, nd *dag.ProtoNode, cs [
 				return nil, err
 			}
 
-		case Mod:
-			err := e.RmLink(ctx, c.Path)
+		case Mod, Rename:
+			rm := c.Path
+			if c.Type == Rename {
+				rm = c.From
+			}
+			err := e.RmLink(ctx, rm)
 			if err != nil {
 				return nil, err
 			}
@@ -72,12 +78,7 @@ func ApplyChange(ctx context.Context, ds dag.DAGService, nd *dag.ProtoNode, cs [
 				return nil, err
 			}
 
-			childpb, ok := child.(*dag.ProtoNode)
-			if !ok {
-				return nil, dag.ErrNotProtobuf
-			}
-
-			err = e.InsertNodeAtPath(ctx, c.Path, childpb, nil)
+			err = e.InsertNodeAtPath(ctx, c.Path, child, nil)
 			if err != nil {
 				return nil, err
 			}
@@ -167,25 +168,89 @@ type Conflict struct {
 	B *Change
 }
 
+// paths returns the paths a change touches: both ends of a rename, or its
+// path otherwise.
+func (c *Change) paths() []string {
+	if c.Type == Rename {
+		return []string{c.From, c.Path}
+	}
+	return []string{c.Path}
+}
+
+// same returns whether both changes do the same thing.
+func (c *Change) same(o *Change) bool {
+	return c.Type == o.Type && c.Path == o.Path && c.From == o.From &&
+		cidsEqual(c.Before, o.Before) && cidsEqual(c.After, o.After)
+}
+
+func cidsEqual(a, b *cid.Cid) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equals(b)
+}
+
+// parentPath returns the path of the directory holding p, or "" for the
+// entries of the root.
+func parentPath(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// MergeDiffs merges two diffs made from the same base. A change of b
+// touching a path changed by a, or a path above or under one, conflicts
+// with that change of a. The changes of a and the other changes of b are
+// returned, leaving out those of b identical to one of a.
 func MergeDiffs(a, b []*Change) ([]*Change, []Conflict) {
 	var out []*Change
 	var conflicts []Conflict
+
+	// paths holds the paths changed by a, and under the directories
+	// holding them
 	paths := make(map[string]*Change)
+	under := make(map[string]*Change)
 	for _, c := range a {
-		paths[c.Path] = c
+		for _, p := range c.paths() {
+			paths[p] = c
+			for d := parentPath(p); d != ""; d = parentPath(d) {
+				under[d] = c
+			}
+		}
+	}
+
+	conflicting := func(c *Change) *Change {
+		for _, p := range c.paths() {
+			if ca, ok := paths[p]; ok {
+				return ca
+			}
+			if ca, ok := under[p]; ok {
+				return ca
+			}
+			for d := parentPath(p); d != ""; d = parentPath(d) {
+				if ca, ok := paths[d]; ok {
+					return ca
+				}
+			}
+		}
+		return nil
 	}
 
 	for _, c := range b {
-		if ca, ok := paths[c.Path]; ok {
+		ca := conflicting(c)
+		switch {
+		case ca == nil:
+			out = append(out, c)
+		case ca.same(c):
+		default:
 			conflicts = append(conflicts, Conflict{
 				A: ca,
 				B: c,
 			})
-		} else {
-			out = append(out, c)
 		}
 	}
-	for _, c := range paths {
+	for _, c := range a {
 		out = append(out, c)
 	}
 	return out, conflicts
@@ -0,0 +1,265 @@
+package dagutils
+
+import (
+	"context"
+	"path"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+)
+
+// Kinds of the entries of a FileChange
+const (
+	KindFile      = "file"
+	KindDirectory = "directory"
+	KindSymlink   = "symlink"
+	KindOther     = "other"
+)
+
+// FileChange is a change to a file, directory or symlink of a unixfs tree,
+// as found by DiffFiles.
+type FileChange struct {
+	Change
+
+	// Kind is the kind of the entry after the change, or before it for
+	// removals
+	Kind string
+
+	// SizeBefore and SizeAfter are the unixfs sizes of files
+	SizeBefore uint64 `json:",omitempty"`
+	SizeAfter  uint64 `json:",omitempty"`
+}
+
+// FileConflict is a pair of changes made to the same path by both sides of
+// a merge.
+type FileConflict struct {
+	A *FileChange
+	B *FileChange
+}
+
+// DiffFiles compares the unixfs trees under a and b, going down the
+// directories present on both sides, and calls f with every file, directory
+// or symlink added, removed, modified or renamed. Subtrees with the same cid
+// on both sides are skipped without being fetched.
+//
+// Modifications are reported as they are found. Additions and removals are
+// held until the whole tree has been compared, so that an entry removed at
+// one path and added with the same cid at another is reported as a single
+// rename.
+func DiffFiles(ctx context.Context, ds dag.DAGService, a, b node.Node, f func(*FileChange) error) error {
+	d := &fileDiff{ctx: ctx, ds: ds, f: f}
+	if err := d.diff("", a, b); err != nil {
+		return err
+	}
+	return d.flush()
+}
+
+type fileDiff struct {
+	ctx context.Context
+	ds  dag.DAGService
+	f   func(*FileChange) error
+
+	added   []*FileChange
+	removed []*FileChange
+}
+
+func (d *fileDiff) diff(p string, a, b node.Node) error {
+	if a.Cid().Equals(b.Cid()) {
+		return nil
+	}
+
+	ka, sa, err := kindOf(a)
+	if err != nil {
+		return err
+	}
+	kb, sb, err := kindOf(b)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case ka == KindDirectory && kb == KindDirectory:
+		return d.diffDirs(p, a, b)
+	case ka == kb || p == "":
+		return d.f(&FileChange{
+			Change:     Change{Type: Mod, Path: p, Before: a.Cid(), After: b.Cid()},
+			Kind:       kb,
+			SizeBefore: sa,
+			SizeAfter:  sb,
+		})
+	default:
+		// an entry changing kind is removed and added again
+		d.removed = append(d.removed, &FileChange{
+			Change:     Change{Type: Remove, Path: p, Before: a.Cid()},
+			Kind:       ka,
+			SizeBefore: sa,
+		})
+		d.added = append(d.added, &FileChange{
+			Change:    Change{Type: Add, Path: p, After: b.Cid()},
+			Kind:      kb,
+			SizeAfter: sb,
+		})
+		return nil
+	}
+}
+
+func (d *fileDiff) diffDirs(p string, a, b node.Node) error {
+	blinks := make(map[string]*node.Link)
+	for _, lnk := range b.Links() {
+		blinks[lnk.Name] = lnk
+	}
+
+	for _, la := range a.Links() {
+		lp := path.Join(p, la.Name)
+
+		lb, ok := blinks[la.Name]
+		delete(blinks, la.Name)
+		if !ok {
+			nd, err := la.GetNode(d.ctx, d.ds)
+			if err != nil {
+				return err
+			}
+			k, s, err := kindOf(nd)
+			if err != nil {
+				return err
+			}
+			d.removed = append(d.removed, &FileChange{
+				Change:     Change{Type: Remove, Path: lp, Before: la.Cid},
+				Kind:       k,
+				SizeBefore: s,
+			})
+			continue
+		}
+
+		if la.Cid.Equals(lb.Cid) {
+			continue
+		}
+
+		na, err := la.GetNode(d.ctx, d.ds)
+		if err != nil {
+			return err
+		}
+		nb, err := lb.GetNode(d.ctx, d.ds)
+		if err != nil {
+			return err
+		}
+		if err := d.diff(lp, na, nb); err != nil {
+			return err
+		}
+	}
+
+	// go through the links only in b in their order
+	for _, lb := range b.Links() {
+		if _, ok := blinks[lb.Name]; !ok {
+			continue
+		}
+
+		nd, err := lb.GetNode(d.ctx, d.ds)
+		if err != nil {
+			return err
+		}
+		k, s, err := kindOf(nd)
+		if err != nil {
+			return err
+		}
+		d.added = append(d.added, &FileChange{
+			Change:    Change{Type: Add, Path: path.Join(p, lb.Name), After: lb.Cid},
+			Kind:      k,
+			SizeAfter: s,
+		})
+	}
+	return nil
+}
+
+// flush reports the additions and removals held back, pairing those of the
+// same cid into renames.
+func (d *fileDiff) flush() error {
+	removed := make(map[string][]*FileChange)
+	for _, c := range d.removed {
+		k := c.Before.KeyString()
+		removed[k] = append(removed[k], c)
+	}
+
+	renamed := make(map[*FileChange]bool)
+	for _, c := range d.added {
+		k := c.After.KeyString()
+		if len(removed[k]) == 0 {
+			continue
+		}
+		from := removed[k][0]
+		removed[k] = removed[k][1:]
+
+		renamed[from] = true
+		c.Type = Rename
+		c.From = from.Path
+		c.Before = from.Before
+		c.SizeBefore = from.SizeBefore
+	}
+
+	for _, c := range d.removed {
+		if renamed[c] {
+			continue
+		}
+		if err := d.f(c); err != nil {
+			return err
+		}
+	}
+	for _, c := range d.added {
+		if err := d.f(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kindOf returns the kind of a unixfs node, and its size if it is a file.
+// Raw nodes are file contents.
+func kindOf(nd node.Node) (string, uint64, error) {
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return KindFile, uint64(len(nd.RawData())), nil
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return "", 0, err
+		}
+		switch fsn.Type {
+		case ft.TFile, ft.TRaw:
+			return KindFile, fsn.FileSize(), nil
+		case ft.TDirectory:
+			return KindDirectory, 0, nil
+		case ft.TSymlink:
+			return KindSymlink, 0, nil
+		}
+	}
+	return KindOther, 0, nil
+}
+
+// MergeFileDiffs merges two lists of file changes made from the same base
+// with MergeDiffs, returning the changes of both sides that do not touch
+// the same paths, and the pairs of those that do.
+func MergeFileDiffs(a, b []*FileChange) ([]*FileChange, []FileConflict) {
+	byChange := make(map[*Change]*FileChange)
+	changes := func(fcs []*FileChange) []*Change {
+		out := make([]*Change, len(fcs))
+		for i, fc := range fcs {
+			out[i] = &fc.Change
+			byChange[out[i]] = fc
+		}
+		return out
+	}
+
+	merged, conflicts := MergeDiffs(changes(a), changes(b))
+
+	out := make([]*FileChange, len(merged))
+	for i, c := range merged {
+		out[i] = byChange[c]
+	}
+	fconflicts := make([]FileConflict, len(conflicts))
+	for i, c := range conflicts {
+		fconflicts[i] = FileConflict{A: byChange[c.A], B: byChange[c.B]}
+	}
+	return out, fconflicts
+}
@@ -0,0 +1,158 @@
+package dagutils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+)
+
+func fileNode(data string) *dag.ProtoNode {
+	return dag.NodeWithData(ft.FilePBData([]byte(data), uint64(len(data))))
+}
+
+func dirNode(t *testing.T, ds dag.DAGService, entries map[string]*dag.ProtoNode) *dag.ProtoNode {
+	dir := ft.EmptyDirNode()
+	for name, nd := range entries {
+		if err := dir.AddNodeLinkClean(name, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ds.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func changeString(c *FileChange) string {
+	switch c.Type {
+	case Add:
+		return fmt.Sprintf("+%s %s %d", c.Path, c.Kind, c.SizeAfter)
+	case Remove:
+		return fmt.Sprintf("-%s %s %d", c.Path, c.Kind, c.SizeBefore)
+	case Mod:
+		return fmt.Sprintf("~%s %s %d %d", c.Path, c.Kind, c.SizeBefore, c.SizeAfter)
+	case Rename:
+		return fmt.Sprintf(">%s %s %s", c.From, c.Path, c.Kind)
+	}
+	return "?"
+}
+
+func TestDiffFiles(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	files := make(map[string]*dag.ProtoNode)
+	for _, s := range []string{"one", "two", "three", "four", "five!"} {
+		files[s] = fileNode(s)
+		if _, err := ds.Add(files[s]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// same is not added to the dag service, to check that identical
+	// subtrees are not fetched
+	same := ft.EmptyDirNode()
+	if err := same.AddNodeLinkClean("f", files["two"]); err != nil {
+		t.Fatal(err)
+	}
+
+	a := dirNode(t, ds, map[string]*dag.ProtoNode{
+		"same": same,
+		"mod":  files["one"],
+		"old":  files["three"],
+		"gone": files["two"],
+		"sub": dirNode(t, ds, map[string]*dag.ProtoNode{
+			"f": files["one"],
+		}),
+		"kind": files["one"],
+	})
+	b := dirNode(t, ds, map[string]*dag.ProtoNode{
+		"same": same,
+		"mod":  files["five!"],
+		"new":  files["three"],
+		"added": dirNode(t, ds, map[string]*dag.ProtoNode{
+			"g": files["four"],
+		}),
+		"sub": dirNode(t, ds, map[string]*dag.ProtoNode{
+			"f": files["two"],
+		}),
+		"kind": dirNode(t, ds, nil),
+	})
+
+	var got []string
+	err := DiffFiles(ctx, ds, a, b, func(c *FileChange) error {
+		got = append(got, changeString(c))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		"~mod file 3 5",
+		"~sub/f file 3 3",
+		"-gone file 3",
+		"-kind file 3",
+		"+added directory 0",
+		"+kind directory 0",
+		">old new file",
+	}
+	if len(got) != len(exp) {
+		t.Fatalf("got changes %v, expected %v", got, exp)
+	}
+
+	// directories are built from maps, so the order of the links is not
+	// known: only check that modifications come before the rest
+	gotSet := make(map[string]bool)
+	for _, g := range got {
+		gotSet[g] = true
+	}
+	for _, e := range exp {
+		if !gotSet[e] {
+			t.Fatalf("missing change %q in %v", e, got)
+		}
+	}
+	if got[0][0] != '~' || got[1][0] != '~' {
+		t.Fatalf("modifications not reported first: %v", got)
+	}
+
+	// identical trees have no changes
+	err = DiffFiles(ctx, ds, a, a, func(c *FileChange) error {
+		t.Fatalf("unexpected change %s", changeString(c))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeFileDiffs(t *testing.T) {
+	one := fileNode("one").Cid()
+	two := fileNode("two").Cid()
+
+	a := []*FileChange{
+		{Change: Change{Type: Rename, From: "x", Path: "y", Before: one, After: one}},
+		{Change: Change{Type: Add, Path: "a", After: two}},
+	}
+	b := []*FileChange{
+		{Change: Change{Type: Mod, Path: "x", Before: one, After: two}},
+		{Change: Change{Type: Add, Path: "b", After: two}},
+	}
+
+	merged, conflicts := MergeFileDiffs(a, b)
+	if len(conflicts) != 1 || conflicts[0].A != a[0] || conflicts[0].B != b[0] {
+		t.Fatalf("expected the rename and the modification to conflict, got %v", conflicts)
+	}
+
+	var paths []string
+	for _, c := range merged {
+		paths = append(paths, c.Path)
+	}
+	if fmt.Sprint(paths) != "[b y a]" {
+		t.Fatalf("unexpected merged changes: %v", paths)
+	}
+}
@@ -0,0 +1,187 @@
+package dagutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+)
+
+// Strategies for the conflicts of a merge
+const (
+	// MergeFail makes Merge fail when there are conflicts
+	MergeFail = "fail"
+	// MergeOurs keeps the changes of ours
+	MergeOurs = "ours"
+	// MergeTheirs keeps the changes of theirs
+	MergeTheirs = "theirs"
+	// MergeBoth keeps the changes of ours, and copies the entries of
+	// theirs in conflict next to them, with ConflictSuffix appended to
+	// their names
+	MergeBoth = "both"
+)
+
+// ConflictSuffix is appended to the names of the copies of the entries of
+// theirs kept by MergeBoth.
+const ConflictSuffix = ".theirs"
+
+var ErrMergeConflict = errors.New("merge conflict")
+var ErrNotDirectory = errors.New("can only merge directories")
+
+// Merge merges the changes made to the directory tree base in ours and in
+// theirs, and returns the merged tree, added to ds, along with the
+// conflicts between the changes of both sides. Conflicts are resolved with
+// strategy, one of MergeFail, MergeOurs, MergeTheirs and MergeBoth. With
+// MergeFail, the conflicts are returned along with ErrMergeConflict if
+// there are any.
+func Merge(ctx context.Context, ds dag.DAGService, base, ours, theirs *dag.ProtoNode, strategy string) (*dag.ProtoNode, []FileConflict, error) {
+	switch strategy {
+	case MergeFail, MergeOurs, MergeTheirs, MergeBoth:
+	default:
+		return nil, nil, fmt.Errorf("unrecognized merge strategy: %s", strategy)
+	}
+
+	for _, nd := range []*dag.ProtoNode{base, ours, theirs} {
+		if k, _, err := kindOf(nd); err != nil || k != KindDirectory {
+			return nil, nil, ErrNotDirectory
+		}
+	}
+
+	diff := func(nd node.Node) ([]*FileChange, error) {
+		var changes []*FileChange
+		err := DiffFiles(ctx, ds, base, nd, func(c *FileChange) error {
+			changes = append(changes, c)
+			return nil
+		})
+		return changes, err
+	}
+
+	co, err := diff(ours)
+	if err != nil {
+		return nil, nil, err
+	}
+	ct, err := diff(theirs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merged, conflicts := MergeFileDiffs(co, ct)
+	if len(conflicts) > 0 {
+		switch strategy {
+		case MergeFail:
+			return nil, conflicts, ErrMergeConflict
+		case MergeTheirs:
+			merged = preferTheirs(merged, conflicts)
+		case MergeBoth:
+			copies, err := conflictCopies(ctx, ds, theirs, conflicts)
+			if err != nil {
+				return nil, nil, err
+			}
+			merged = append(merged, copies...)
+		}
+	}
+
+	changes := make([]*Change, len(merged))
+	for i, c := range merged {
+		changes[i] = &c.Change
+	}
+
+	// ApplyChange edits the node it is given
+	nd, err := ApplyChange(ctx, ds, base.Copy().(*dag.ProtoNode), changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nd, conflicts, nil
+}
+
+// preferTheirs replaces the changes of ours in conflict in merged by those
+// of theirs.
+func preferTheirs(merged []*FileChange, conflicts []FileConflict) []*FileChange {
+	drop := make(map[*FileChange]bool)
+	for _, c := range conflicts {
+		drop[c.A] = true
+	}
+
+	var out []*FileChange
+	for _, c := range merged {
+		if !drop[c] {
+			out = append(out, c)
+		}
+	}
+	for _, c := range conflicts {
+		out = append(out, c.B)
+	}
+	return out
+}
+
+// conflictCopies returns the additions copying the entries of theirs at the
+// top of each conflict next to them, with ConflictSuffix appended to their
+// names. Entries theirs removed have no copy.
+func conflictCopies(ctx context.Context, ds dag.DAGService, theirs *dag.ProtoNode, conflicts []FileConflict) ([]*FileChange, error) {
+	var out []*FileChange
+	copied := make(map[string]bool)
+	for _, c := range conflicts {
+		p := conflictPath(&c.A.Change, &c.B.Change)
+		if copied[p] {
+			continue
+		}
+		copied[p] = true
+
+		nd, err := nodeAtPath(ctx, ds, theirs, p)
+		if err == dag.ErrLinkNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		k, s, err := kindOf(nd)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, &FileChange{
+			Change:    Change{Type: Add, Path: p + ConflictSuffix, After: nd.Cid()},
+			Kind:      k,
+			SizeAfter: s,
+		})
+	}
+	return out, nil
+}
+
+// conflictPath returns the highest of the paths through which a and b
+// conflict.
+func conflictPath(a, b *Change) string {
+	for _, pa := range a.paths() {
+		for _, pb := range b.paths() {
+			switch {
+			case pa == pb || strings.HasPrefix(pb, pa+"/"):
+				return pa
+			case strings.HasPrefix(pa, pb+"/"):
+				return pb
+			}
+		}
+	}
+	return a.Path
+}
+
+// nodeAtPath returns the node at the slash separated path p under root.
+func nodeAtPath(ctx context.Context, ds dag.DAGService, root *dag.ProtoNode, p string) (node.Node, error) {
+	var nd node.Node = root
+	for _, name := range strings.Split(p, "/") {
+		pbnd, ok := nd.(*dag.ProtoNode)
+		if !ok {
+			return nil, dag.ErrLinkNotFound
+		}
+
+		var err error
+		nd, err = pbnd.GetLinkedNode(ctx, ds, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nd, nil
+}
@@ -0,0 +1,117 @@
+package dagutils
+
+import (
+	"context"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+)
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	files := make(map[string]*dag.ProtoNode)
+	for _, s := range []string{"a", "b", "x", "ours-a", "theirs-a", "ours-x", "new", "same"} {
+		files[s] = fileNode(s)
+		if _, err := ds.Add(files[s]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := dirNode(t, ds, map[string]*dag.ProtoNode{
+		"a": files["a"],
+		"b": files["b"],
+		"d": dirNode(t, ds, map[string]*dag.ProtoNode{"x": files["x"]}),
+	})
+	ours := dirNode(t, ds, map[string]*dag.ProtoNode{
+		"a":    files["ours-a"],
+		"b":    files["b"],
+		"d":    dirNode(t, ds, map[string]*dag.ProtoNode{"x": files["ours-x"]}),
+		"new":  files["new"],
+		"same": files["same"],
+	})
+	theirs := dirNode(t, ds, map[string]*dag.ProtoNode{
+		"a":    files["theirs-a"],
+		"same": files["same"],
+	})
+
+	// check compares the files of a merged tree to exp, a nil node
+	// meaning that the file is missing
+	check := func(nd *dag.ProtoNode, exp map[string]*dag.ProtoNode) {
+		for p, f := range exp {
+			got, err := nodeAtPath(ctx, ds, nd, p)
+			if f == nil {
+				if err != dag.ErrLinkNotFound {
+					t.Fatalf("expected %s to be missing, got %v", p, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", p, err)
+			}
+			if !got.Cid().Equals(f.Cid()) {
+				t.Fatalf("%s is not the expected file", p)
+			}
+		}
+	}
+
+	_, conflicts, err := Merge(ctx, ds, base, ours, theirs, MergeFail)
+	if err != ErrMergeConflict {
+		t.Fatalf("expected a merge conflict, got %v", err)
+	}
+	if len(conflicts) != 2 {
+		t.Fatalf("expected 2 conflicts, got %d", len(conflicts))
+	}
+
+	nd, _, err := Merge(ctx, ds, base, ours, theirs, MergeOurs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(nd, map[string]*dag.ProtoNode{
+		"a":    files["ours-a"],
+		"b":    nil,
+		"d/x":  files["ours-x"],
+		"new":  files["new"],
+		"same": files["same"],
+	})
+
+	nd, _, err = Merge(ctx, ds, base, ours, theirs, MergeTheirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(nd, map[string]*dag.ProtoNode{
+		"a":    files["theirs-a"],
+		"b":    nil,
+		"d":    nil,
+		"new":  files["new"],
+		"same": files["same"],
+	})
+
+	nd, _, err = Merge(ctx, ds, base, ours, theirs, MergeBoth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(nd, map[string]*dag.ProtoNode{
+		"a":                     files["ours-a"],
+		"a" + ConflictSuffix:    files["theirs-a"],
+		"d/x":                   files["ours-x"],
+		"d" + ConflictSuffix:    nil,
+		"b":                     nil,
+		"new":                   files["new"],
+		"same" + ConflictSuffix: nil,
+	})
+
+	// the merged tree is in the dag service
+	if _, err := ds.Get(ctx, nd.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Merge(ctx, ds, base, ours, theirs, "bogus"); err == nil {
+		t.Fatal("expected an error for an unknown strategy")
+	}
+	if _, _, err := Merge(ctx, ds, files["a"], ours, theirs, MergeOurs); err != ErrNotDirectory {
+		t.Fatalf("expected ErrNotDirectory, got %v", err)
+	}
+}
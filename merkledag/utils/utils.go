@@ -213,7 +213,12 @@ func copyDag(nd *dag.ProtoNode, from, to dag.DAGService) error {
 
 		childpb, ok := child.(*dag.ProtoNode)
 		if !ok {
-			return dag.ErrNotProtobuf
+			// other nodes are inserted as is, and have no children
+			// of their own in from
+			if _, err := to.Add(child); err != nil {
+				return err
+			}
+			continue
 		}
 
 		err = copyDag(childpb, from, to)
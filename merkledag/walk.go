@@ -0,0 +1,145 @@
+package merkledag
+
+import (
+	"context"
+	gopath "path"
+	"strings"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// Selector restricts the part of a dag that Walk goes through. A nil
+// selector selects the whole dag.
+//
+// Only named links count as path components, so the unnamed links between
+// the blocks of a file are followed whenever the node they come from is.
+type Selector struct {
+	// MaxDepth is the depth of the deepest nodes reached, direct children
+	// of the root being at depth 1. A negative value means no limit.
+	MaxDepth int
+
+	// path holds the components of a glob pattern of slash separated
+	// link names, as understood by path.Match. Only the nodes along the
+	// paths matching it, and everything under them, are reached.
+	path []string
+
+	// links is a glob pattern named links must match to be followed
+	links string
+}
+
+// NewSelector returns a selector reaching nodes down to maxDepth, a
+// negative value meaning no limit, along the paths matching pathGlob and
+// through the named links matching linkGlob. Empty patterns match
+// everything.
+func NewSelector(maxDepth int, pathGlob, linkGlob string) (*Selector, error) {
+	sel := &Selector{MaxDepth: maxDepth, links: linkGlob}
+
+	if pathGlob = strings.Trim(pathGlob, "/"); pathGlob != "" {
+		sel.path = strings.Split(pathGlob, "/")
+	}
+
+	// check the patterns once here, rather than on every link
+	for _, p := range append([]string{linkGlob}, sel.path...) {
+		if _, err := gopath.Match(p, ""); err != nil {
+			return nil, err
+		}
+	}
+	return sel, nil
+}
+
+// Follow returns whether a link named name, pointing to a node at depth
+// and reached with the first matched components of the path pattern
+// matched, is to be followed, and how many components are matched below it.
+// Links from the root are at depth 1, with no components matched.
+func (s *Selector) Follow(name string, depth, matched int) (bool, int) {
+	if s == nil {
+		return true, matched
+	}
+	if s.MaxDepth >= 0 && depth > s.MaxDepth {
+		return false, matched
+	}
+	if name == "" {
+		return true, matched
+	}
+
+	if s.links != "" {
+		if ok, _ := gopath.Match(s.links, name); !ok {
+			return false, matched
+		}
+	}
+
+	if matched < len(s.path) {
+		if ok, _ := gopath.Match(s.path[matched], name); !ok {
+			return false, matched
+		}
+		matched++
+	}
+	return true, matched
+}
+
+// WalkFunc is called by Walk for every link it follows, with the cid of the
+// node the link is in and the depth of the node it points to. Walk does not
+// go through the links of that node if it returns false.
+type WalkFunc func(from *cid.Cid, lnk *node.Link, depth int) (bool, error)
+
+// Walk goes depth first through the dag under root, following the links
+// selected by sel, and calls visit for each of them before going through
+// the links of the node it points to. Nodes linked several times are only
+// gone through again when reached with more depth left than before, but
+// visit is called for every link to them.
+func Walk(ctx context.Context, ls LinkService, root *cid.Cid, sel *Selector, visit WalkFunc) error {
+	return walk(ctx, ls, root, sel, visit, make(map[walkKey]int), 1, 0)
+}
+
+// walkKey identifies the part of the dag under a node a walk goes through,
+// which depends on the path components matched on the way to it.
+type walkKey struct {
+	c       string
+	matched int
+}
+
+// walk goes through the links of c, at depth, recording in walked the
+// smallest depth each node was gone through at.
+func walk(ctx context.Context, ls LinkService, c *cid.Cid, sel *Selector, visit WalkFunc, walked map[walkKey]int, depth, matched int) error {
+	if sel != nil && sel.MaxDepth >= 0 && depth > sel.MaxDepth {
+		return nil
+	}
+
+	// without a depth limit, the same links are followed under a node
+	// whatever depth it is reached at
+	at := depth
+	if sel == nil || sel.MaxDepth < 0 {
+		at = 0
+	}
+	k := walkKey{c.KeyString(), matched}
+	if d, ok := walked[k]; ok && d <= at {
+		return nil
+	}
+	walked[k] = at
+
+	links, err := ls.GetLinks(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	for _, lnk := range links {
+		ok, m := sel.Follow(lnk.Name, depth, matched)
+		if !ok {
+			continue
+		}
+
+		descend, err := visit(c, lnk, depth)
+		if err != nil {
+			return err
+		}
+		if !descend {
+			continue
+		}
+
+		if err := walk(ctx, ls, lnk.Cid, sel, visit, walked, depth+1, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -285,7 +285,10 @@ func (d *Directory) Mkdir(name string) (*Directory, error) {
 		}
 	}
 
+	// new directories are hashed like their parent
 	ndir := new(dag.ProtoNode)
+	ndir.Prefix = d.node.Prefix
+	ndir.SetInlineLimit(d.node.InlineLimit())
 	ndir.SetData(ft.FolderPBData())
 
 	_, err = d.dserv.Add(ndir)
@@ -303,6 +306,42 @@ func (d *Directory) Mkdir(name string) (*Directory, error) {
 	return dirobj, nil
 }
 
+// Symlink creates a symbolic link called 'name' in this directory that
+// points to 'target'. The target is stored as is and not resolved.
+func (d *Directory) Symlink(name, target string) (*File, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, err := d.childUnsync(name); err == nil {
+		return nil, os.ErrExist
+	}
+
+	data, err := ft.SymlinkData(target)
+	if err != nil {
+		return nil, err
+	}
+
+	nd := dag.NodeWithData(data)
+	nd.Prefix = d.node.Prefix
+	nd.SetInlineLimit(d.node.InlineLimit())
+	_, err = d.dserv.Add(nd)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.node.AddNodeLinkClean(name, nd)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := NewFile(name, nd, d, d.dserv)
+	if err != nil {
+		return nil, err
+	}
+	d.files[name] = fi
+	return fi, nil
+}
+
 func (d *Directory) Unlink(name string) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
@@ -2,6 +2,7 @@ package mfs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -13,6 +14,8 @@ import (
 	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
 )
 
+var ErrNotSymlink = errors.New("not a symbolic link")
+
 type File struct {
 	parent childCloser
 
@@ -57,7 +60,7 @@ func (fi *File) Open(flags int, sync bool) (FileDescriptor, error) {
 		default:
 			return nil, fmt.Errorf("unsupported fsnode type for 'file'")
 		case ft.TSymlink:
-			return nil, fmt.Errorf("symlinks not yet supported")
+			return nil, fmt.Errorf("cannot open a symlink, use Readlink")
 		case ft.TFile, ft.TRaw:
 			// OK case
 		}
@@ -106,6 +109,33 @@ func (fi *File) Size() (int64, error) {
 	}
 }
 
+// IsSymlink returns whether this file is a symbolic link
+func (fi *File) IsSymlink() bool {
+	_, err := fi.Readlink()
+	return err == nil
+}
+
+// Readlink returns the target of this file if it is a symbolic link, and
+// ErrNotSymlink otherwise
+func (fi *File) Readlink() (string, error) {
+	fi.nodelk.Lock()
+	defer fi.nodelk.Unlock()
+
+	nd, ok := fi.node.(*dag.ProtoNode)
+	if !ok {
+		return "", ErrNotSymlink
+	}
+
+	pbd, err := ft.FromBytes(nd.Data())
+	if err != nil {
+		return "", err
+	}
+	if pbd.GetType() != ft.TSymlink {
+		return "", ErrNotSymlink
+	}
+	return string(pbd.GetData()), nil
+}
+
 // GetNode returns the dag node associated with this file
 func (fi *File) GetNode() (node.Node, error) {
 	fi.nodelk.Lock()
@@ -114,6 +144,15 @@ func (fi *File) GetNode() (node.Node, error) {
 }
 
 func (fi *File) Flush() error {
+	if fi.IsSymlink() {
+		// symlinks are never written to, so there is nothing to sync
+		// but the parent directories
+		fi.nodelk.Lock()
+		nd := fi.node.(*dag.ProtoNode)
+		fi.nodelk.Unlock()
+		return fi.parent.closeChild(fi.name, nd, true)
+	}
+
 	// open the file in fullsync mode
 	fd, err := fi.Open(OpenWriteOnly, true)
 	if err != nil {
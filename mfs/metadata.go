@@ -0,0 +1,125 @@
+package mfs
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+)
+
+var ErrNoMetadata = errors.New("raw nodes cannot carry unix metadata")
+
+// UnixMeta returns the permission bits and modification time recorded in
+// fsn.
+func UnixMeta(fsn FSNode) (ft.UnixMeta, error) {
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return ft.UnixMeta{}, err
+	}
+
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return ft.UnixMeta{}, nil
+	}
+
+	pbd, err := ft.FromBytes(pbnd.Data())
+	if err != nil {
+		return ft.UnixMeta{}, err
+	}
+	return ft.GetUnixMeta(pbd), nil
+}
+
+// SetMode records the permission bits of fsn.
+func SetMode(fsn FSNode, mode os.FileMode) error {
+	return updateMetadata(fsn, func(m ft.UnixMeta) ft.UnixMeta {
+		m.Mode, m.HasMode = mode.Perm(), true
+		return m
+	})
+}
+
+// ClearMode removes the permission bits recorded in fsn.
+func ClearMode(fsn FSNode) error {
+	return updateMetadata(fsn, func(m ft.UnixMeta) ft.UnixMeta {
+		m.Mode, m.HasMode = 0, false
+		return m
+	})
+}
+
+// SetModTime records the modification time of fsn. A zero time removes it.
+func SetModTime(fsn FSNode, mtime time.Time) error {
+	return updateMetadata(fsn, func(m ft.UnixMeta) ft.UnixMeta {
+		m.ModTime = mtime
+		return m
+	})
+}
+
+type metadataFunc func(ft.UnixMeta) ft.UnixMeta
+
+func updateMetadata(fsn FSNode, f metadataFunc) error {
+	switch fsn := fsn.(type) {
+	case *Directory:
+		return fsn.updateMetadata(f)
+	case *File:
+		return fsn.updateMetadata(f)
+	default:
+		return errors.New("unrecognized fsnode type")
+	}
+}
+
+func withMetadata(data []byte, f metadataFunc) ([]byte, error) {
+	pbd, err := ft.FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return ft.WithUnixMeta(data, f(ft.GetUnixMeta(pbd)))
+}
+
+func (d *Directory) updateMetadata(f metadataFunc) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	data, err := withMetadata(d.node.Data(), f)
+	if err != nil {
+		return err
+	}
+
+	d.node.SetData(data)
+	d.modTime = time.Now()
+	return nil
+}
+
+func (fi *File) updateMetadata(f metadataFunc) error {
+	// wait for open writers, which would otherwise overwrite the change
+	fi.desclock.Lock()
+	defer fi.desclock.Unlock()
+
+	fi.nodelk.Lock()
+	pbnd, ok := fi.node.(*dag.ProtoNode)
+	if !ok {
+		fi.nodelk.Unlock()
+		return ErrNoMetadata
+	}
+
+	data, err := withMetadata(pbnd.Data(), f)
+	if err != nil {
+		fi.nodelk.Unlock()
+		return err
+	}
+
+	nd := pbnd.Copy().(*dag.ProtoNode)
+	nd.SetData(data)
+
+	if _, err := fi.dserv.Add(nd); err != nil {
+		fi.nodelk.Unlock()
+		return err
+	}
+
+	fi.node = nd
+	name := fi.name
+	parent := fi.parent
+	fi.nodelk.Unlock()
+
+	return parent.closeChild(name, nd, false)
+}
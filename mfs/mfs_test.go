@@ -998,3 +998,141 @@ func TestFileDescriptors(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestUnixMeta(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ds, rt := setupRoot(ctx, t)
+
+	rootdir := rt.GetValue().(*Directory)
+	dir := mkdirP(t, rootdir, "a")
+
+	if err := dir.AddChild("file", getRandFile(t, ds, 1000)); err != nil {
+		t.Fatal(err)
+	}
+
+	fsn, err := dir.Child("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Unix(1490000000, 0)
+	if err := SetMode(fsn, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetModTime(fsn, mtime); err != nil {
+		t.Fatal(err)
+	}
+	// a mode of 0 is recorded as such
+	if err := SetMode(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// writing to the file keeps its metadata
+	wfd, err := fsn.(*File).Open(OpenWriteOnly, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wfd.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := wfd.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read both back from the dag, through the root
+	if err := rt.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	rnd, err := rootdir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nrt, err := NewRoot(ctx, ds, rnd.(*dag.ProtoNode), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nfile, err := Lookup(nrt, "/a/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := UnixMeta(nfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.HasMode || m.Mode != 0750 || !m.ModTime.Equal(mtime) {
+		t.Fatalf("file has mode %o and mtime %s", m.Mode, m.ModTime)
+	}
+
+	ndir, err := Lookup(nrt, "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = UnixMeta(ndir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.HasMode || m.Mode != 0 {
+		t.Fatalf("directory has mode %o", m.Mode)
+	}
+
+	if err := ClearMode(ndir); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := UnixMeta(ndir); err != nil || m.HasMode {
+		t.Fatal("directory mode not cleared")
+	}
+}
+
+func TestSymlink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, rt := setupRoot(ctx, t)
+
+	if err := Mkdir(rt, "/a", false, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Symlink(rt, "/a/link", "../target", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Symlink(rt, "/a/link", "elsewhere", true); err != os.ErrExist {
+		t.Fatalf("expected os.ErrExist, got %v", err)
+	}
+
+	fsn, err := Lookup(rt, "/a/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, ok := fsn.(*File)
+	if !ok {
+		t.Fatal("symlink was not a file")
+	}
+
+	target, err := fi.Readlink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "../target" {
+		t.Fatalf("expected target ../target, got %s", target)
+	}
+
+	if _, err := fi.Open(OpenReadOnly, false); err == nil {
+		t.Fatal("expected opening a symlink to fail")
+	}
+	if err := FlushPath(rt, "/a/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a regular file is not a symlink
+	if err := PutNode(rt, "/a/file", dag.NodeWithData(ft.FilePBData(nil, 0))); err != nil {
+		t.Fatal(err)
+	}
+	fsn, err = Lookup(rt, "/a/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsn.(*File).Readlink(); err != ErrNotSymlink {
+		t.Fatalf("expected ErrNotSymlink, got %v", err)
+	}
+}
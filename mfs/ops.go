@@ -163,6 +163,28 @@ func Mkdir(r *Root, pth string, mkparents bool, flush bool) error {
 	return nil
 }
 
+// Symlink creates a symbolic link at 'pth' pointing to 'target'
+func Symlink(r *Root, pth, target string, flush bool) error {
+	dirp, name := gopath.Split(strings.TrimRight(pth, "/"))
+	if name == "" {
+		return fmt.Errorf("cannot create symlink with empty name")
+	}
+
+	pdir, err := lookupDir(r, dirp)
+	if err != nil {
+		return err
+	}
+
+	if _, err := pdir.Symlink(name, target); err != nil {
+		return err
+	}
+
+	if flush {
+		return pdir.Flush()
+	}
+	return nil
+}
+
 func Lookup(r *Root, path string) (FSNode, error) {
 	dir, ok := r.GetValue().(*Directory)
 	if !ok {
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	dag "github.com/ipfs/go-ipfs/merkledag"
@@ -30,6 +31,17 @@ func (e ErrNoLink) Error() string {
 	return fmt.Sprintf("no link named %q under %s", e.Name, e.Node.String())
 }
 
+// ErrNotANode is returned when a path leads to a value inside a node rather
+// than to a node
+type ErrNotANode struct {
+	Path []string
+	Node *cid.Cid
+}
+
+func (e ErrNotANode) Error() string {
+	return fmt.Sprintf("%s under %s is not a link", strings.Join(e.Path, "/"), e.Node)
+}
+
 // Resolver provides path resolution to IPFS
 // It has a pointer to a DAGService, which is uses to resolve nodes.
 // TODO: now that this is more modular, try to unify this code with the
@@ -111,6 +123,65 @@ func (s *Resolver) ResolvePathComponents(ctx context.Context, fpath Path) ([]nod
 	return s.ResolveLinks(ctx, nd, parts)
 }
 
+// ResolveToLastNode walks the given path down to the last node it reaches,
+// and returns the cid of that node along with the rest of the path, naming a
+// value inside the node. The rest is empty if the path leads to a node.
+func (s *Resolver) ResolveToLastNode(ctx context.Context, fpath Path) (*cid.Cid, []string, error) {
+	c, names, err := SplitAbsPath(fpath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nd, err := s.DAG.Get(ctx, c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for len(names) > 0 {
+		val, rest, err := nd.Resolve(names)
+		if err == dag.ErrLinkNotFound {
+			return nil, nil, ErrNoLink{Name: names[0], Node: nd.Cid()}
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		lnk, ok := val.(*node.Link)
+		if !ok {
+			// the path ends inside this node
+			return nd.Cid(), names, nil
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+
+		nd, err = lnk.GetNode(ctx, s.DAG)
+		if err != nil {
+			return nil, nil, err
+		}
+		names = rest
+	}
+
+	return nd.Cid(), nil, nil
+}
+
+// resolveLink resolves names inside nd up to the first link, with
+// node.Resolve so that paths through values of any format work. It fails if
+// the path leads to a value that is not a link.
+func resolveLink(nd node.Node, names []string) (*node.Link, []string, error) {
+	val, rest, err := nd.Resolve(names)
+	if err == dag.ErrLinkNotFound {
+		return nil, nil, ErrNoLink{Name: names[0], Node: nd.Cid()}
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	lnk, ok := val.(*node.Link)
+	if !ok {
+		return nil, nil, ErrNotANode{Path: names, Node: nd.Cid()}
+	}
+	return lnk, rest, nil
+}
+
 // ResolveLinks iteratively resolves names by walking the link hierarchy.
 // Every node is fetched from the DAGService, resolving the next name.
 // Returns the list of nodes forming the path, starting with ndd. This list is
@@ -130,10 +201,8 @@ func (s *Resolver) ResolveLinks(ctx context.Context, ndd node.Node, names []stri
 		ctx, cancel = context.WithTimeout(ctx, time.Minute)
 		defer cancel()
 
-		lnk, rest, err := nd.ResolveLink(names)
-		if err == dag.ErrLinkNotFound {
-			return result, ErrNoLink{Name: names[0], Node: nd.Cid()}
-		} else if err != nil {
+		lnk, rest, err := resolveLink(nd, names)
+		if err != nil {
 			return result, err
 		}
 
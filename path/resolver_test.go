@@ -3,6 +3,7 @@ package path_test
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
@@ -11,6 +12,7 @@ import (
 
 	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
 	util "gx/ipfs/Qmb912gdngC1UWwTkhuW8knyRbcWeu5kqkxBpveLmW8bSr/go-ipfs-util"
+	ipldcbor "gx/ipfs/QmbuuwTd9x4NReZ7sxtiKk7wFcfDUo54MfWBdtF5MRCPGR/go-ipld-cbor"
 )
 
 func randNode() *merkledag.ProtoNode {
@@ -67,3 +69,61 @@ func TestRecurivePathResolution(t *testing.T) {
 			p.String(), key.String(), cKey.String()))
 	}
 }
+
+func TestResolveMixedFormats(t *testing.T) {
+	ctx := context.Background()
+	dagService := dagmock.Mock()
+
+	leaf := randNode()
+	obj := fmt.Sprintf(`{"a":{"b":{"/":"%s"}},"c":"value"}`, leaf.Cid())
+	cbornd, err := ipldcbor.FromJson(strings.NewReader(obj))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := randNode()
+	if err := root.AddNodeLink("cbor", cbornd); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []node.Node{leaf, cbornd, root} {
+		if _, err := dagService.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resolver := path.NewBasicResolver(dagService)
+	base := "/ipfs/" + root.Cid().String() + "/cbor"
+
+	nd, err := resolver.ResolvePath(ctx, path.Path(base+"/a/b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(leaf.Cid()) {
+		t.Fatal("path through the cbor node did not resolve to the leaf")
+	}
+
+	if _, err := resolver.ResolvePath(ctx, path.Path(base+"/c")); err == nil {
+		t.Fatal("expected an error resolving a path to a value")
+	}
+
+	c, rest, err := resolver.ResolveToLastNode(ctx, path.Path(base+"/c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Equals(cbornd.Cid()) || len(rest) != 1 || rest[0] != "c" {
+		t.Fatalf("expected %s and [c], got %s and %v", cbornd.Cid(), c, rest)
+	}
+
+	c, rest, err = resolver.ResolveToLastNode(ctx, path.Path(base+"/a/b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Equals(leaf.Cid()) || len(rest) != 0 {
+		t.Fatalf("expected %s with no rest, got %s and %v", leaf.Cid(), c, rest)
+	}
+
+	if _, _, err := resolver.ResolveToLastNode(ctx, path.Path(base+"/nope")); err == nil {
+		t.Fatal("expected an error resolving a missing path")
+	}
+}
@@ -2,70 +2,552 @@ package gc
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	pin "github.com/ipfs/go-ipfs/pin"
 
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
 	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
 var log = logging.Logger("gc")
 
-// GC performs a mark and sweep garbage collection of the blocks in the blockstore
-// first, it creates a 'marked' set and adds to it the following:
+// collectLk is held by a collection for its whole run, so that collections
+// do not remove the blocks marked by one another.
+var collectLk sync.Mutex
+
+// Phases of a collection, as reported by Progress
+const (
+	PhaseMark  = "mark"
+	PhaseSweep = "sweep"
+	PhaseDone  = "done"
+)
+
+const (
+	// defaultBatchSize is the default number of blocks removed at a time
+	defaultBatchSize = 1024
+
+	// progressInterval is the time between two progress reports
+	progressInterval = time.Second
+)
+
+// Options tunes a collection run by Collect. The zero value keeps the
+// marked set in memory, with no budget.
+type Options struct {
+	// Datastore, if not nil, holds the marked set instead of memory, with
+	// a bloom filter of BloomSize bits in front of it. The set is removed
+	// from it once the collection is done.
+	Datastore ds.Batching
+	BloomSize int
+
+	// BatchSize is the number of blocks removed at a time, while holding
+	// the GC lock of the blockstore.
+	BatchSize int
+
+	// MaxDuration and MaxBytes, when not zero, bound the time spent and
+	// the size of the blocks removed. The collection stops once either
+	// is reached, with the remaining garbage left for the next one.
+	MaxDuration time.Duration
+	MaxBytes    uint64
+
+	// Keys, if not nil, returns the keys the sweep goes through, in the
+	// order to remove them in, instead of those of the blockstore in no
+	// particular order. With a budget, it chooses the blocks removed
+	// first, e.g. the least recently used ones.
+	Keys func(ctx context.Context) (<-chan *cid.Cid, error)
+}
+
+// Progress reports how far a collection has gone.
+type Progress struct {
+	Phase string
+
+	// Marked is the number of blocks marked as live, Scanned the number
+	// of blocks of the blockstore the sweep went through, and Removed
+	// the number of those removed
+	Marked  int
+	Scanned int
+	Removed int
+
+	// RemovedBytes is the size of the blocks removed, only counted when
+	// there is a byte budget, and by DryRun
+	RemovedBytes uint64 `json:",omitempty"`
+
+	// BudgetExhausted is set once the collection stopped on its budget
+	BudgetExhausted bool `json:",omitempty"`
+}
+
+// Result is an output of Collect: a removed block, a progress report, or
+// the error the collection stopped on.
+type Result struct {
+	KeyRemoved *cid.Cid
+	Progress   *Progress
+	Error      error
+}
+
+// RootsFunc returns the roots whose locally available descendants are kept
+// by a collection. It is called again before each batch of removals, as
+// the roots may change while a collection runs.
+type RootsFunc func() ([]*cid.Cid, error)
+
+// GC performs a mark and sweep garbage collection of the blocks in the
+// blockstore with Collect and the default options, and returns the keys
+// of the blocks removed. It returns once the live blocks are marked, with
+// the error marking them failed on, if any. Errors of the sweep are logged.
+func GC(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.Pinner, bestEffortRoots []*cid.Cid) (<-chan *cid.Cid, error) {
+	roots := func() ([]*cid.Cid, error) {
+		return bestEffortRoots, nil
+	}
+	results := Collect(ctx, bs, ls, pn, roots, Options{})
+
+	// wait for the end of the mark phase, reporting its errors
+	for r := range results {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if r.Progress != nil && r.Progress.Phase != PhaseMark {
+			break
+		}
+	}
+
+	output := make(chan *cid.Cid)
+	go func() {
+		defer close(output)
+		for r := range results {
+			switch {
+			case r.Error != nil:
+				log.Errorf("garbage collection failed: %s", r.Error)
+			case r.KeyRemoved != nil:
+				select {
+				case output <- r.KeyRemoved:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return output, nil
+}
+
+// Collect performs a mark and sweep garbage collection of the blocks in the
+// blockstore. It first marks the following blocks as live:
 // - all recursively pinned blocks, plus all of their descendants (recursively)
-// - bestEffortRoots, plus all of its descendants (recursively)
+// - all depth limited pinned blocks, plus their descendants down to the depth
+// - the best effort roots, plus all of their descendants (recursively)
 // - all directly pinned blocks
 // - all blocks utilized internally by the pinner
 //
-// The routine then iterates over every block in the blockstore and
-// deletes any block that is not found in the marked set.
-func GC(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.Pinner, bestEffortRoots []*cid.Cid) (<-chan *cid.Cid, error) {
-	unlocker := bs.GCLock()
+// When the pinner keeps a valid reference count index, the recursive pins
+// are not walked: the blocks with references are kept instead.
+//
+// It then goes through every block in the blockstore, and removes those not
+// marked. Blocks are removed in batches, while holding the GC lock of the
+// blockstore, so that the blocks added and pinned concurrently only wait
+// for the current batch. Before each batch, the pins and roots appeared
+// since the last one are marked in turn.
+//
+// The removed blocks are sent on the returned channel as they go, along with
+// progress reports once a second and at each phase change, and the error
+// the collection stops on, if any. The channel is closed once the
+// collection is done.
+//
+// Collections run one at a time: Collect waits for the one running, if
+// any, to be done before marking.
+func Collect(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.Pinner, bestEffortRoots RootsFunc, opts Options) <-chan Result {
+	out := make(chan Result, 16)
+
+	go func() {
+		defer close(out)
+
+		collectLk.Lock()
+		defer collectLk.Unlock()
+
+		c := &collector{
+			ctx:      ctx,
+			bs:       bs,
+			ls:       ls.GetOfflineLinkService(),
+			pn:       pn,
+			roots:    bestEffortRoots,
+			opts:     opts,
+			out:      out,
+			walked:   cid.NewSet(),
+			shallow:  cid.NewSet(),
+			depthSel: make(map[string]int),
+		}
+		if c.opts.BatchSize <= 0 {
+			c.opts.BatchSize = defaultBatchSize
+		}
+		if c.opts.MaxDuration > 0 {
+			c.deadline = time.Now().Add(c.opts.MaxDuration)
+		}
+
+		if err := c.run(); err != nil {
+			select {
+			case out <- Result{Error: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return out
+}
+
+type collector struct {
+	ctx   context.Context
+	bs    bstore.GCBlockstore
+	ls    dag.LinkService
+	pn    pin.Pinner
+	roots RootsFunc
+	opts  Options
+	out   chan<- Result
 
-	ls = ls.GetOfflineLinkService()
+	// marked holds the blocks marked along with all their descendants,
+	// and shallow those marked without them: direct pins, and the blocks
+	// of depth limited pins
+	marked  markSet
+	shallow *cid.Set
 
-	gcs, err := ColoredSet(ctx, pn, ls, bestEffortRoots)
+	// walked holds the roots already marked, and depthSel the depth each
+	// depth limited pin was marked to
+	walked   *cid.Set
+	depthSel map[string]int
+
+	// refs, if not nil, is the valid reference count index of the pinner,
+	// keeping the blocks with references
+	refs *pin.RefCounts
+
+	progress   Progress
+	lastReport time.Time
+	deadline   time.Time
+}
+
+func (c *collector) run() error {
+	if c.opts.Datastore != nil {
+		set, err := newDiskSet(c.opts.Datastore, c.opts.BloomSize)
+		if err != nil {
+			return err
+		}
+		c.marked = set
+	} else {
+		c.marked = memSet{cid.NewSet()}
+	}
+	if refs := c.pn.RefCounts(); refs != nil && refs.Valid() {
+		c.refs = refs
+	}
+
+	c.setPhase(PhaseMark)
+	err := c.mark()
+	if err == nil {
+		err = c.sweep()
+	}
+	if c.expired() {
+		c.progress.BudgetExhausted = true
+		err = nil
+	}
+
+	if cerr := c.marked.Close(); err == nil {
+		err = cerr
+	}
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	c.setPhase(PhaseDone)
+	return nil
+}
+
+func (c *collector) expired() bool {
+	return !c.deadline.IsZero() && time.Now().After(c.deadline)
+}
+
+// setPhase moves to the given phase, and reports it.
+func (c *collector) setPhase(phase string) {
+	c.progress.Phase = phase
+	c.report()
+}
+
+func (c *collector) report() {
+	c.lastReport = time.Now()
+	p := c.progress
+	select {
+	case c.out <- Result{Progress: &p}:
+	case <-c.ctx.Done():
+	}
+}
+
+func (c *collector) maybeReport() {
+	if time.Since(c.lastReport) >= progressInterval {
+		c.report()
+	}
+}
+
+func (c *collector) has(k *cid.Cid) bool {
+	if c.shallow.Has(k) || c.marked.Has(k) {
+		return true
+	}
+	if c.refs == nil {
+		return false
 	}
 
-	keychan, err := bs.AllKeysChan(ctx)
+	n, err := c.refs.Count(k)
 	if err != nil {
-		return nil, err
+		// keep the block when in doubt
+		log.Errorf("gc: checking reference count: %s", err)
+		return true
 	}
+	return n > 0
+}
+
+func (c *collector) visit(k *cid.Cid) bool {
+	if !c.marked.Visit(k) {
+		return false
+	}
+	c.progress.Marked++
+	c.maybeReport()
+	return true
+}
+
+// mark marks the blocks to keep. Roots marked by a previous call are not
+// walked again.
+func (c *collector) mark() error {
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+
+	markTree := func(root *cid.Cid, bestEffort bool) error {
+		if c.walked.Has(root) {
+			return nil
+		}
+		if c.visit(root) {
+			if err := dag.EnumerateChildren(ctx, c.ls, root, c.visit, bestEffort); err != nil {
+				return err
+			}
+		}
+		c.walked.Add(root)
+		return nil
+	}
+
+	if c.refs == nil {
+		for _, k := range c.pn.RecursiveKeys() {
+			if err := markTree(k, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, dp := range c.pn.DepthLimitedPins() {
+		if d, ok := c.depthSel[dp.Key.KeyString()]; ok && d == dp.MaxDepth {
+			continue
+		}
+
+		c.shallow.Add(dp.Key)
+		err := dag.Walk(ctx, c.ls, dp.Key, &dag.Selector{MaxDepth: dp.MaxDepth}, func(_ *cid.Cid, lnk *node.Link, _ int) (bool, error) {
+			c.shallow.Add(lnk.Cid)
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+		c.depthSel[dp.Key.KeyString()] = dp.MaxDepth
+	}
+
+	roots, err := c.roots()
+	if err != nil {
+		return err
+	}
+	for _, k := range roots {
+		if err := markTree(k, true); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range c.pn.DirectKeys() {
+		c.shallow.Add(k)
+	}
+
+	for _, k := range c.pn.InternalPins() {
+		if err := markTree(k, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sweep goes through the blockstore and removes the blocks not marked.
+func (c *collector) sweep() error {
+	c.setPhase(PhaseSweep)
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+
+	allKeys := c.bs.AllKeysChan
+	if c.opts.Keys != nil {
+		allKeys = c.opts.Keys
+	}
+	keys, err := allKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	batch := make([]*cid.Cid, 0, c.opts.BatchSize)
+	for k := range keys {
+		c.progress.Scanned++
+		c.maybeReport()
+
+		if c.has(k) {
+			continue
+		}
+		batch = append(batch, k)
+		if len(batch) < c.opts.BatchSize {
+			continue
+		}
+
+		done, err := c.removeBatch(batch)
+		if err != nil || done {
+			return err
+		}
+		batch = batch[:0]
+	}
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err = c.removeBatch(batch)
+	return err
+}
+
+// removeBatch removes the blocks of batch still not marked once the roots
+// changed since the last batch are, and returns whether the budget of the
+// collection is exhausted.
+func (c *collector) removeBatch(batch []*cid.Cid) (bool, error) {
+	if len(batch) == 0 {
+		return false, nil
+	}
+
+	unlocker := c.bs.GCLock()
+	defer unlocker.Unlock()
+
+	if err := c.mark(); err != nil {
+		return false, err
+	}
+	if c.refs != nil && !c.refs.Valid() {
+		// an update of the index failed, it may miss references
+		return false, pin.ErrRefCountsInvalid
+	}
+
+	for _, k := range batch {
+		if c.expired() {
+			return true, nil
+		}
+		if c.has(k) {
+			continue
+		}
+
+		if c.opts.MaxBytes > 0 {
+			blk, err := c.bs.Get(k)
+			if err == bstore.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+
+			size := uint64(len(blk.RawData()))
+			if c.progress.RemovedBytes+size > c.opts.MaxBytes {
+				c.progress.BudgetExhausted = true
+				return true, nil
+			}
+			c.progress.RemovedBytes += size
+		}
+
+		switch err := c.bs.DeleteBlock(k); err {
+		case nil:
+		case bstore.ErrNotFound, ds.ErrNotFound:
+			// removed since it was listed
+			continue
+		default:
+			return false, err
+		}
+		c.progress.Removed++
+
+		select {
+		case c.out <- Result{KeyRemoved: k}:
+		case <-c.ctx.Done():
+			return false, c.ctx.Err()
+		}
+	}
+	return false, nil
+}
+
+// DryRun goes through the blockstore like Collect, but only sends the
+// blocks not in the ColoredSet on the returned channel, without removing
+// them, followed by a final progress report of their count and size. It
+// holds no lock, so blocks added while it runs may be reported as well.
+func DryRun(ctx context.Context, bs bstore.Blockstore, ls dag.LinkService, pn pin.Pinner, bestEffortRoots []*cid.Cid) <-chan Result {
+	out := make(chan Result, 16)
 
-	output := make(chan *cid.Cid)
 	go func() {
-		defer close(output)
-		defer unlocker.Unlock()
-		for {
+		defer close(out)
+
+		send := func(r Result) bool {
 			select {
-			case k, ok := <-keychan:
-				if !ok {
-					return
-				}
-				if !gcs.Has(k) {
-					err := bs.DeleteBlock(k)
-					if err != nil {
-						log.Debugf("Error removing key from blockstore: %s", err)
-						return
-					}
-					select {
-					case output <- k:
-					case <-ctx.Done():
-						return
-					}
-				}
+			case out <- r:
+				return true
 			case <-ctx.Done():
+				return false
+			}
+		}
+
+		gcs, err := ColoredSet(ctx, pn, ls.GetOfflineLinkService(), bestEffortRoots)
+		if err != nil {
+			send(Result{Error: err})
+			return
+		}
+
+		keys, err := bs.AllKeysChan(ctx)
+		if err != nil {
+			send(Result{Error: err})
+			return
+		}
+
+		p := Progress{Phase: PhaseSweep, Marked: gcs.Len()}
+		for k := range keys {
+			p.Scanned++
+			if gcs.Has(k) {
+				continue
+			}
+
+			blk, err := bs.Get(k)
+			if err == bstore.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				send(Result{Error: err})
+				return
+			}
+			p.Removed++
+			p.RemovedBytes += uint64(len(blk.RawData()))
+
+			if !send(Result{KeyRemoved: k}) {
 				return
 			}
 		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.Phase = PhaseDone
+		send(Result{Progress: &p})
 	}()
 
-	return output, nil
+	return out
 }
 
 func Descendants(ctx context.Context, ls dag.LinkService, set *cid.Set, roots []*cid.Cid, bestEffort bool) error {
@@ -82,9 +564,11 @@ func Descendants(ctx context.Context, ls dag.LinkService, set *cid.Set, roots []
 	return nil
 }
 
+// ColoredSet returns the set of the blocks a collection keeps, as listed by
+// Collect. The blocks kept without their descendants, those of direct and
+// depth limited pins, are added last, as the walks of the others stop at the
+// blocks already in the set.
 func ColoredSet(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffortRoots []*cid.Cid) (*cid.Set, error) {
-	// KeySet currently implemented in memory, in the future, may be bloom filter or
-	// disk backed to conserve memory.
 	gcs := cid.NewSet()
 	err := Descendants(ctx, ls, gcs, pn.RecursiveKeys(), false)
 	if err != nil {
@@ -96,14 +580,25 @@ func ColoredSet(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffo
 		return nil, err
 	}
 
-	for _, k := range pn.DirectKeys() {
-		gcs.Add(k)
-	}
-
 	err = Descendants(ctx, ls, gcs, pn.InternalPins(), false)
 	if err != nil {
 		return nil, err
 	}
 
+	for _, dp := range pn.DepthLimitedPins() {
+		gcs.Add(dp.Key)
+		err := dag.Walk(ctx, ls, dp.Key, &dag.Selector{MaxDepth: dp.MaxDepth}, func(_ *cid.Cid, lnk *node.Link, _ int) (bool, error) {
+			gcs.Add(lnk.Cid)
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, k := range pn.DirectKeys() {
+		gcs.Add(k)
+	}
+
 	return gcs, nil
 }
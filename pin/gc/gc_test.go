@@ -0,0 +1,391 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+type testRepo struct {
+	dstore ds.Batching
+	bs     bstore.GCBlockstore
+	dserv  dag.DAGService
+	pinner pin.Pinner
+}
+
+func newTestRepo() *testRepo {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := bstore.NewGCBlockstore(bstore.NewBlockstore(dstore), bstore.NewGCLocker())
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	return &testRepo{
+		dstore: dstore,
+		bs:     bs,
+		dserv:  dserv,
+		pinner: pin.NewPinner(dstore, dserv, dserv),
+	}
+}
+
+// addChain adds a chain of n nodes, and returns their cids from the root.
+func (r *testRepo) addChain(t *testing.T, name string, n int) []*cid.Cid {
+	var cids []*cid.Cid
+	var child *dag.ProtoNode
+	for i := 0; i < n; i++ {
+		nd := dag.NodeWithData([]byte(fmt.Sprintf("%s %d", name, i)))
+		if child != nil {
+			if err := nd.AddNodeLinkClean("child", child); err != nil {
+				t.Fatal(err)
+			}
+		}
+		c, err := r.dserv.Add(nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cids = append([]*cid.Cid{c}, cids...)
+		child = nd
+	}
+	return cids
+}
+
+func noRoots() ([]*cid.Cid, error) {
+	return nil, nil
+}
+
+func collect(t *testing.T, r *testRepo, opts Options) (map[string]bool, []*Progress) {
+	removed := make(map[string]bool)
+	var progress []*Progress
+	for res := range Collect(context.Background(), r.bs, r.dserv, r.pinner, noRoots, opts) {
+		switch {
+		case res.Error != nil:
+			t.Fatal(res.Error)
+		case res.Progress != nil:
+			progress = append(progress, res.Progress)
+		default:
+			removed[res.KeyRemoved.KeyString()] = true
+		}
+	}
+	return removed, progress
+}
+
+func TestCollect(t *testing.T) {
+	for _, disk := range []bool{false, true} {
+		r := newTestRepo()
+
+		pinned := r.addChain(t, "pinned", 5)
+		garbage := r.addChain(t, "garbage", 5)
+
+		root, err := r.dserv.Get(context.Background(), pinned[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.pinner.Pin(context.Background(), root, true); err != nil {
+			t.Fatal(err)
+		}
+
+		var opts Options
+		if disk {
+			opts.Datastore = r.dstore
+			opts.BloomSize = 1024
+		}
+		removed, progress := collect(t, r, opts)
+
+		for _, c := range garbage {
+			if !removed[c.KeyString()] {
+				t.Fatal("garbage block not removed")
+			}
+		}
+		for _, c := range pinned {
+			if removed[c.KeyString()] {
+				t.Fatal("pinned block removed")
+			}
+			if has, _ := r.bs.Has(c); !has {
+				t.Fatal("pinned block missing")
+			}
+		}
+
+		last := progress[len(progress)-1]
+		if progress[0].Phase != PhaseMark || last.Phase != PhaseDone {
+			t.Fatalf("unexpected phases: %s to %s", progress[0].Phase, last.Phase)
+		}
+		if last.Removed != len(garbage) || last.Marked != len(pinned) {
+			t.Fatalf("unexpected final progress: %+v", last)
+		}
+
+		// the marked set is not left in the datastore
+		res, err := r.dstore.Query(dsq.Query{Prefix: markedPrefix.String(), KeysOnly: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		left, err := res.Rest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(left) != 0 {
+			t.Fatalf("%d marks left in the datastore", len(left))
+		}
+	}
+}
+
+func TestGCMarkError(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo()
+
+	pinned := r.addChain(t, "pinned", 2)
+	root, err := r.dserv.Get(ctx, pinned[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.pinner.Pin(ctx, root, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.bs.DeleteBlock(pinned[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GC(ctx, r.bs, r.dserv, r.pinner, nil); err == nil {
+		t.Fatal("expected an error marking a pin with a missing block")
+	}
+}
+
+func TestCollectByteBudget(t *testing.T) {
+	r := newTestRepo()
+	garbage := r.addChain(t, "garbage", 10)
+
+	// all the blocks but the leaf, which is smaller, have the size of the root
+	blk, err := r.bs.Get(garbage[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := uint64(len(blk.RawData()))
+
+	removed, progress := collect(t, r, Options{MaxBytes: 3 * size, BatchSize: 2})
+	if len(removed) != 3 {
+		t.Fatalf("expected 3 blocks removed, got %d", len(removed))
+	}
+
+	last := progress[len(progress)-1]
+	if !last.BudgetExhausted || last.RemovedBytes > 3*size {
+		t.Fatalf("unexpected final progress: %+v", last)
+	}
+
+	// the next collection removes the rest
+	removed, _ = collect(t, r, Options{})
+	if len(removed) != 7 {
+		t.Fatalf("expected 7 blocks removed, got %d", len(removed))
+	}
+}
+
+func TestCollectKeysOrder(t *testing.T) {
+	r := newTestRepo()
+	garbage := r.addChain(t, "garbage", 10)
+
+	blk, err := r.bs.Get(garbage[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := uint64(len(blk.RawData()))
+
+	// sweep from the root, which is larger than the leaf
+	keys := func(ctx context.Context) (<-chan *cid.Cid, error) {
+		out := make(chan *cid.Cid, len(garbage))
+		for _, c := range garbage {
+			out <- c
+		}
+		close(out)
+		return out, nil
+	}
+	removed, _ := collect(t, r, Options{MaxBytes: 3 * size, Keys: keys})
+	if len(removed) != 3 {
+		t.Fatalf("expected 3 blocks removed, got %d", len(removed))
+	}
+	for _, c := range garbage[:3] {
+		if !removed[c.KeyString()] {
+			t.Fatal("blocks not removed in the order of the keys")
+		}
+	}
+}
+
+func TestCollectRefCounts(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo()
+
+	refs, err := pin.NewRefCounts(r.dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := refs.Rebuild(ctx, r.dserv, nil); err != nil {
+		t.Fatal(err)
+	}
+	r.pinner.SetRefCounts(refs)
+
+	pinned := r.addChain(t, "pinned", 5)
+	root, err := r.dserv.Get(ctx, pinned[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.pinner.Pin(ctx, root, true); err != nil {
+		t.Fatal(err)
+	}
+
+	garbage := r.addChain(t, "garbage", 3)
+	removed, progress := collect(t, r, Options{})
+	if len(removed) != len(garbage) {
+		t.Fatalf("expected %d blocks removed, got %d", len(garbage), len(removed))
+	}
+	// the recursive pin is counted, not walked
+	if last := progress[len(progress)-1]; last.Marked != 0 {
+		t.Fatalf("expected no block marked, got %d", last.Marked)
+	}
+
+	// once unpinned, collecting the released blocks only removes them
+	if err := r.pinner.Unpin(ctx, pinned[0], true); err != nil {
+		t.Fatal(err)
+	}
+	garbage = r.addChain(t, "more garbage", 3)
+
+	removed, _ = collect(t, r, Options{Keys: refs.Released})
+	if len(removed) != len(pinned) {
+		t.Fatalf("expected %d blocks removed, got %d", len(pinned), len(removed))
+	}
+	for _, c := range pinned {
+		if !removed[c.KeyString()] {
+			t.Fatal("released block not removed")
+		}
+	}
+	for _, c := range garbage {
+		if has, _ := r.bs.Has(c); !has {
+			t.Fatal("unreleased block removed")
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	r := newTestRepo()
+	garbage := r.addChain(t, "garbage", 3)
+
+	var size uint64
+	for _, c := range garbage {
+		blk, err := r.bs.Get(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += uint64(len(blk.RawData()))
+	}
+
+	listed := 0
+	var last *Progress
+	for res := range DryRun(context.Background(), r.bs, r.dserv, r.pinner, nil) {
+		switch {
+		case res.Error != nil:
+			t.Fatal(res.Error)
+		case res.Progress != nil:
+			last = res.Progress
+		default:
+			listed++
+		}
+	}
+
+	if listed != len(garbage) {
+		t.Fatalf("expected %d blocks listed, got %d", len(garbage), listed)
+	}
+	if last == nil || last.Phase != PhaseDone || last.Removed != len(garbage) || last.RemovedBytes != size {
+		t.Fatalf("unexpected final progress: %+v", last)
+	}
+
+	// nothing is removed
+	for _, c := range garbage {
+		if has, _ := r.bs.Has(c); !has {
+			t.Fatal("block removed by a dry run")
+		}
+	}
+}
+
+func TestColoredSetDepthLimited(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo()
+
+	// the top of the mfs chain is also pinned to a depth of 1
+	mfs := r.addChain(t, "mfs", 3)
+	root, err := r.dserv.Get(ctx, mfs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.pinner.PinToDepth(ctx, root, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	gcs, err := ColoredSet(ctx, r.pinner, r.dserv, mfs[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range mfs {
+		if !gcs.Has(c) {
+			t.Fatal("block under a depth limited pin and a best effort root not kept")
+		}
+	}
+}
+
+func TestWhy(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo()
+
+	pinned := r.addChain(t, "pinned", 3)
+	garbage := r.addChain(t, "garbage", 2)
+	mfs := r.addChain(t, "mfs", 2)
+
+	root, err := r.dserv.Get(ctx, pinned[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.pinner.Pin(ctx, root, true); err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := r.dserv.Get(ctx, pinned[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.pinner.Pin(ctx, leaf, false); err != nil {
+		t.Fatal(err)
+	}
+
+	reasons, err := Why(ctx, r.pinner, r.dserv, mfs[:1], pinned[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reasons) != 2 {
+		t.Fatalf("expected 2 reasons, got %v", reasons)
+	}
+	rec, direct := reasons[0], reasons[1]
+	if rec.Kind != KeptRecursive || !rec.Root.Equals(pinned[0]) || len(rec.Path) != 2 || rec.Path[0] != "child" {
+		t.Fatalf("unexpected recursive reason: %+v", rec)
+	}
+	if direct.Kind != KeptDirect || !direct.Root.Equals(pinned[2]) || len(direct.Path) != 0 {
+		t.Fatalf("unexpected direct reason: %+v", direct)
+	}
+
+	reasons, err = Why(ctx, r.pinner, r.dserv, mfs[:1], mfs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reasons) != 1 || reasons[0].Kind != KeptBestEffort {
+		t.Fatalf("unexpected reasons: %v", reasons)
+	}
+
+	reasons, err = Why(ctx, r.pinner, r.dserv, mfs[:1], garbage[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reasons) != 0 {
+		t.Fatalf("expected no reason for garbage, got %v", reasons)
+	}
+}
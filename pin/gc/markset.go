@@ -0,0 +1,179 @@
+package gc
+
+import (
+	"strconv"
+	"time"
+
+	dshelp "github.com/ipfs/go-ipfs/thirdparty/ds-help"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+	bloom "gx/ipfs/QmeiMCBkYHxkDkDfnDadzz4YxY5ruL5Pj499essE4vRsGM/bbloom"
+)
+
+// markedPrefix is the prefix of the keys of the marked sets held in a
+// datastore, each collection keeping its own under a child of it
+var markedPrefix = ds.NewKey("/local/gc/marked")
+
+const (
+	// defaultBloomSize is the size in bits of the bloom filter in front
+	// of a marked set held in a datastore, enough for about ten million
+	// blocks with a few percent of false positives
+	defaultBloomSize = 1 << 26
+	bloomHashCount   = 7
+
+	// markedBatchSize is the number of marks buffered before being
+	// written to the datastore
+	markedBatchSize = 4096
+)
+
+// markSet is the set of the blocks marked as live during a collection
+type markSet interface {
+	// Visit adds c to the set, returning false if it was in it already
+	Visit(c *cid.Cid) bool
+	Has(c *cid.Cid) bool
+	Close() error
+}
+
+type memSet struct {
+	*cid.Set
+}
+
+func (memSet) Close() error {
+	return nil
+}
+
+// diskSet is a marked set held in a datastore, with a bloom filter
+// answering most lookups of blocks not in the set from memory. Marks are
+// buffered and written in batches.
+type diskSet struct {
+	d       ds.Batching
+	prefix  ds.Key
+	bloom   *bloom.Bloom
+	pending *cid.Set
+	err     error
+}
+
+func newDiskSet(d ds.Batching, bloomSize int) (*diskSet, error) {
+	if bloomSize <= 0 {
+		bloomSize = defaultBloomSize
+	}
+	bl, err := bloom.New(float64(bloomSize), bloomHashCount)
+	if err != nil {
+		return nil, err
+	}
+
+	// remove what interrupted collections may have left. Collections
+	// run one at a time, so no other set is in use.
+	if err := clearPrefix(d, markedPrefix); err != nil {
+		return nil, err
+	}
+
+	return &diskSet{
+		d:       d,
+		prefix:  markedPrefix.ChildString(strconv.FormatInt(time.Now().UnixNano(), 36)),
+		bloom:   bl,
+		pending: cid.NewSet(),
+	}, nil
+}
+
+func (s *diskSet) key(c *cid.Cid) ds.Key {
+	return s.prefix.Child(dshelp.CidToDsKey(c))
+}
+
+func (s *diskSet) Has(c *cid.Cid) bool {
+	if !s.bloom.Has(c.Bytes()) {
+		return false
+	}
+	if s.pending.Has(c) {
+		return true
+	}
+
+	has, err := s.d.Has(s.key(c))
+	if err != nil {
+		// keep the block when in doubt
+		log.Errorf("gc: checking marked set: %s", err)
+		return true
+	}
+	return has
+}
+
+func (s *diskSet) Visit(c *cid.Cid) bool {
+	if s.Has(c) {
+		return false
+	}
+
+	s.bloom.Add(c.Bytes())
+	s.pending.Add(c)
+	if s.pending.Len() >= markedBatchSize {
+		s.flush()
+	}
+	return true
+}
+
+// flush writes the pending marks to the datastore. Errors are kept, and
+// returned by Close.
+func (s *diskSet) flush() {
+	if s.err != nil {
+		return
+	}
+
+	b, err := s.d.Batch()
+	if err != nil {
+		s.err = err
+		return
+	}
+	for _, c := range s.pending.Keys() {
+		if err := b.Put(s.key(c), []byte{}); err != nil {
+			s.err = err
+			return
+		}
+	}
+	if err := b.Commit(); err != nil {
+		s.err = err
+		return
+	}
+	s.pending = cid.NewSet()
+}
+
+// clearPrefix removes all the keys under prefix from d.
+func clearPrefix(d ds.Batching, prefix ds.Key) error {
+	res, err := d.Query(dsq.Query{Prefix: prefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	b, err := d.Batch()
+	if err != nil {
+		return err
+	}
+	n := 0
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+		if err := b.Delete(ds.RawKey(e.Key)); err != nil {
+			return err
+		}
+
+		if n++; n%markedBatchSize == 0 {
+			if err := b.Commit(); err != nil {
+				return err
+			}
+			if b, err = d.Batch(); err != nil {
+				return err
+			}
+		}
+	}
+	return b.Commit()
+}
+
+func (s *diskSet) Close() error {
+	err := s.err
+	if cerr := clearPrefix(s.d, s.prefix); err == nil {
+		err = cerr
+	}
+	return err
+}
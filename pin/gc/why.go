@@ -0,0 +1,122 @@
+package gc
+
+import (
+	"context"
+	"errors"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// Kinds of the roots keeping a block from being collected
+const (
+	KeptRecursive  = "recursive"
+	KeptDepth      = "depth-limited"
+	KeptBestEffort = "best-effort"
+	KeptInternal   = "internal"
+	KeptDirect     = "direct"
+)
+
+// Reason is a root keeping a block from being collected.
+type Reason struct {
+	Kind string
+	Root *cid.Cid
+
+	// Path holds the names of the links from Root to the block, or the
+	// cids they point to for unnamed links. It is empty when the block
+	// is the root itself.
+	Path []string
+}
+
+var errFound = errors.New("found")
+
+// Why returns the roots keeping c from being collected, with a path from
+// each of them to it, going through the roots ColoredSet marks. It returns
+// no reason for the blocks a collection would remove.
+func Why(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffortRoots []*cid.Cid, c *cid.Cid) ([]Reason, error) {
+	ls = ls.GetOfflineLinkService()
+
+	var reasons []Reason
+	find := func(kind string, roots []*cid.Cid, ls dag.LinkService, sel *dag.Selector) error {
+		for _, root := range roots {
+			p, found, err := pathTo(ctx, ls, root, c, sel)
+			if err != nil {
+				return err
+			}
+			if found {
+				reasons = append(reasons, Reason{Kind: kind, Root: root, Path: p})
+			}
+		}
+		return nil
+	}
+
+	if err := find(KeptRecursive, pn.RecursiveKeys(), ls, nil); err != nil {
+		return nil, err
+	}
+	for _, dp := range pn.DepthLimitedPins() {
+		sel := &dag.Selector{MaxDepth: dp.MaxDepth}
+		if err := find(KeptDepth, []*cid.Cid{dp.Key}, ls, sel); err != nil {
+			return nil, err
+		}
+	}
+	if err := find(KeptBestEffort, bestEffortRoots, bestEffortLinks{ls}, nil); err != nil {
+		return nil, err
+	}
+	if err := find(KeptInternal, pn.InternalPins(), ls, nil); err != nil {
+		return nil, err
+	}
+
+	for _, k := range pn.DirectKeys() {
+		if k.Equals(c) {
+			reasons = append(reasons, Reason{Kind: KeptDirect, Root: k})
+		}
+	}
+	return reasons, nil
+}
+
+// pathTo looks for target in the dag under root, and returns the path to
+// the first place it is found at.
+func pathTo(ctx context.Context, ls dag.LinkService, root, target *cid.Cid, sel *dag.Selector) ([]string, bool, error) {
+	if root.Equals(target) {
+		return nil, true, nil
+	}
+
+	var p []string
+	err := dag.Walk(ctx, ls, root, sel, func(_ *cid.Cid, lnk *node.Link, depth int) (bool, error) {
+		name := lnk.Name
+		if name == "" {
+			name = lnk.Cid.String()
+		}
+		p = append(p[:depth-1], name)
+
+		if lnk.Cid.Equals(target) {
+			return false, errFound
+		}
+		return true, nil
+	})
+	switch err {
+	case errFound:
+		return p, true, nil
+	case nil:
+		return nil, false, nil
+	default:
+		return nil, false, err
+	}
+}
+
+// bestEffortLinks is a link service for best effort roots, whose nodes
+// missing locally have no links.
+type bestEffortLinks struct {
+	dag.LinkService
+}
+
+func (l bestEffortLinks) GetLinks(ctx context.Context, c *cid.Cid) ([]*node.Link, error) {
+	links, err := l.LinkService.GetLinks(ctx, c)
+	if err == dag.ErrNotFound {
+		return nil, nil
+	}
+	return links, err
+}
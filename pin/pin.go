@@ -6,6 +6,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -40,6 +42,10 @@ const (
 	linkNotPinned = "not pinned"
 	linkAny       = "any"
 	linkAll       = "all"
+
+	// depth limited pins are stored in one set per depth, named with
+	// this prefix followed by the depth
+	linkDepthPrefix = "depth-"
 )
 
 type PinMode int
@@ -86,6 +92,11 @@ type Pinner interface {
 	Pin(context.Context, node.Node, bool) error
 	Unpin(context.Context, *cid.Cid, bool) error
 
+	// PinToDepth pins a node along with its descendants down to the given
+	// depth, its direct children being at depth 1. Such pins are reported
+	// and removed as recursive ones.
+	PinToDepth(context.Context, node.Node, int) error
+
 	// Check if a set of keys are pinned, more efficient than
 	// calling IsPinned for each key
 	CheckIfPinned(cids ...*cid.Cid) ([]Pinned, error)
@@ -102,7 +113,20 @@ type Pinner interface {
 	Flush() error
 	DirectKeys() []*cid.Cid
 	RecursiveKeys() []*cid.Cid
+	DepthLimitedPins() []DepthPin
 	InternalPins() []*cid.Cid
+
+	// SetRefCounts makes the pinner keep the reference count index r up
+	// to date as recursive pins are added and removed, while it is valid.
+	SetRefCounts(r *RefCounts)
+	// RefCounts returns the index set with SetRefCounts, or nil.
+	RefCounts() *RefCounts
+}
+
+// DepthPin is a pin of a node and its descendants down to MaxDepth.
+type DepthPin struct {
+	Key      *cid.Cid
+	MaxDepth int
 }
 
 type Pinned struct {
@@ -137,12 +161,18 @@ type pinner struct {
 	recursePin *cid.Set
 	directPin  *cid.Set
 
+	// depthPin holds the depth limited pins, by cid key
+	depthPin map[string]DepthPin
+
 	// Track the keys used for storing the pinning state, so gc does
 	// not delete them.
 	internalPin *cid.Set
 	dserv       mdag.DAGService
 	internal    mdag.DAGService // dagservice used to store internal objects
 	dstore      ds.Datastore
+
+	// refs, if not nil, is kept up to date with the recursive pins
+	refs *RefCounts
 }
 
 // NewPinner creates a new pinner using the given datastore as a backend
@@ -154,6 +184,7 @@ func NewPinner(dstore ds.Datastore, serv, internal mdag.DAGService) Pinner {
 	return &pinner{
 		recursePin:  rcset,
 		directPin:   dirset,
+		depthPin:    make(map[string]DepthPin),
 		dserv:       serv,
 		dstore:      dstore,
 		internal:    internal,
@@ -175,6 +206,7 @@ func (p *pinner) Pin(ctx context.Context, node node.Node, recurse bool) error {
 		if p.directPin.Has(c) {
 			p.directPin.Remove(c)
 		}
+		delete(p.depthPin, c.KeyString())
 
 		// fetch entire graph
 		err := mdag.FetchGraph(ctx, c, p.dserv)
@@ -183,6 +215,7 @@ func (p *pinner) Pin(ctx context.Context, node node.Node, recurse bool) error {
 		}
 
 		p.recursePin.Add(c)
+		p.countRefs(ctx, c)
 	} else {
 		if _, err := p.dserv.Get(ctx, c); err != nil {
 			return err
@@ -191,12 +224,43 @@ func (p *pinner) Pin(ctx context.Context, node node.Node, recurse bool) error {
 		if p.recursePin.Has(c) {
 			return fmt.Errorf("%s already pinned recursively", c.String())
 		}
+		if _, ok := p.depthPin[c.KeyString()]; ok {
+			return fmt.Errorf("%s already pinned recursively", c.String())
+		}
 
 		p.directPin.Add(c)
 	}
 	return nil
 }
 
+func (p *pinner) PinToDepth(ctx context.Context, nd node.Node, depth int) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	c := nd.Cid()
+
+	if p.recursePin.Has(c) {
+		return nil
+	}
+	if dp, ok := p.depthPin[c.KeyString()]; ok && dp.MaxDepth >= depth {
+		return nil
+	}
+
+	// fetch the graph down to depth. The nodes at the bottom, and raw
+	// leaves, are not fetched by the walk as their links are not needed.
+	sel := &mdag.Selector{MaxDepth: depth}
+	err := mdag.Walk(ctx, p.dserv, c, sel, func(_ *cid.Cid, lnk *node.Link, _ int) (bool, error) {
+		_, err := p.dserv.Get(ctx, lnk.Cid)
+		return err == nil, err
+	})
+	if err != nil {
+		return err
+	}
+
+	p.directPin.Remove(c)
+	p.depthPin[c.KeyString()] = DepthPin{Key: c, MaxDepth: depth}
+	return nil
+}
+
 var ErrNotPinned = fmt.Errorf("not pinned")
 
 // Unpin a given key
@@ -213,7 +277,7 @@ func (p *pinner) Unpin(ctx context.Context, c *cid.Cid, recursive bool) error {
 	switch reason {
 	case "recursive":
 		if recursive {
-			p.recursePin.Remove(c)
+			p.removeRecursive(ctx, c)
 			return nil
 		} else {
 			return fmt.Errorf("%s is pinned recursively", c)
@@ -257,6 +321,9 @@ func (p *pinner) isPinnedWithType(c *cid.Cid, mode PinMode) (string, bool, error
 	if (mode == Recursive || mode == Any) && p.recursePin.Has(c) {
 		return linkRecursive, true, nil
 	}
+	if _, ok := p.depthPin[c.KeyString()]; ok && (mode == Recursive || mode == Any) {
+		return linkRecursive, true, nil
+	}
 	if mode == Recursive {
 		return "", false, nil
 	}
@@ -285,6 +352,15 @@ func (p *pinner) isPinnedWithType(c *cid.Cid, mode PinMode) (string, bool, error
 			return rc.String(), true, nil
 		}
 	}
+	for _, dp := range p.depthPin {
+		has, err := hasChildToDepth(p.dserv, dp.Key, c, dp.MaxDepth)
+		if err != nil {
+			return "", false, err
+		}
+		if has {
+			return dp.Key.String(), true, nil
+		}
+	}
 	return "", false, nil
 }
 
@@ -296,7 +372,7 @@ func (p *pinner) CheckIfPinned(cids ...*cid.Cid) ([]Pinned, error) {
 
 	// First check for non-Indirect pins directly
 	for _, c := range cids {
-		if p.recursePin.Has(c) {
+		if _, ok := p.depthPin[c.KeyString()]; ok || p.recursePin.Has(c) {
 			pinned = append(pinned, Pinned{Key: c, Mode: Recursive})
 		} else if p.directPin.Has(c) {
 			pinned = append(pinned, Pinned{Key: c, Mode: Direct})
@@ -345,6 +421,24 @@ func (p *pinner) CheckIfPinned(cids ...*cid.Cid) ([]Pinned, error) {
 		}
 	}
 
+	for _, dp := range p.depthPin {
+		if toCheck.Len() == 0 {
+			break
+		}
+
+		rk := dp.Key
+		err := mdag.Walk(context.Background(), p.dserv, rk, &mdag.Selector{MaxDepth: dp.MaxDepth}, func(_ *cid.Cid, lnk *node.Link, _ int) (bool, error) {
+			if toCheck.Has(lnk.Cid) {
+				pinned = append(pinned, Pinned{Key: lnk.Cid, Mode: Indirect, Via: rk})
+				toCheck.Remove(lnk.Cid)
+			}
+			return toCheck.Len() > 0, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Anything left in toCheck is not pinned
 	for _, k := range toCheck.Keys() {
 		pinned = append(pinned, Pinned{Key: k, Mode: NotPinned})
@@ -360,7 +454,7 @@ func (p *pinner) RemovePinWithMode(c *cid.Cid, mode PinMode) {
 	case Direct:
 		p.directPin.Remove(c)
 	case Recursive:
-		p.recursePin.Remove(c)
+		p.removeRecursive(context.TODO(), c)
 	default:
 		// programmer error, panic OK
 		panic("unrecognized pin type")
@@ -426,6 +520,26 @@ func LoadPinner(d ds.Datastore, dserv, internal mdag.DAGService) (Pinner, error)
 		p.directPin = cidSetWithValues(directKeys)
 	}
 
+	// load depth limited sets, absent from the pin state of older versions
+	p.depthPin = make(map[string]DepthPin)
+	for _, lnk := range rootpb.Links() {
+		if !strings.HasPrefix(lnk.Name, linkDepthPrefix) {
+			continue
+		}
+		depth, err := strconv.Atoi(strings.TrimPrefix(lnk.Name, linkDepthPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("cannot load depth limited pins: bad set name %q", lnk.Name)
+		}
+
+		keys, err := loadSet(ctx, internal, rootpb, lnk.Name, recordInternal)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load depth limited pins: %v", err)
+		}
+		for _, c := range keys {
+			p.depthPin[c.KeyString()] = DepthPin{Key: c, MaxDepth: depth}
+		}
+	}
+
 	p.internalPin = internalset
 
 	// assign services
@@ -446,6 +560,17 @@ func (p *pinner) RecursiveKeys() []*cid.Cid {
 	return p.recursePin.Keys()
 }
 
+// DepthLimitedPins returns the depth limited pins
+func (p *pinner) DepthLimitedPins() []DepthPin {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	out := make([]DepthPin, 0, len(p.depthPin))
+	for _, dp := range p.depthPin {
+		out = append(out, dp)
+	}
+	return out
+}
+
 // Flush encodes and writes pinner keysets to the datastore
 func (p *pinner) Flush() error {
 	p.lock.Lock()
@@ -477,6 +602,20 @@ func (p *pinner) Flush() error {
 		}
 	}
 
+	byDepth := make(map[int][]*cid.Cid)
+	for _, dp := range p.depthPin {
+		byDepth[dp.MaxDepth] = append(byDepth[dp.MaxDepth], dp.Key)
+	}
+	for depth, keys := range byDepth {
+		n, err := storeSet(ctx, p.internal, keys, recordInternal)
+		if err != nil {
+			return err
+		}
+		if err := root.AddNodeLink(linkDepthPrefix+strconv.Itoa(depth), n); err != nil {
+			return err
+		}
+	}
+
 	// add the empty node, its referenced by the pin sets but never created
 	_, err := p.internal.Add(new(mdag.ProtoNode))
 	if err != nil {
@@ -493,6 +632,13 @@ func (p *pinner) Flush() error {
 		return fmt.Errorf("cannot store pin state: %v", err)
 	}
 	p.internalPin = internalset
+
+	// the pins counted by the index are stored now
+	if p.refs != nil {
+		if err := p.refs.flushed(); err != nil {
+			return fmt.Errorf("cannot store reference count index state: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -513,12 +659,66 @@ func (p *pinner) PinWithMode(c *cid.Cid, mode PinMode) {
 	defer p.lock.Unlock()
 	switch mode {
 	case Recursive:
-		p.recursePin.Add(c)
+		if !p.recursePin.Has(c) {
+			p.recursePin.Add(c)
+			p.countRefs(context.TODO(), c)
+		}
 	case Direct:
 		p.directPin.Add(c)
 	}
 }
 
+func (p *pinner) SetRefCounts(r *RefCounts) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.refs = r
+}
+
+func (p *pinner) RefCounts() *RefCounts {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.refs
+}
+
+// countRefs counts the references of the new recursive pin of c, if there
+// is a valid reference count index.
+func (p *pinner) countRefs(ctx context.Context, c *cid.Cid) {
+	if p.refs == nil || !p.refs.Valid() {
+		return
+	}
+	if err := p.refs.add(ctx, p.dserv, c); err != nil {
+		p.refs.invalidate(err)
+	}
+}
+
+// removeRecursive removes the recursive or depth limited pin of c, and the
+// references it counted.
+func (p *pinner) removeRecursive(ctx context.Context, c *cid.Cid) {
+	delete(p.depthPin, c.KeyString())
+	if !p.recursePin.Has(c) {
+		return
+	}
+	p.recursePin.Remove(c)
+
+	if p.refs == nil || !p.refs.Valid() {
+		return
+	}
+	if err := p.refs.release(ctx, p.dserv, c); err != nil {
+		p.refs.invalidate(err)
+	}
+}
+
+// hasChildToDepth returns whether child is linked from root, at most depth
+// levels below it.
+func hasChildToDepth(ds mdag.LinkService, root *cid.Cid, child *cid.Cid, depth int) (bool, error) {
+	var found bool
+	err := mdag.Walk(context.Background(), ds, root, &mdag.Selector{MaxDepth: depth}, func(_ *cid.Cid, lnk *node.Link, _ int) (bool, error) {
+		found = found || lnk.Cid.Equals(child)
+		return !found, nil
+	})
+	return found, err
+}
+
 func hasChild(ds mdag.LinkService, root *cid.Cid, child *cid.Cid) (bool, error) {
 	links, err := ds.GetLinks(context.Background(), root)
 	if err != nil {
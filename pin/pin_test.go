@@ -237,3 +237,69 @@ func TestPinRecursiveFail(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestPinToDepth(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv, dserv)
+
+	// a chain a -> b -> c -> d, where d is not available
+	var chain []*mdag.ProtoNode
+	for i := 0; i < 4; i++ {
+		nd, _ := randNode()
+		chain = append(chain, nd)
+	}
+	for i := 2; i >= 0; i-- {
+		if err := chain[i].AddNodeLinkClean("next", chain[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, nd := range chain[:3] {
+		if _, err := dserv.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := p.PinToDepth(ctx, chain[0], 2); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(p Pinner) {
+		for _, nd := range chain[:3] {
+			assertPinned(t, p, nd.Cid(), "node within the depth is not pinned")
+		}
+		if _, pinned, err := p.IsPinned(chain[3].Cid()); err != nil || pinned {
+			t.Fatal("node below the depth is pinned")
+		}
+
+		dps := p.DepthLimitedPins()
+		if len(dps) != 1 || !dps[0].Key.Equals(chain[0].Cid()) || dps[0].MaxDepth != 2 {
+			t.Fatalf("unexpected depth limited pins: %v", dps)
+		}
+	}
+	check(p)
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	np, err := LoadPinner(dstore, dserv, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(np)
+
+	if err := np.Pin(ctx, chain[0], false); err == nil {
+		t.Fatal("expected direct pin of a depth limited pin to fail")
+	}
+
+	if err := np.Unpin(ctx, chain[0].Cid(), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, pinned, err := np.IsPinned(chain[1].Cid()); err != nil || pinned {
+		t.Fatal("node still pinned after unpinning")
+	}
+}
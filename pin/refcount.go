@@ -0,0 +1,422 @@
+package pin
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	dshelp "github.com/ipfs/go-ipfs/thirdparty/ds-help"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+var (
+	refCountsKey   = ds.NewKey("/local/refcounts/counts")
+	releasedKey    = ds.NewKey("/local/refcounts/released")
+	refCountsValid = ds.NewKey("/local/refcounts/valid")
+)
+
+// ErrRefCountsInvalid is returned when using a reference count index that
+// does not match the pins, as it was never built or an update of it failed.
+var ErrRefCountsInvalid = errors.New("the reference count index needs to be rebuilt, see 'ipfs repo fsck --refcounts'")
+
+// RefCounts is an index, held in a datastore, of the number of references
+// each block has from the recursive pins and from the blocks under them.
+// A block has one reference per recursive pin of it, plus one per link to
+// it from a referenced block: the blocks with references are exactly the
+// ones the recursive pins keep. Depth limited and direct pins are not
+// counted.
+//
+// The index is kept up to date by a pinner it is set on, as recursive pins
+// are added and removed, rather than by the adds of the blocks: a block
+// added only gets references once something pinned recursively links to
+// it. The blocks whose count drops to zero as a pin is removed are recorded
+// as released, for an incremental collection to only look at them.
+//
+// Counts are updated as pins change, before the pins are flushed. The
+// index is marked as invalid in the datastore before an update, and as
+// valid again once the pinner flushed the pins, so that after a crash in
+// between it is not used until it is rebuilt. If an update fails, the
+// index is marked as invalid in memory as well.
+type RefCounts struct {
+	d ds.Batching
+
+	lk    sync.Mutex
+	valid bool
+
+	// stored is whether the datastore holds the index as valid
+	stored bool
+}
+
+// NewRefCounts returns the reference count index held in d.
+func NewRefCounts(d ds.Batching) (*RefCounts, error) {
+	has, err := d.Has(refCountsValid)
+	if err != nil {
+		return nil, err
+	}
+	return &RefCounts{d: d, valid: has, stored: has}, nil
+}
+
+// Valid returns whether the index matches the recursive pins, and can be
+// relied upon.
+func (r *RefCounts) Valid() bool {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	return r.valid
+}
+
+// invalidate marks the index as not matching the pins anymore, after err.
+func (r *RefCounts) invalidate(err error) {
+	log.Errorf("reference count index invalidated: %s", err)
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.valid = false
+	if err := r.d.Delete(refCountsValid); err != nil && err != ds.ErrNotFound {
+		log.Errorf("cannot invalidate reference count index: %s", err)
+	}
+	r.stored = false
+}
+
+// begin marks the index as invalid in the datastore before it is updated,
+// until the pins are flushed.
+func (r *RefCounts) begin() error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if !r.stored {
+		return nil
+	}
+	if err := r.d.Delete(refCountsValid); err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	r.stored = false
+	return nil
+}
+
+// flushed marks the index as valid in the datastore again, once the pins
+// it was updated for are flushed.
+func (r *RefCounts) flushed() error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if !r.valid || r.stored {
+		return nil
+	}
+	if err := r.d.Put(refCountsValid, []byte{}); err != nil {
+		return err
+	}
+	r.stored = true
+	return nil
+}
+
+func (r *RefCounts) countKey(c *cid.Cid) ds.Key {
+	return refCountsKey.Child(dshelp.CidToDsKey(c))
+}
+
+func (r *RefCounts) releasedKey(c *cid.Cid) ds.Key {
+	return releasedKey.Child(dshelp.CidToDsKey(c))
+}
+
+// Count returns the number of references to c.
+func (r *RefCounts) Count(c *cid.Cid) (uint64, error) {
+	return r.count(r.d, c)
+}
+
+// getter reads values, from the datastore or through a refBatch.
+type getter interface {
+	Get(ds.Key) (interface{}, error)
+}
+
+func (r *RefCounts) count(d getter, c *cid.Cid) (uint64, error) {
+	v, err := d.Get(r.countKey(c))
+	if err == ds.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	b, ok := v.([]byte)
+	if !ok {
+		return 0, fmt.Errorf("reference count of %s was not bytes", c)
+	}
+	n, l := binary.Uvarint(b)
+	if l <= 0 {
+		return 0, fmt.Errorf("bad reference count for %s", c)
+	}
+	return n, nil
+}
+
+// refBatchSize is the number of changes to the index written at a time
+const refBatchSize = 4096
+
+// refBatch buffers changes to the index, and writes them in batches. Reads
+// through it see the changes not written yet.
+type refBatch struct {
+	d    ds.Batching
+	puts map[ds.Key][]byte
+	dels map[ds.Key]struct{}
+}
+
+func newRefBatch(d ds.Batching) *refBatch {
+	return &refBatch{
+		d:    d,
+		puts: make(map[ds.Key][]byte),
+		dels: make(map[ds.Key]struct{}),
+	}
+}
+
+func (b *refBatch) Get(k ds.Key) (interface{}, error) {
+	if v, ok := b.puts[k]; ok {
+		return v, nil
+	}
+	if _, ok := b.dels[k]; ok {
+		return nil, ds.ErrNotFound
+	}
+	return b.d.Get(k)
+}
+
+func (b *refBatch) put(k ds.Key, v []byte) error {
+	delete(b.dels, k)
+	b.puts[k] = v
+	return b.maybeCommit()
+}
+
+func (b *refBatch) delete(k ds.Key) error {
+	delete(b.puts, k)
+	// batches fail on deleting keys not there
+	has, err := b.d.Has(k)
+	if err != nil || !has {
+		return err
+	}
+	b.dels[k] = struct{}{}
+	return b.maybeCommit()
+}
+
+func (b *refBatch) maybeCommit() error {
+	if len(b.puts)+len(b.dels) < refBatchSize {
+		return nil
+	}
+	return b.commit()
+}
+
+// commit writes the buffered changes.
+func (b *refBatch) commit() error {
+	if len(b.puts)+len(b.dels) == 0 {
+		return nil
+	}
+
+	batch, err := b.d.Batch()
+	if err != nil {
+		return err
+	}
+	for k, v := range b.puts {
+		if err := batch.Put(k, v); err != nil {
+			return err
+		}
+	}
+	for k := range b.dels {
+		if err := batch.Delete(k); err != nil {
+			return err
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+
+	b.puts = make(map[ds.Key][]byte)
+	b.dels = make(map[ds.Key]struct{})
+	return nil
+}
+
+// adjust adds delta to the count of c in b, and returns the new count.
+func (r *RefCounts) adjust(b *refBatch, c *cid.Cid, delta int) (uint64, error) {
+	n, err := r.count(b, c)
+	if err != nil {
+		return 0, err
+	}
+	if delta < 0 && n < uint64(-delta) {
+		return 0, fmt.Errorf("reference count of %s below zero", c)
+	}
+	n = uint64(int64(n) + int64(delta))
+
+	if n == 0 {
+		return 0, b.delete(r.countKey(c))
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	return n, b.put(r.countKey(c), buf[:binary.PutUvarint(buf, n)])
+}
+
+// add counts a recursive pin of root, along with the links of the blocks
+// under it which get their first reference.
+func (r *RefCounts) add(ctx context.Context, ls mdag.LinkService, root *cid.Cid) error {
+	if err := r.begin(); err != nil {
+		return err
+	}
+
+	b := newRefBatch(r.d)
+	stack := []*cid.Cid{root}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		n, err := r.adjust(b, c, 1)
+		if err != nil {
+			return err
+		}
+		if n > 1 {
+			// its links are counted already
+			continue
+		}
+		if err := b.delete(r.releasedKey(c)); err != nil {
+			return err
+		}
+
+		links, err := ls.GetLinks(ctx, c)
+		if err != nil {
+			return err
+		}
+		for _, lnk := range links {
+			stack = append(stack, lnk.Cid)
+		}
+	}
+	return b.commit()
+}
+
+// release uncounts a recursive pin of root, along with the links of the
+// blocks under it which lose their last reference. Those are recorded as
+// released.
+func (r *RefCounts) release(ctx context.Context, ls mdag.LinkService, root *cid.Cid) error {
+	if err := r.begin(); err != nil {
+		return err
+	}
+
+	b := newRefBatch(r.d)
+	stack := []*cid.Cid{root}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		n, err := r.adjust(b, c, -1)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if err := b.put(r.releasedKey(c), []byte{}); err != nil {
+			return err
+		}
+
+		links, err := ls.GetLinks(ctx, c)
+		if err != nil {
+			return err
+		}
+		for _, lnk := range links {
+			stack = append(stack, lnk.Cid)
+		}
+	}
+	return b.commit()
+}
+
+// Released sends on the returned channel the blocks released by the removal
+// of pins, not forgotten with Forget since.
+func (r *RefCounts) Released(ctx context.Context) (<-chan *cid.Cid, error) {
+	res, err := r.d.Query(dsq.Query{Prefix: releasedKey.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *cid.Cid, dsq.KeysOnlyBufSize)
+	go func() {
+		defer close(out)
+		defer res.Close()
+
+		for e := range res.Next() {
+			if e.Error != nil {
+				log.Errorf("listing released blocks: %s", e.Error)
+				return
+			}
+
+			c, err := dshelp.DsKeyToCid(ds.RawKey(e.Key[len(releasedKey.String()):]))
+			if err != nil {
+				log.Errorf("bad released block key %s: %s", e.Key, err)
+				continue
+			}
+
+			select {
+			case out <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Forget removes the given blocks from the released ones, once a
+// collection went through them.
+func (r *RefCounts) Forget(cids []*cid.Cid) error {
+	for _, c := range cids {
+		if err := r.d.Delete(r.releasedKey(c)); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebuild recomputes the index from the given recursive pins, and marks it
+// as valid. The pins must not change while it runs.
+func (r *RefCounts) Rebuild(ctx context.Context, ls mdag.LinkService, roots []*cid.Cid) error {
+	r.lk.Lock()
+	r.valid = false
+	r.stored = false
+	r.lk.Unlock()
+
+	if err := r.d.Delete(refCountsValid); err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	for _, prefix := range []ds.Key{refCountsKey, releasedKey} {
+		if err := r.clear(prefix); err != nil {
+			return err
+		}
+	}
+
+	for _, root := range roots {
+		if err := r.add(ctx, ls, root); err != nil {
+			return err
+		}
+	}
+
+	if err := r.d.Put(refCountsValid, []byte{}); err != nil {
+		return err
+	}
+	r.lk.Lock()
+	r.valid = true
+	r.stored = true
+	r.lk.Unlock()
+	return nil
+}
+
+// clear removes all the keys under prefix.
+func (r *RefCounts) clear(prefix ds.Key) error {
+	res, err := r.d.Query(dsq.Query{Prefix: prefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := r.d.Delete(ds.RawKey(e.Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package pin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/blocks/blockstore"
+	bs "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+func assertCount(t *testing.T, r *RefCounts, c *cid.Cid, expected uint64) {
+	n, err := r.Count(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != expected {
+		t.Fatalf("expected %d references, got %d", expected, n)
+	}
+}
+
+func released(t *testing.T, r *RefCounts) *cid.Set {
+	keys, err := r.Released(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := cid.NewSet()
+	for c := range keys {
+		set.Add(c)
+	}
+	return set
+}
+
+func TestRefCounts(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	p := NewPinner(dstore, dserv, dserv)
+
+	refs, err := NewRefCounts(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := refs.Rebuild(ctx, dserv, nil); err != nil {
+		t.Fatal(err)
+	}
+	p.SetRefCounts(refs)
+
+	// a and b both link to the shared node, a twice
+	shared, sk := randNode()
+	a, _ := randNode()
+	b, _ := randNode()
+	for _, parent := range []*mdag.ProtoNode{a, a, b} {
+		if err := parent.AddNodeLinkClean("", shared); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, nd := range []*mdag.ProtoNode{shared, a, b} {
+		if _, err := dserv.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ak, bk := a.Cid(), b.Cid()
+
+	if err := p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, b, true); err != nil {
+		t.Fatal(err)
+	}
+	assertCount(t, refs, ak, 1)
+	assertCount(t, refs, sk, 3)
+
+	// unpinning a only releases a
+	if err := p.Unpin(ctx, ak, true); err != nil {
+		t.Fatal(err)
+	}
+	assertCount(t, refs, ak, 0)
+	assertCount(t, refs, sk, 1)
+	rel := released(t, refs)
+	if rel.Len() != 1 || !rel.Has(ak) {
+		t.Fatalf("expected a to be released, got %v", rel.Keys())
+	}
+
+	// a rebuild counts the same
+	if err := refs.Rebuild(ctx, dserv, p.RecursiveKeys()); err != nil {
+		t.Fatal(err)
+	}
+	assertCount(t, refs, bk, 1)
+	assertCount(t, refs, sk, 1)
+	if released(t, refs).Len() != 0 {
+		t.Fatal("released blocks left after a rebuild")
+	}
+
+	if err := p.Unpin(ctx, bk, true); err != nil {
+		t.Fatal(err)
+	}
+	rel = released(t, refs)
+	if rel.Len() != 2 || !rel.Has(bk) || !rel.Has(sk) {
+		t.Fatalf("expected b and the shared node to be released, got %v", rel.Keys())
+	}
+
+	if err := refs.Forget(rel.Keys()); err != nil {
+		t.Fatal(err)
+	}
+	if released(t, refs).Len() != 0 {
+		t.Fatal("released blocks not forgotten")
+	}
+	if !refs.Valid() {
+		t.Fatal("index invalidated")
+	}
+}
+
+func TestRefCountsUnflushed(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	p := NewPinner(dstore, dserv, dserv)
+
+	refs, err := NewRefCounts(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := refs.Rebuild(ctx, dserv, nil); err != nil {
+		t.Fatal(err)
+	}
+	p.SetRefCounts(refs)
+
+	a, _ := randNode()
+	child, ck := randNode()
+	if err := a.AddNodeLinkClean("", child); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []*mdag.ProtoNode{child, a} {
+		if _, err := dserv.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ak := a.Cid()
+
+	if err := p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reload := func() (Pinner, *RefCounts) {
+		lp, err := LoadPinner(dstore, dserv, dserv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lr, err := NewRefCounts(dstore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lp, lr
+	}
+
+	_, lr := reload()
+	if !lr.Valid() {
+		t.Fatal("index not valid once the pins are flushed")
+	}
+	assertCount(t, lr, ck, 1)
+
+	// a crash before the unpin is flushed keeps the pin, and the index
+	// updated for the unpin is not used
+	if err := p.Unpin(ctx, ak, true); err != nil {
+		t.Fatal(err)
+	}
+	lp, lr := reload()
+	if _, pinned, err := lp.IsPinned(ak); err != nil || !pinned {
+		t.Fatal("unflushed unpin lost the pin")
+	}
+	if lr.Valid() {
+		t.Fatal("index updated for an unflushed unpin is valid")
+	}
+	if !refs.Valid() {
+		t.Fatal("index of the pinner invalidated")
+	}
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	lp, lr = reload()
+	if _, pinned, err := lp.IsPinned(ak); err != nil || pinned {
+		t.Fatal("flushed unpin did not remove the pin")
+	}
+	if !lr.Valid() {
+		t.Fatal("index not valid once the unpin is flushed")
+	}
+	assertCount(t, lr, ck, 0)
+}
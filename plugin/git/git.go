@@ -0,0 +1,379 @@
+// Package git implements an IPLD codec for git objects, so that blobs,
+// trees, commits and tags can be stored and traversed as dag nodes.
+//
+// A git object block holds the uncompressed object, header included, and
+// its CID is a CIDv1 with the git-raw codec over the sha1 hash git itself
+// uses. Importing the package registers the codec with merkledag, and
+// 'raw' and 'zlib' input encodings of the 'git' format with 'ipfs dag put'.
+package git
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	coredag "github.com/ipfs/go-ipfs/core/coredag"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	mh "gx/ipfs/QmYDds3421prZgqKbLpEK7T9Aa2eVdQ7o3YarX1LVLdP2J/go-multihash"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// GitRaw is the multicodec of git objects.
+const GitRaw = 0x78
+
+var ErrMalformed = errors.New("git: malformed object")
+
+func init() {
+	dag.RegisterCodec(GitRaw, DecodeBlock)
+	coredag.AddParser("raw", "git", ParseRaw)
+	coredag.AddParser("zlib", "git", ParseZlib)
+}
+
+// TreeEntry is an entry of a git tree.
+type TreeEntry struct {
+	Mode string
+	Name string
+	Hash *cid.Cid
+}
+
+// Object is a git object. Only the fields of its type are set.
+type Object struct {
+	blocks.Block
+
+	Type string
+
+	// tree
+	Entries []TreeEntry
+
+	// commit
+	TreeCid   *cid.Cid
+	Parents   []*cid.Cid
+	Author    string
+	Committer string
+
+	// tag
+	Object  *cid.Cid
+	ObjType string
+	Tag     string
+	Tagger  string
+
+	// commit and tag
+	Message string
+}
+
+// DecodeBlock decodes a git-raw block.
+func DecodeBlock(b blocks.Block) (node.Node, error) {
+	if pref := b.Cid().Prefix(); pref.MhType != mh.SHA1 {
+		return nil, fmt.Errorf("git: objects must be hashed with sha1, %s is not", b.Cid())
+	}
+
+	o, err := parseObject(b.RawData())
+	if err != nil {
+		return nil, err
+	}
+	o.Block = b
+	return o, nil
+}
+
+// ParseRaw reads an uncompressed git object, as printed by
+// 'git cat-file --batch' without its first line or stored in a pack.
+func ParseRaw(r io.Reader) (node.Node, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := parseObject(data)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := mh.Sum(data, mh.SHA1, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	o.Block, err = blocks.NewBlockWithCid(data, cid.NewCidV1(GitRaw, h))
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// ParseZlib reads a zlib compressed git object, as stored in the loose object
+// directory of a git repository.
+func ParseZlib(r io.Reader) (node.Node, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return ParseRaw(zr)
+}
+
+// HashToCid returns the CID of the git object with the given sha1 hash.
+func HashToCid(sha []byte) *cid.Cid {
+	h, _ := mh.Encode(sha, mh.SHA1)
+	return cid.NewCidV1(GitRaw, h)
+}
+
+func hexToCid(s string) (*cid.Cid, error) {
+	sha, err := hex.DecodeString(s)
+	if err != nil || len(sha) != 20 {
+		return nil, ErrMalformed
+	}
+	return HashToCid(sha), nil
+}
+
+func parseObject(data []byte) (*Object, error) {
+	i := bytes.IndexByte(data, 0)
+	if i < 0 {
+		return nil, ErrMalformed
+	}
+
+	hdr := strings.SplitN(string(data[:i]), " ", 2)
+	if len(hdr) != 2 {
+		return nil, ErrMalformed
+	}
+	size, err := strconv.Atoi(hdr[1])
+	if err != nil || size != len(data)-i-1 {
+		return nil, ErrMalformed
+	}
+
+	o := &Object{Type: hdr[0]}
+	body := data[i+1:]
+
+	switch o.Type {
+	case "blob":
+	case "tree":
+		err = o.parseTree(body)
+	case "commit", "tag":
+		err = o.parseHeaders(body)
+	default:
+		return nil, fmt.Errorf("git: unknown object type %q", o.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *Object) parseTree(body []byte) error {
+	for len(body) > 0 {
+		i := bytes.IndexByte(body, 0)
+		if i < 0 || len(body) < i+21 {
+			return ErrMalformed
+		}
+
+		mn := strings.SplitN(string(body[:i]), " ", 2)
+		if len(mn) != 2 {
+			return ErrMalformed
+		}
+
+		o.Entries = append(o.Entries, TreeEntry{
+			Mode: mn[0],
+			Name: mn[1],
+			Hash: HashToCid(body[i+1 : i+21]),
+		})
+		body = body[i+21:]
+	}
+	return nil
+}
+
+// parseHeaders parses the header lines and the message of commits and tags.
+// Headers spanning several lines, like signatures, are skipped.
+func (o *Object) parseHeaders(body []byte) error {
+	text := string(body)
+	for {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			return ErrMalformed
+		}
+		line := text[:i]
+		text = text[i+1:]
+
+		if line == "" {
+			o.Message = text
+			// the links must be there
+			if (o.Type == "commit" && o.TreeCid == nil) || (o.Type == "tag" && o.Object == nil) {
+				return ErrMalformed
+			}
+			return nil
+		}
+
+		kv := strings.SplitN(line, " ", 2)
+		if len(kv) != 2 {
+			return ErrMalformed
+		}
+
+		var err error
+		switch kv[0] {
+		case "tree":
+			o.TreeCid, err = hexToCid(kv[1])
+		case "parent":
+			var c *cid.Cid
+			c, err = hexToCid(kv[1])
+			o.Parents = append(o.Parents, c)
+		case "object":
+			o.Object, err = hexToCid(kv[1])
+		case "author":
+			o.Author = kv[1]
+		case "committer":
+			o.Committer = kv[1]
+		case "type":
+			o.ObjType = kv[1]
+		case "tag":
+			o.Tag = kv[1]
+		case "tagger":
+			o.Tagger = kv[1]
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// fields returns the named fields of the object, links included.
+func (o *Object) fields() map[string]interface{} {
+	out := make(map[string]interface{})
+	switch o.Type {
+	case "tree":
+		for _, e := range o.Entries {
+			out[e.Name] = &node.Link{Name: e.Name, Cid: e.Hash}
+		}
+	case "commit":
+		out["tree"] = &node.Link{Name: "tree", Cid: o.TreeCid}
+		parents := make([]interface{}, len(o.Parents))
+		for i, p := range o.Parents {
+			parents[i] = &node.Link{Name: "parents/" + strconv.Itoa(i), Cid: p}
+		}
+		out["parents"] = parents
+		out["author"] = o.Author
+		out["committer"] = o.Committer
+		out["message"] = o.Message
+	case "tag":
+		out["object"] = &node.Link{Name: "object", Cid: o.Object}
+		out["type"] = o.ObjType
+		out["tag"] = o.Tag
+		out["tagger"] = o.Tagger
+		out["message"] = o.Message
+	}
+	return out
+}
+
+func (o *Object) Resolve(path []string) (interface{}, []string, error) {
+	if len(path) == 0 {
+		return o, nil, nil
+	}
+
+	v, ok := o.fields()[path[0]]
+	if !ok {
+		return nil, nil, dag.ErrLinkNotFound
+	}
+	path = path[1:]
+
+	if list, ok := v.([]interface{}); ok {
+		if len(path) == 0 {
+			return list, nil, nil
+		}
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(list) {
+			return nil, nil, dag.ErrLinkNotFound
+		}
+		return list[i], path[1:], nil
+	}
+	return v, path, nil
+}
+
+func (o *Object) ResolveLink(path []string) (*node.Link, []string, error) {
+	v, rest, err := o.Resolve(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lnk, ok := v.(*node.Link)
+	if !ok {
+		return nil, nil, fmt.Errorf("git: %s is not a link", strings.Join(path, "/"))
+	}
+	return lnk, rest, nil
+}
+
+func (o *Object) Links() []*node.Link {
+	var out []*node.Link
+	switch o.Type {
+	case "tree":
+		for _, e := range o.Entries {
+			out = append(out, &node.Link{Name: e.Name, Cid: e.Hash})
+		}
+	case "commit":
+		out = append(out, &node.Link{Name: "tree", Cid: o.TreeCid})
+		for i, p := range o.Parents {
+			out = append(out, &node.Link{Name: "parents/" + strconv.Itoa(i), Cid: p})
+		}
+	case "tag":
+		out = append(out, &node.Link{Name: "object", Cid: o.Object})
+	}
+	return out
+}
+
+func (o *Object) Tree(p string, depth int) []string {
+	if p != "" {
+		return nil
+	}
+
+	var out []string
+	for k, v := range o.fields() {
+		out = append(out, k)
+		if list, ok := v.([]interface{}); ok {
+			for i := range list {
+				out = append(out, k+"/"+strconv.Itoa(i))
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (o *Object) Copy() node.Node {
+	nd, err := DecodeBlock(o.Block)
+	if err != nil {
+		// programmer error
+		panic("failure attempting to clone git object: " + err.Error())
+	}
+	return nd
+}
+
+func (o *Object) Size() (uint64, error) {
+	return uint64(len(o.RawData())), nil
+}
+
+func (o *Object) Stat() (*node.NodeStat, error) {
+	return &node.NodeStat{
+		NumLinks:       len(o.Links()),
+		BlockSize:      len(o.RawData()),
+		CumulativeSize: len(o.RawData()),
+	}, nil
+}
+
+func (o *Object) MarshalJSON() ([]byte, error) {
+	out := o.fields()
+	if o.Type == "blob" {
+		i := bytes.IndexByte(o.RawData(), 0)
+		out["data"] = o.RawData()[i+1:]
+	}
+	return json.Marshal(out)
+}
+
+var _ node.Node = (*Object)(nil)
@@ -0,0 +1,179 @@
+package git
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	dstest "github.com/ipfs/go-ipfs/merkledag/test"
+
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
+	mh "gx/ipfs/QmYDds3421prZgqKbLpEK7T9Aa2eVdQ7o3YarX1LVLdP2J/go-multihash"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
+)
+
+// objects of a repository holding a single commit of a file 'hello', with
+// the hashes git gives them
+const (
+	blobHash   = "ce013625030ba8dba906f756967f9e9ca394464a"
+	treeHash   = "b4d01e9b0c4a9356736dfddf8830ba9a54f5271c"
+	commitHash = "8751090589875711fe62593dac2c815f98dbcee5"
+
+	commitBody = "tree " + treeHash + "\n" +
+		"author A <a@b> 1483228800 +0000\n" +
+		"committer A <a@b> 1483228800 +0000\n" +
+		"\n" +
+		"init\n"
+)
+
+func gitObject(typ string, body []byte) []byte {
+	hdr := typ + " " + strconv.Itoa(len(body)) + "\x00"
+	return append([]byte(hdr), body...)
+}
+
+func treeBody(t *testing.T) []byte {
+	sha, err := hex.DecodeString(blobHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte("100644 hello\x00"), sha...)
+}
+
+func parse(t *testing.T, data []byte) *Object {
+	nd, err := ParseRaw(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nd.(*Object)
+}
+
+func checkHash(t *testing.T, c *cid.Cid, exp string) {
+	if c.Type() != GitRaw {
+		t.Fatalf("expected git-raw codec, got %x", c.Type())
+	}
+	dmh, err := mh.Decode(c.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dmh.Code != mh.SHA1 || hex.EncodeToString(dmh.Digest) != exp {
+		t.Fatalf("expected hash %s, got %x", exp, dmh.Digest)
+	}
+}
+
+func TestParseObjects(t *testing.T) {
+	blob := parse(t, gitObject("blob", []byte("hello\n")))
+	checkHash(t, blob.Cid(), blobHash)
+	if len(blob.Links()) != 0 {
+		t.Fatal("blobs have no links")
+	}
+
+	tree := parse(t, gitObject("tree", treeBody(t)))
+	checkHash(t, tree.Cid(), treeHash)
+	if len(tree.Entries) != 1 || tree.Entries[0].Name != "hello" || tree.Entries[0].Mode != "100644" {
+		t.Fatalf("unexpected tree entries: %v", tree.Entries)
+	}
+	checkHash(t, tree.Entries[0].Hash, blobHash)
+
+	commit := parse(t, gitObject("commit", []byte(commitBody)))
+	checkHash(t, commit.Cid(), commitHash)
+	checkHash(t, commit.TreeCid, treeHash)
+	if commit.Author != "A <a@b> 1483228800 +0000" || commit.Message != "init\n" {
+		t.Fatalf("unexpected commit: %q, %q", commit.Author, commit.Message)
+	}
+	if len(commit.Parents) != 0 {
+		t.Fatal("root commit has no parents")
+	}
+
+	paths := commit.Tree("", -1)
+	if !sort.StringsAreSorted(paths) {
+		t.Fatalf("paths not sorted: %v", paths)
+	}
+}
+
+func TestParseZlib(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zw := zlib.NewWriter(buf)
+	zw.Write(gitObject("blob", []byte("hello\n")))
+	zw.Close()
+
+	nd, err := ParseZlib(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHash(t, nd.Cid(), blobHash)
+}
+
+func TestParseMalformed(t *testing.T) {
+	for _, data := range []string{
+		"blob 6",
+		"blob 7\x00hello\n",
+		"note 6\x00hello\n",
+		"tree 9\x00100644 hi",
+		"commit 10\x00tree abcd\n",
+		"commit 16\x00author A <a@b>\n\n",
+		"tag 8\x00tag v1\n\n",
+	} {
+		if _, err := ParseRaw(bytes.NewReader([]byte(data))); err == nil {
+			t.Fatalf("expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestResolveThroughDag(t *testing.T) {
+	ds := dstest.Mock()
+
+	var nodes []node.Node
+	for _, data := range [][]byte{
+		gitObject("blob", []byte("hello\n")),
+		gitObject("tree", treeBody(t)),
+		gitObject("commit", []byte(commitBody)),
+	} {
+		nd := parse(t, data)
+		if _, err := ds.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, nd)
+	}
+
+	// the registered codec decodes the blocks on the way back
+	nd, err := ds.Get(context.Background(), nodes[2].Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, ok := nd.(*Object)
+	if !ok || commit.Type != "commit" {
+		t.Fatalf("expected a decoded commit, got %T", nd)
+	}
+
+	lnk, rest, err := commit.ResolveLink([]string{"tree", "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lnk.Cid.Equals(nodes[1].Cid()) || len(rest) != 1 {
+		t.Fatal("commit tree did not resolve to the tree")
+	}
+
+	tree, err := lnk.GetNode(context.Background(), ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lnk, _, err = tree.ResolveLink(rest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lnk.Cid.Equals(nodes[0].Cid()) {
+		t.Fatal("tree entry did not resolve to the blob")
+	}
+
+	if _, _, err := commit.ResolveLink([]string{"author"}); err == nil {
+		t.Fatal("author is not a link")
+	}
+	if _, _, err := commit.Resolve([]string{"nope"}); err != dag.ErrLinkNotFound {
+		t.Fatalf("expected ErrLinkNotFound, got %v", err)
+	}
+}
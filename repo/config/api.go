@@ -1,5 +1,60 @@
 package config
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const APITag = "API"
+const TokensTag = "Tokens"
+const APITokensSelector = APITag + "." + TokensTag
+
+// DefaultAPITokenFile is the filename, in the configuration root, of the
+// secret the local command line authenticates to the API with.
+const DefaultAPITokenFile = "api_token"
+
+// LocalAPITokenName is the name of the token allowing all commands created
+// for the local command line when a first, scoped, token is added.
+const LocalAPITokenName = "local"
+
 type API struct {
 	HTTPHeaders map[string][]string // HTTP headers to return with the API.
+
+	// Tokens, when set, are the only credentials accepted by the API.
+	// Requests must carry one of them as a bearer token.
+	Tokens []APIToken
+}
+
+// APIToken is a bearer token scoped to a set of API commands. Only a hash
+// of its secret is kept.
+type APIToken struct {
+	Name       string
+	SecretHash string
+
+	// Commands lists the command paths the token may call, such as "cat",
+	// "pin/add" or "pin/*". "*" allows every command.
+	Commands []string
+}
+
+// IsAdmin reports whether the token allows every command.
+func (t *APIToken) IsAdmin() bool {
+	for _, c := range t.Commands {
+		if c == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// HashAPISecret returns the hash of an API token secret, as stored in
+// APIToken.SecretHash.
+func HashAPISecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// APITokenFilename returns the path of the local API token secret given a
+// configuration root directory.
+func APITokenFilename(configroot string) (string, error) {
+	return Path(configroot, DefaultAPITokenFile)
 }
@@ -29,6 +29,7 @@ type Config struct {
 	SupernodeRouting SupernodeClientConfig // local node's routing servers (if SupernodeRouting enabled)
 	API              API                   // local node's API settings
 	Swarm            SwarmConfig
+	Files            Files // settings of the 'ipfs files' root
 
 	Reprovider Reprovider
 }
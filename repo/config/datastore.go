@@ -7,6 +7,17 @@ import (
 // DefaultDataStoreDirectory is the directory to store all the local IPFS data.
 const DefaultDataStoreDirectory = "datastore"
 
+// Modes of the automatic garbage collection
+const (
+	// GCModeFull removes all the blocks not kept once the storage
+	// watermark is reached.
+	GCModeFull = "full"
+
+	// GCModeLRU evicts the least recently used blocks not kept, just
+	// enough to get back under the storage watermark.
+	GCModeLRU = "lru"
+)
+
 // Datastore tracks the configuration of the datastore.
 type Datastore struct {
 	Type               string
@@ -14,11 +25,13 @@ type Datastore struct {
 	StorageMax         string // in B, kB, kiB, MB, ...
 	StorageGCWatermark int64  // in percentage to multiply on StorageMax
 	GCPeriod           string // in ns, us, ms, s, m, h
+	GCMode             string // "full" or "lru"
 
 	Params          *json.RawMessage
 	NoSync          bool
 	HashOnRead      bool
 	BloomFilterSize int
+	RefCounts       bool // index the references to blocks from recursive pins
 }
 
 func (d *Datastore) ParamData() []byte {
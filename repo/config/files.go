@@ -0,0 +1,14 @@
+package config
+
+// Files contains the settings of the 'ipfs files' root.
+type Files struct {
+	Snapshots SnapshotPolicy
+}
+
+// SnapshotPolicy sets how many automatic snapshots of the files root are
+// kept for each period. A zero count disables snapshots for that period.
+type SnapshotPolicy struct {
+	Hourly int
+	Daily  int
+	Weekly int
+}
@@ -6,4 +6,12 @@ type Gateway struct {
 	RootRedirect string
 	Writable     bool
 	PathPrefixes []string
+
+	// SubdomainHosts are the hostnames under which content is served
+	// from per-object origins, e.g. <cid>.ipfs.<host> and <name>.ipns.<host>
+	SubdomainHosts []string
+
+	// WriteTokens are the bearer tokens that authorize writes to /ipns
+	// names backed by local keys on a writable gateway
+	WriteTokens []string
 }
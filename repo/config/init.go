@@ -59,9 +59,10 @@ func Init(out io.Writer, nBitsForKeypair int) (*Config, error) {
 		},
 
 		Gateway: Gateway{
-			RootRedirect: "",
-			Writable:     false,
-			PathPrefixes: []string{},
+			RootRedirect:   "",
+			Writable:       false,
+			PathPrefixes:   []string{},
+			SubdomainHosts: []string{},
 			HTTPHeaders: map[string][]string{
 				"Access-Control-Allow-Origin":  []string{"*"},
 				"Access-Control-Allow-Methods": []string{"GET"},
@@ -87,6 +88,7 @@ func datastoreConfig() (Datastore, error) {
 		StorageMax:         "10GB",
 		StorageGCWatermark: 90, // 90%
 		GCPeriod:           "1h",
+		GCMode:             GCModeFull,
 		HashOnRead:         false,
 		BloomFilterSize:    0,
 	}, nil
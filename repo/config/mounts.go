@@ -4,5 +4,6 @@ package config
 type Mounts struct {
 	IPFS           string
 	IPNS           string
+	MFS            string `json:",omitempty"` // files root, not mounted if empty
 	FuseAllowOther bool
 }
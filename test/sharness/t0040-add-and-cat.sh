@@ -74,6 +74,84 @@ test_add_cat_file() {
         test_cmp expected actual
     '
 
+    for chunker in buzhash fastcdc fastcdc-1024-4096-16384; do
+      test_expect_success "ipfs add --chunker $chunker succeeds" '
+          ipfs add --chunker $chunker mountdir/hello.txt >actual
+      '
+
+      test_expect_success "ipfs add --chunker $chunker output looks good" '
+          HASH="QmVr26fY1tKyspEJBniVhqxQeEjhF78XerGiqWAwraVLQH" &&
+          echo "added $HASH hello.txt" >expected &&
+          test_cmp expected actual
+      '
+    done
+
+    test_expect_success "ipfs add --chunker with bad fastcdc sizes fails" '
+        test_must_fail ipfs add --chunker fastcdc-4096-1024-16384 mountdir/hello.txt
+    '
+
+    test_expect_success "ipfs add --cid-version=1 succeeds" '
+        ipfs add --cid-version=1 mountdir/hello.txt >actual
+    '
+
+    test_expect_success "ipfs add --cid-version=1 output looks good" '
+        HASHV1="zdj7WcwGU6bsMoakXYDvXg7aDnyGSw8xeYfXAgFWJZcg24EaD" &&
+        echo "added $HASHV1 hello.txt" >expected &&
+        test_cmp expected actual
+    '
+
+    test_expect_success "ipfs add --hash=sha2-512 succeeds" '
+        ipfs add --hash=sha2-512 mountdir/hello.txt >actual
+    '
+
+    test_expect_success "ipfs add --hash=sha2-512 output looks good" '
+        HASH512="zBunRE2irPoFM7W6npkqvQ7kBXfWEqxwBrbz37Ytg7QqiYk9E2bcpSSBhnhzL2rNAK3iatwkMrRTjX52oWCSqbdoATxoo" &&
+        echo "added $HASH512 hello.txt" >expected &&
+        test_cmp expected actual
+    '
+
+    test_expect_success "ipfs cat on a sha2-512 hash works" '
+        ipfs cat "$HASH512" >actual &&
+        echo "Hello Worlds!" >expected &&
+        test_cmp expected actual
+    '
+
+    test_expect_success "ipfs add --cid-version=0 --hash=sha2-512 fails" '
+        test_must_fail ipfs add --cid-version=0 --hash=sha2-512 mountdir/hello.txt
+    '
+
+    test_expect_success "ipfs add --hash=id fails" '
+        test_must_fail ipfs add --hash=id mountdir/hello.txt 2>add_err &&
+        grep -- "--inline" add_err
+    '
+
+    test_expect_success "ipfs add --inline succeeds" '
+        ipfs add --inline mountdir/hello.txt >actual
+    '
+
+    test_expect_success "ipfs add --inline output looks good" '
+        HASHINLINE="z3Z5PRkHNifnEeMHFdpQ92cw8aX3pEhUgGwo" &&
+        echo "added $HASHINLINE hello.txt" >expected &&
+        test_cmp expected actual
+    '
+
+    test_expect_success "ipfs cat on an inlined hash works" '
+        ipfs cat "$HASHINLINE" >actual &&
+        echo "Hello Worlds!" >expected &&
+        test_cmp expected actual
+    '
+
+    test_expect_success "inlined node is not stored as a block" '
+        ipfs refs local >refs_local &&
+        test_expect_code 1 grep "$HASHINLINE" refs_local
+    '
+
+    test_expect_success "ipfs add --inline with a small limit does not inline" '
+        ipfs add -q --inline --inline-limit=8 mountdir/hello.txt >actual &&
+        echo "QmVr26fY1tKyspEJBniVhqxQeEjhF78XerGiqWAwraVLQH" >expected &&
+        test_cmp expected actual
+    '
+
     test_expect_success "ipfs add on hidden file succeeds" '
         echo "Hello Worlds!" >mountdir/.hello.txt &&
         ipfs add mountdir/.hello.txt >actual
@@ -197,6 +197,22 @@ test_expect_success "can read block with different hash" '
 	echo "foooo" > blk_get_exp &&
 	test_cmp blk_get_exp blk_get_out
 '
+test_expect_success "can set the hash function with --hash on block put" '
+	HASH=$(echo "Hello Mars!" | ipfs block put --format=raw --hash=sha2-512)
+'
+
+test_expect_success "output looks good" '
+	test "zB7NCf1MwnXZdcEBxrWudHNGuV2XGu29TVwQ5prCGzovCwZrY7UWBnKZT3c6X27EwbQu4p8sK6XtSRmoZHGTVoXpdLDqw" = "$HASH"
+'
+
+test_expect_success "block put with --cid-version=0 on a raw block fails" '
+	echo "Hello Mars!" | test_must_fail ipfs block put --format=raw --cid-version=0
+'
+
+test_expect_success "block put with --cid-version=0 and sha2-512 fails" '
+	echo "Hello Mars!" | test_must_fail ipfs block put --hash=sha2-512 --cid-version=0
+'
+
 #
 # Misc tests
 #
@@ -68,6 +68,77 @@ test_dag_cmd() {
 		echo "{\"data\":\"CAISBGZvbwoYBA==\",\"links\":[]}" > cat_exp &&
 		test_cmp cat_exp cat_out
 	'
+
+	test_expect_success "dag put takes several json objects per call" '
+		printf "{\"a\":1}\n{\"b\":2}\n" | ipfs dag put > multi_out &&
+		echo "{\"a\":1}" | ipfs dag put > multi_exp &&
+		echo "{\"b\":2}" | ipfs dag put >> multi_exp &&
+		test_cmp multi_exp multi_out
+	'
+
+	test_expect_success "dag put takes cbor input" '
+		ipfs block get $IPLDHASH > ipld.cbor &&
+		cat ipld.cbor ipld.cbor | ipfs dag put --input-enc=cbor > cbor_out &&
+		printf "%s\n%s\n" $IPLDHASH $IPLDHASH > cbor_exp &&
+		test_cmp cbor_exp cbor_out
+	'
+
+	test_expect_success "dag put takes protobuf input" '
+		ipfs block get $HASH1 | ipfs dag put --input-enc=protobuf --format=protobuf > pb_out &&
+		echo $HASH1 > pb_exp &&
+		test_cmp pb_exp pb_out
+	'
+
+	test_expect_success "dag put takes raw input" '
+		echo "some raw data" | ipfs dag put --input-enc=raw --format=raw > raw_out &&
+		ipfs block get $(cat raw_out) > raw_data &&
+		echo "some raw data" > raw_exp &&
+		test_cmp raw_exp raw_data
+	'
+
+	test_expect_success "dag put --hash" '
+		SHA512HASH=$(ipfs dag put --hash=sha2-512 < ipld_object) &&
+		test $SHA512HASH != $IPLDHASH &&
+		ipfs cat $SHA512HASH/cats/0 > out512 &&
+		test_cmp file1 out512
+	'
+
+	test_expect_success "dag put --hash with an unknown function fails" '
+		test_must_fail ipfs dag put --hash=nope < ipld_object
+	'
+
+	test_expect_success "dag put --pin" '
+		PINNEDHASH=$(echo "{\"pinned\":true}" | ipfs dag put --pin) &&
+		ipfs pin ls --type=recursive | grep $PINNEDHASH
+	'
+
+	test_expect_success "dag put of truncated cbor fails" '
+		head -c 10 ipld.cbor > short.cbor &&
+		test_must_fail ipfs dag put --input-enc=cbor < short.cbor
+	'
+
+	test_expect_success "dag resolve follows links to the last node" '
+		ipfs dag resolve $IPLDHASH/cats/1/water > resolve_out &&
+		echo $HASH2 > resolve_exp &&
+		test_cmp resolve_exp resolve_out
+	'
+
+	test_expect_success "dag resolve prints the path left inside a node" '
+		ipfs dag resolve $IPLDHASH/hello > resolve_out &&
+		echo $IPLDHASH/hello > resolve_exp &&
+		test_cmp resolve_exp resolve_out
+	'
+
+	test_expect_success "dag stat of a single block" '
+		ipfs dag stat $HASH1 > stat_out &&
+		echo "Size: 10, NumBlocks: 1" > stat_exp &&
+		test_cmp stat_exp stat_out
+	'
+
+	test_expect_success "dag stat counts the blocks of a cbor dag" '
+		ipfs dag stat $IPLDHASH > stat_out &&
+		grep "NumBlocks: 4" stat_out
+	'
 }
 
 # should work offline
@@ -78,4 +149,117 @@ test_launch_ipfs_daemon
 test_dag_cmd
 test_kill_ipfs_daemon
 
+test_expect_success "make a git repo" '
+	git init -q gitrepo &&
+	echo "hello" > gitrepo/hello &&
+	(cd gitrepo &&
+	git add hello &&
+	GIT_AUTHOR_DATE="2017-01-01T00:00:00Z" GIT_COMMITTER_DATE="2017-01-01T00:00:00Z" \
+	git -c user.name=A -c user.email=a@b commit -qm init) &&
+	GITOBJS=gitrepo/.git/objects
+'
+
+test_expect_success "can add git objects" '
+	GITBLOB=$(ipfs dag put --format=git --input-enc=zlib < $GITOBJS/ce/013625030ba8dba906f756967f9e9ca394464a) &&
+	GITTREE=$(ipfs dag put --format=git --input-enc=zlib < $GITOBJS/b4/d01e9b0c4a9356736dfddf8830ba9a54f5271c) &&
+	GITCOMMIT=$(ipfs dag put --format=git --input-enc=zlib < $GITOBJS/87/51090589875711fe62593dac2c815f98dbcee5)
+'
+
+test_expect_success "git object cids use the git hashes" '
+	test $GITBLOB = z8mWaJ67DvTLWsoWc4ysmMHrS5izfrgT3 &&
+	test $GITTREE = z8mWaHjkahtkmijYxFospGsD6XM3AiuRd &&
+	test $GITCOMMIT = z8mWaH6zLbUULT1w96a22VAAVqbajz6RS
+'
+
+test_expect_success "can resolve paths through git objects" '
+	ipfs dag get $GITCOMMIT/tree/hello > git_out &&
+	echo "{\"data\":\"aGVsbG8K\"}" > git_exp &&
+	test_cmp git_exp git_out
+'
+
+test_expect_success "git objects with a bad input encoding fail" '
+	test_must_fail ipfs dag put --format=git --input-enc=json < $GITOBJS/ce/013625030ba8dba906f756967f9e9ca394464a
+'
+
+test_expect_success "make a nested directory" '
+	mkdir -p nested/a/b &&
+	echo nested-foo > nested/foo &&
+	echo nested-bar > nested/a/bar &&
+	echo nested-baz > nested/a/b/baz &&
+	NESTED=$(ipfs add -r -q --pin=false nested | tail -n1) &&
+	NESTED_A=$(ipfs resolve -r /ipfs/$NESTED/a | cut -d/ -f3)
+'
+
+test_expect_success "refs --max-depth limits the depth" '
+	ipfs refs --max-depth=1 $NESTED | sort > refs_depth1 &&
+	ipfs refs $NESTED | sort > refs_direct &&
+	test_cmp refs_direct refs_depth1 &&
+	ipfs refs -r --max-depth=2 $NESTED | wc -l > refs_depth2 &&
+	ipfs refs -r $NESTED | wc -l > refs_all &&
+	echo 4 > refs_depth2_exp &&
+	echo 5 > refs_all_exp &&
+	test_cmp refs_depth2_exp refs_depth2 &&
+	test_cmp refs_all_exp refs_all
+'
+
+test_expect_success "refs --link-glob only follows matching links" '
+	ipfs refs -r --link-glob="a" $NESTED > refs_glob &&
+	echo $NESTED_A > refs_glob_exp &&
+	test_cmp refs_glob_exp refs_glob
+'
+
+test_expect_success "dag export --max-depth writes part of the dag" '
+	ipfs dag export $NESTED > nested_all.car &&
+	ipfs dag export --max-depth=1 $NESTED > nested_1.car &&
+	test $(wc -c < nested_1.car) -lt $(wc -c < nested_all.car)
+'
+
+test_expect_success "pin add --max-depth pins down to the given depth" '
+	ipfs pin add --max-depth=1 $NESTED &&
+	ipfs pin ls --type=recursive > pin_depth_out &&
+	grep "$NESTED recursive, max depth 1" pin_depth_out &&
+	ipfs pin ls --type=indirect $NESTED_A &&
+	test_must_fail ipfs pin ls $(ipfs resolve -r /ipfs/$NESTED/a/bar | cut -d/ -f3) &&
+	ipfs pin rm $NESTED
+'
+
+test_expect_success "can export a dag as a car file" '
+	ipfs dag export $IPLDHASH > dag.car &&
+	ipfs dag export $IPLDHASH > dag2.car
+'
+
+test_expect_success "export is deterministic" '
+	test_cmp dag.car dag2.car
+'
+
+test_expect_success "can import the car file in another repo" '
+	IPFS_PATH="$(pwd)/.ipfs-car" &&
+	export IPFS_PATH &&
+	ipfs init -b=1024 > /dev/null &&
+	ipfs dag import dag.car > import_out
+'
+
+test_expect_success "import printed the root" '
+	echo $IPLDHASH > import_exp &&
+	test_cmp import_exp import_out
+'
+
+test_expect_success "imported dag is pinned and readable offline" '
+	ipfs pin ls --type=recursive | grep $IPLDHASH &&
+	ipfs cat $IPLDHASH/cats/1/water > car_out2 &&
+	test_cmp file2 car_out2
+'
+
+test_expect_success "import without pinning the roots" '
+	ipfs pin rm $IPLDHASH &&
+	ipfs dag import --pin-roots=false dag.car &&
+	test_must_fail ipfs pin ls $IPLDHASH
+'
+
+test_expect_success "import of a corrupted car file fails" '
+	sed "s/baz/bad/" dag.car > bad.car &&
+	test_must_fail ipfs dag import bad.car 2> bad_out &&
+	grep "does not match its hash" bad_out
+'
+
 test_done
@@ -0,0 +1,123 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test diff command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+BAR_A=QmNgd5cz2jNftnAHBhcRUGdtiaMzb5Rhjqd4etondHHST8
+BAR_D=QmRfFVsjSXkhFxrfWnLpMae2M4GBVsry6VAuYYcji5MiZb
+CAT=QmUSvcqzhdfYM1KLDbM76eLPdS9ANFtkJvFuPYeZt73d7A
+DOG=QmdNJQUTZuDpsUcec7YDuCfRfvw1w4J13DCm7YcU4VMZdS
+
+test_expect_success "create some trees for testing diffs" '
+	mkdir foo &&
+	echo "stuff" > foo/bar &&
+	mkdir foo/baz &&
+	A=$(ipfs add -r -q foo | tail -n1) &&
+	echo "more things" > foo/cat &&
+	B=$(ipfs add -r -q foo | tail -n1) &&
+	echo "nested" > foo/baz/dog &&
+	echo "changed" > foo/bar &&
+	D=$(ipfs add -r -q foo | tail -n1) &&
+	mv foo/cat foo/kitten &&
+	E=$(ipfs add -r -q foo | tail -n1)
+'
+
+test_expect_success "diff against self is empty" '
+	ipfs diff $A $A > diff_out &&
+	printf "" > diff_exp &&
+	test_cmp diff_exp diff_out
+'
+
+test_expect_success "diff lists nested changes" '
+	ipfs diff $A $D > diff_out &&
+	echo "~ $BAR_A $BAR_D bar" > diff_exp &&
+	echo "+ $DOG baz/dog" >> diff_exp &&
+	echo "+ $CAT cat" >> diff_exp &&
+	test_cmp diff_exp diff_out
+'
+
+test_expect_success "diff lists removals" '
+	ipfs diff $B $A > diff_out &&
+	echo "- $CAT cat" > diff_exp &&
+	test_cmp diff_exp diff_out
+'
+
+test_expect_success "diff detects renames" '
+	ipfs diff $D $E > diff_out &&
+	echo "> $CAT cat -> kitten" > diff_exp &&
+	test_cmp diff_exp diff_out
+'
+
+test_expect_success "diff json output has kinds and sizes" '
+	ipfs diff --enc=json $A $D > diff_json &&
+	grep "\"Kind\":\"file\"" diff_json &&
+	grep "\"SizeBefore\":6" diff_json &&
+	grep "\"SizeAfter\":8" diff_json
+'
+
+test_expect_success "diff --merge merges both diffs" '
+	ipfs diff --merge=$A $B $D > diff_out &&
+	echo "~ $BAR_A $BAR_D bar" > diff_exp &&
+	echo "+ $DOG baz/dog" >> diff_exp &&
+	echo "+ $CAT cat" >> diff_exp &&
+	echo "! + $CAT cat" >> diff_exp &&
+	echo "  + $CAT cat" >> diff_exp &&
+	test_cmp diff_exp diff_out
+'
+
+test_expect_success "diff of bad paths fails" '
+	test_must_fail ipfs diff $A not-a-path
+'
+
+test_expect_success "create trees to merge" '
+	mkdir merge &&
+	echo base > merge/file &&
+	echo other > merge/other &&
+	BASE=$(ipfs add -r -q merge | tail -n1) &&
+	echo ours > merge/file &&
+	echo new > merge/new &&
+	OURS=$(ipfs add -r -q merge | tail -n1) &&
+	rm -r merge && mkdir merge &&
+	echo theirs > merge/file &&
+	THEIRS=$(ipfs add -r -q merge | tail -n1)
+'
+
+test_expect_success "object merge fails on conflicts by default" '
+	test_must_fail ipfs object merge $BASE $OURS $THEIRS 2> merge_err &&
+	grep "CONFLICT: ours changed file to" merge_err
+'
+
+test_merge_strategy() {
+	strategy=$1
+	shift
+	files="$*"
+
+	test_expect_success "object merge --strategy=$strategy works" '
+		ipfs object merge --strategy=$strategy $BASE $OURS $THEIRS > merge_out &&
+		grep CONFLICT merge_out &&
+		MERGED=$(tail -n1 merge_out)
+	'
+
+	test_expect_success "object merge --strategy=$strategy looks right" '
+		rm -rf expected && mkdir expected &&
+		for f in $files; do
+			echo ${f#*=} > expected/${f%%=*}
+		done &&
+		EXPECTED=$(ipfs add -r -q expected | tail -n1) &&
+		ipfs diff $EXPECTED $MERGED > merge_diff &&
+		printf "" > merge_diff_exp &&
+		test_cmp merge_diff_exp merge_diff
+	'
+}
+
+test_merge_strategy ours file=ours new=new
+test_merge_strategy theirs file=theirs new=new
+test_merge_strategy both file=ours file.theirs=theirs new=new
+
+test_done
@@ -0,0 +1,55 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test API tokens"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "adding a scoped first token works" '
+	ipfs config api-token add reader cat >reader_secret
+'
+
+test_expect_success "a local token allowing all commands was created" '
+	ipfs config api-token ls >tokens &&
+	grep "^local	\*$" tokens &&
+	grep "^reader	cat$" tokens &&
+	test -s "$IPFS_PATH/api_token"
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "the local command line still works" '
+	ipfs id -f="<id>" >actual &&
+	test -s actual
+'
+
+test_expect_success "requests without a token are refused" '
+	curl -s -o /dev/null -w "%{http_code}" "http://$API_ADDR/api/v0/id" >status &&
+	echo 401 >expected &&
+	test_cmp expected status
+'
+
+test_expect_success "the scoped token only allows its commands" '
+	curl -s -o /dev/null -w "%{http_code}" -H "Authorization: Bearer $(cat reader_secret)" "http://$API_ADDR/api/v0/id" >status &&
+	echo 403 >expected &&
+	test_cmp expected status
+'
+
+test_expect_success "the last token allowing all commands cannot be removed" '
+	test_must_fail ipfs config api-token rm local
+'
+
+test_expect_success "the scoped token can be removed from the local command line" '
+	ipfs config api-token rm reader &&
+	ipfs config api-token rm local &&
+	ipfs config api-token ls >tokens &&
+	test_must_be_empty tokens
+'
+
+test_kill_ipfs_daemon
+
+test_done
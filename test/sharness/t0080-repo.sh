@@ -260,6 +260,51 @@ test_expect_success "repo version came out correct" '
 	egrep "^fs-repo@[0-9]+" repo-version-q >/dev/null
 '
 
+test_expect_success "'ipfs repo gc --progress' reports its phases" '
+	ipfs repo gc --progress >/dev/null 2>gc_progress &&
+	grep "^mark: " gc_progress &&
+	grep "^done: " gc_progress
+'
+
+test_expect_success "'ipfs repo gc --max-bytes' stops on its budget" '
+	GARBAGE=$(echo "some garbage" | ipfs add -q --pin=false) &&
+	ipfs repo gc --max-bytes=1B >gc_budget_out 2>gc_budget_err &&
+	grep "budget exhausted" gc_budget_err &&
+	test_must_fail grep "$GARBAGE" gc_budget_out &&
+	ipfs refs local | grep "$GARBAGE"
+'
+
+test_expect_success "the next 'ipfs repo gc' removes the rest" '
+	ipfs repo gc >gc_rest_out &&
+	grep "removed $GARBAGE" gc_rest_out
+'
+
+test_expect_success "'ipfs repo gc --max-time' rejects bad durations" '
+	test_must_fail ipfs repo gc --max-time=forever
+'
+
+test_expect_success "'ipfs repo gc --dry-run' lists the garbage without removing it" '
+	GARBAGE=$(echo "more garbage" | ipfs add -q --pin=false) &&
+	ipfs repo gc --dry-run >gc_dry_out &&
+	grep "would remove $GARBAGE" gc_dry_out &&
+	grep "^would remove 1 blocks, freeing " gc_dry_out &&
+	ipfs refs local | grep "$GARBAGE"
+'
+
+test_expect_success "'ipfs pin why' explains what keeps an object" '
+	mkdir -p why/sub &&
+	echo "kept" >why/sub/file &&
+	WHYROOT=$(ipfs add -r -q why | tail -n1) &&
+	WHYFILE=$(ipfs resolve -r /ipfs/$WHYROOT/sub/file | cut -d/ -f3) &&
+	ipfs pin why $WHYFILE >why_out &&
+	grep "^$WHYROOT recursive /ipfs/$WHYROOT/sub/file$" why_out
+'
+
+test_expect_success "'ipfs pin why' reports objects kept by nothing" '
+	ipfs pin why $GARBAGE >why_garbage_out &&
+	grep "$GARBAGE is not kept" why_garbage_out
+'
+
 test_kill_ipfs_daemon
 
 test_done
@@ -187,4 +187,38 @@ test_expect_success "'ipfs repo fsck' output looks good with daemon" '
 
 test_kill_ipfs_daemon
 
+###########################
+# Test reference count index
+###########################
+
+test_expect_success "'ipfs repo fsck --refcounts' fails when the index is disabled" '
+  test_must_fail ipfs repo fsck --refcounts 2>fsck_refcounts_err &&
+  grep "Datastore.RefCounts" fsck_refcounts_err
+'
+
+test_expect_success "'ipfs repo fsck --refcounts' rebuilds the index" '
+  test_config_set --json Datastore.RefCounts true &&
+  echo "pinned before" >before &&
+  BEFORE=$(ipfs add -q before) &&
+  ipfs repo fsck --refcounts >fsck_refcounts_out &&
+  grep "Reference counts have been rebuilt." fsck_refcounts_out
+'
+
+test_expect_success "'ipfs repo gc --incremental' removes the released blocks" '
+  echo "pinned after" >after &&
+  AFTER=$(ipfs add -q after) &&
+  NEVER=$(echo "never pinned" | ipfs add -q --pin=false) &&
+  ipfs pin rm $BEFORE $AFTER &&
+  ipfs repo gc --incremental >gc_incr_out &&
+  grep "removed $BEFORE" gc_incr_out &&
+  grep "removed $AFTER" gc_incr_out &&
+  test_must_fail grep "$NEVER" gc_incr_out &&
+  ipfs refs local | grep "$NEVER"
+'
+
+test_expect_success "released blocks are only collected once" '
+  ipfs repo gc --incremental >gc_incr_out2 &&
+  test_must_fail grep "removed" gc_incr_out2
+'
+
 test_done
@@ -334,6 +334,40 @@ test_files_api() {
 		ipfs files rm /fun
 	'
 
+	test_expect_success "append to a file works" '
+		echo first | ipfs files write --create /log &&
+		echo second | ipfs files write --append /log &&
+		printf "first\nsecond\n" > append_expected &&
+		ipfs files read /log > append_output &&
+		test_cmp append_expected append_output
+	'
+
+	test_expect_success "append conflicts with offset" '
+		echo third | test_expect_code 1 ipfs files write --append --offset 3 /log
+	'
+
+	test_expect_success "cleanup" '
+		ipfs files rm /log
+	'
+
+	test_expect_success "write creates a file with --cid-version=1" '
+		echo first | ipfs files write --create --cid-version=1 /v1file &&
+		echo second | ipfs files write --append /v1file &&
+		ipfs files stat --hash /v1file > v1hash &&
+		grep "^z" v1hash &&
+		printf "first\nsecond\n" > v1_expected &&
+		ipfs cat $(cat v1hash) > v1_output &&
+		test_cmp v1_expected v1_output
+	'
+
+	test_expect_success "write rejects CIDv0 with another hash function" '
+		echo first | test_expect_code 1 ipfs files write --create --cid-version=0 --hash=sha2-512 /v0file
+	'
+
+	test_expect_success "cleanup" '
+		ipfs files rm /v1file
+	'
+
 	test_expect_success "cannot write to directory" '
 		ipfs files stat --hash /cats > dirhash &&
 		test_expect_code 1 ipfs files write /cats < output
@@ -478,6 +512,30 @@ test_files_api() {
 		ipfs files rm -r /foobar &&
 		ipfs files rm -r /adir
 	'
+
+	test_expect_success "can create a symlink" '
+		ipfs files ln -s ../some/target /alink
+	'
+
+	test_expect_success "symlink can be read back" '
+		echo "../some/target" > link_expected &&
+		ipfs files readlink /alink > link_actual &&
+		test_cmp link_expected link_actual
+	'
+
+	test_expect_success "symlink is reported as such" '
+		ipfs files stat --format="<type>" /alink > link_type &&
+		echo symlink > link_type_expected &&
+		test_cmp link_type_expected link_type
+	'
+
+	test_expect_success "ln without -s fails" '
+		test_expect_code 1 ipfs files ln ../some/target /alink2
+	'
+
+	test_expect_success "clean up symlink" '
+		ipfs files rm /alink
+	'
 }
 
 # test offline and online
@@ -10,8 +10,20 @@ import (
 	"strings"
 )
 
+// Extended attributes set on the entries of an archive whose mode or
+// modification time are recorded, and applied when extracting them. The
+// extracted files keep their default mode and time otherwise.
+const (
+	ModeXattr  = "ipfs.mode"
+	MtimeXattr = "ipfs.mtime"
+)
+
 type Extractor struct {
 	Path string
+
+	// directories created, whose mode and times are set once all of
+	// their content has been written
+	dirs []*tar.Header
 }
 
 func (te *Extractor) Extract(reader io.Reader) error {
@@ -50,13 +62,35 @@ func (te *Extractor) Extract(reader io.Reader) error {
 				return err
 			}
 		case tar.TypeSymlink:
-			if err := te.extractSymlink(header); err != nil {
+			if err := te.extractSymlink(header, i, rootExists, rootIsDir); err != nil {
 				return err
 			}
 		default:
 			return fmt.Errorf("unrecognized tar header type: %d", header.Typeflag)
 		}
 	}
+
+	// deepest first, so that setting a parent's times comes last
+	for i := len(te.dirs) - 1; i >= 0; i-- {
+		h := te.dirs[i]
+		if err := setModeAndTime(te.outputPath(h.Name), h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setModeAndTime applies the permission bits and modification time of h to
+// the file at path, for those the archive records.
+func setModeAndTime(path string, h *tar.Header) error {
+	if _, ok := h.Xattrs[ModeXattr]; ok {
+		if err := os.Chmod(path, os.FileMode(h.Mode).Perm()); err != nil {
+			return err
+		}
+	}
+	if _, ok := h.Xattrs[MtimeXattr]; ok {
+		return os.Chtimes(path, h.ModTime, h.ModTime)
+	}
 	return nil
 }
 
@@ -78,19 +112,34 @@ func (te *Extractor) extractDir(h *tar.Header, depth int) error {
 		te.Path = path
 	}
 
+	// directories which exist already are left as they are
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return nil
+	}
+
 	err := os.MkdirAll(path, 0755)
 	if err != nil {
 		return err
 	}
 
+	te.dirs = append(te.dirs, h)
 	return nil
 }
 
-func (te *Extractor) extractSymlink(h *tar.Header) error {
-	return os.Symlink(h.Linkname, te.outputPath(h.Name))
+func (te *Extractor) extractSymlink(h *tar.Header, depth int, rootExists bool, rootIsDir bool) error {
+	path := te.leafPath(h, depth, rootExists, rootIsDir)
+
+	// like files, an existing non-directory is replaced
+	if fi, err := os.Lstat(path); err == nil && !fi.IsDir() {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(h.Linkname, path)
 }
 
-func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, rootExists bool, rootIsDir bool) error {
+// leafPath returns the path at which to place the file or symlink h.
+func (te *Extractor) leafPath(h *tar.Header, depth int, rootExists bool, rootIsDir bool) string {
 	path := te.outputPath(h.Name)
 
 	if depth == 0 { // if depth is 0, this is the only file (we aren't 'ipfs get'ing a directory)
@@ -104,6 +153,11 @@ func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, rootEx
 			}
 		} // else if old file exists, just overwrite it.
 	}
+	return path
+}
+
+func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, rootExists bool, rootIsDir bool) error {
+	path := te.leafPath(h, depth, rootExists, rootIsDir)
 
 	file, err := os.Create(path)
 	if err != nil {
@@ -116,5 +170,8 @@ func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, rootEx
 		return err
 	}
 
-	return nil
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return setModeAndTime(path, h)
 }
@@ -10,6 +10,7 @@ import (
 	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
 
 	mdag "github.com/ipfs/go-ipfs/merkledag"
+	extractor "github.com/ipfs/go-ipfs/thirdparty/tar"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 	upb "github.com/ipfs/go-ipfs/unixfs/pb"
@@ -34,8 +35,8 @@ func NewWriter(ctx cxt.Context, dag mdag.DAGService, archive bool, compression i
 	}, nil
 }
 
-func (w *Writer) writeDir(nd *mdag.ProtoNode, fpath string) error {
-	if err := writeDirHeader(w.TarW, fpath); err != nil {
+func (w *Writer) writeDir(nd *mdag.ProtoNode, pb *upb.Data, fpath string) error {
+	if err := writeDirHeader(w.TarW, fpath, pb); err != nil {
 		return err
 	}
 
@@ -60,7 +61,7 @@ func (w *Writer) writeDir(nd *mdag.ProtoNode, fpath string) error {
 }
 
 func (w *Writer) writeFile(nd *mdag.ProtoNode, pb *upb.Data, fpath string) error {
-	if err := writeFileHeader(w.TarW, fpath, pb.GetFilesize()); err != nil {
+	if err := writeFileHeader(w.TarW, fpath, pb); err != nil {
 		return err
 	}
 
@@ -82,7 +83,7 @@ func (w *Writer) WriteNode(nd *mdag.ProtoNode, fpath string) error {
 	case upb.Data_Metadata:
 		fallthrough
 	case upb.Data_Directory:
-		return w.writeDir(nd, fpath)
+		return w.writeDir(nd, pb, fpath)
 	case upb.Data_Raw:
 		fallthrough
 	case upb.Data_File:
@@ -98,25 +99,46 @@ func (w *Writer) Close() error {
 	return w.TarW.Close()
 }
 
-func writeDirHeader(w *tar.Writer, fpath string) error {
-	return w.WriteHeader(&tar.Header{
+// setUnixMeta applies the mode and modification time recorded in pb to h,
+// marking them for the extractor. Others keep the defaults of h.
+func setUnixMeta(h *tar.Header, pb *upb.Data) {
+	m := ft.GetUnixMeta(pb)
+	if m.IsZero() {
+		return
+	}
+
+	h.Xattrs = make(map[string]string)
+	if m.HasMode {
+		h.Mode = int64(m.Mode)
+		h.Xattrs[extractor.ModeXattr] = "1"
+	}
+	if !m.ModTime.IsZero() {
+		h.ModTime = m.ModTime
+		h.Xattrs[extractor.MtimeXattr] = "1"
+	}
+}
+
+func writeDirHeader(w *tar.Writer, fpath string, pb *upb.Data) error {
+	h := &tar.Header{
 		Name:     fpath,
 		Typeflag: tar.TypeDir,
 		Mode:     0777,
 		ModTime:  time.Now(),
-		// TODO: set mode, dates, etc. when added to unixFS
-	})
+	}
+	setUnixMeta(h, pb)
+	return w.WriteHeader(h)
 }
 
-func writeFileHeader(w *tar.Writer, fpath string, size uint64) error {
-	return w.WriteHeader(&tar.Header{
+func writeFileHeader(w *tar.Writer, fpath string, pb *upb.Data) error {
+	h := &tar.Header{
 		Name:     fpath,
-		Size:     int64(size),
+		Size:     int64(pb.GetFilesize()),
 		Typeflag: tar.TypeReg,
 		Mode:     0644,
 		ModTime:  time.Now(),
-		// TODO: set mode, dates, etc. when added to unixFS
-	})
+	}
+	setUnixMeta(h, pb)
+	return w.WriteHeader(h)
 }
 
 func writeSymlinkHeader(w *tar.Writer, target, fpath string) error {
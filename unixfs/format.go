@@ -5,6 +5,8 @@ package unixfs
 
 import (
 	"errors"
+	"os"
+	"time"
 
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	pb "github.com/ipfs/go-ipfs/unixfs/pb"
@@ -128,6 +130,9 @@ func DataSize(data []byte) (uint64, error) {
 type FSNode struct {
 	Data []byte
 
+	// optional unix permission bits and modification time
+	Meta UnixMeta
+
 	// total data size for each child
 	blocksizes []uint64
 
@@ -150,6 +155,7 @@ func FSNodeFromBytes(b []byte) (*FSNode, error) {
 	n.blocksizes = pbn.Blocksizes
 	n.subtotal = pbn.GetFilesize() - uint64(len(n.Data))
 	n.Type = pbn.GetType()
+	n.Meta = GetUnixMeta(pbn)
 	return n, nil
 }
 
@@ -170,6 +176,7 @@ func (n *FSNode) GetBytes() ([]byte, error) {
 	pbn.Filesize = proto.Uint64(uint64(len(n.Data)) + n.subtotal)
 	pbn.Blocksizes = n.blocksizes
 	pbn.Data = n.Data
+	setUnixMeta(pbn, n.Meta)
 	return proto.Marshal(pbn)
 }
 
@@ -181,6 +188,64 @@ func (n *FSNode) NumChildren() int {
 	return len(n.blocksizes)
 }
 
+// UnixMeta is the optional unix metadata of a node.
+type UnixMeta struct {
+	// Mode holds the permission bits if HasMode is set, 0 being a valid
+	// mode.
+	Mode    os.FileMode
+	HasMode bool
+
+	// ModTime is the modification time, zero if unset.
+	ModTime time.Time
+}
+
+// IsZero reports whether m records nothing.
+func (m UnixMeta) IsZero() bool {
+	return !m.HasMode && m.ModTime.IsZero()
+}
+
+// GetUnixMeta returns the permission bits and modification time recorded in
+// pbdata.
+func GetUnixMeta(pbdata *pb.Data) UnixMeta {
+	var m UnixMeta
+	if pbdata.Mode != nil {
+		m.Mode = os.FileMode(pbdata.GetMode()) & os.ModePerm
+		m.HasMode = true
+	}
+	if t := pbdata.GetMtime(); t != nil {
+		m.ModTime = time.Unix(t.GetSeconds(), int64(t.GetFractionalNanoseconds()))
+	}
+	return m
+}
+
+func setUnixMeta(pbdata *pb.Data, m UnixMeta) {
+	pbdata.Mode = nil
+	if m.HasMode {
+		pbdata.Mode = proto.Uint32(uint32(m.Mode.Perm()))
+	}
+
+	pbdata.Mtime = nil
+	if !m.ModTime.IsZero() {
+		pbdata.Mtime = &pb.UnixTime{Seconds: proto.Int64(m.ModTime.Unix())}
+		if ns := m.ModTime.Nanosecond(); ns != 0 {
+			pbdata.Mtime.FractionalNanoseconds = proto.Uint32(uint32(ns))
+		}
+	}
+}
+
+// WithUnixMeta returns the unixfs data of a node with its permission bits
+// and modification time replaced by those of m. Unset fields are removed,
+// so that nodes without metadata hash as before.
+func WithUnixMeta(data []byte, m UnixMeta) ([]byte, error) {
+	pbdata, err := FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	setUnixMeta(pbdata, m)
+	return proto.Marshal(pbdata)
+}
+
 type Metadata struct {
 	MimeType string
 	Size     uint64
@@ -3,6 +3,7 @@ package unixfs
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
 
@@ -159,3 +160,56 @@ func TestMetadata(t *testing.T) {
 	}
 
 }
+
+func TestUnixMeta(t *testing.T) {
+	mtime := time.Unix(1490000000, 500)
+
+	data, err := WithUnixMeta(FolderPBData(), UnixMeta{Mode: 0755, HasMode: true, ModTime: mtime})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pbn, err := FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pbn.GetType() != TDirectory {
+		t.Fatal("node type was not preserved")
+	}
+
+	m := GetUnixMeta(pbn)
+	if !m.HasMode || m.Mode != 0755 || !m.ModTime.Equal(mtime) {
+		t.Fatalf("got mode %o and mtime %s", m.Mode, m.ModTime)
+	}
+
+	// the metadata survives a round trip through FSNode
+	fsn, err := FSNodeFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsn.Meta.Mode != 0755 || !fsn.Meta.ModTime.Equal(mtime) {
+		t.Fatal("FSNode lost mode or mtime")
+	}
+
+	// a mode of 0 is recorded too
+	zero, err := WithUnixMeta(FolderPBData(), UnixMeta{HasMode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbn, err = FromBytes(zero)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := GetUnixMeta(pbn); !m.HasMode || m.Mode != 0 {
+		t.Fatal("mode 0 was not recorded")
+	}
+
+	// clearing it gives back the original bytes
+	cleared, err := WithUnixMeta(data, UnixMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cleared, FolderPBData()) {
+		t.Fatal("clearing mode and mtime did not restore the original node")
+	}
+}
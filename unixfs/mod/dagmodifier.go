@@ -179,42 +179,41 @@ func (dm *DagModifier) Sync() error {
 	// Number of bytes we're going to write
 	buflen := dm.wrBuf.Len()
 
-	// overwrite existing dag nodes
-	thisc, done, err := dm.modifyDag(dm.curNode, dm.writeStart, dm.wrBuf)
-	if err != nil {
-		return err
-	}
-
-	nd, err := dm.dagserv.Get(dm.ctx, thisc)
+	pbn, err := ft.FromBytes(dm.curNode.Data())
 	if err != nil {
 		return err
 	}
 
-	pbnd, ok := nd.(*mdag.ProtoNode)
-	if !ok {
-		return mdag.ErrNotProtobuf
-	}
-
-	dm.curNode = pbnd
-
-	// need to write past end of current dag
-	if !done {
-		nd, err := dm.appendData(dm.curNode, dm.splitter(dm.wrBuf))
+	// A write starting at the end of the file leaves all existing data
+	// untouched: modifyDag would only go through the links of the root to
+	// find nothing to overwrite.
+	done := false
+	if dm.writeStart != pbn.GetFilesize() {
+		// overwrite existing dag nodes
+		thisc, sdone, err := dm.modifyDag(dm.curNode, dm.writeStart, dm.wrBuf)
 		if err != nil {
 			return err
 		}
 
-		_, err = dm.dagserv.Add(nd)
+		nd, err := dm.dagserv.Get(dm.ctx, thisc)
 		if err != nil {
 			return err
 		}
 
-		pbnode, ok := nd.(*mdag.ProtoNode)
+		pbnd, ok := nd.(*mdag.ProtoNode)
 		if !ok {
 			return mdag.ErrNotProtobuf
 		}
 
-		dm.curNode = pbnode
+		dm.curNode = pbnd
+		done = sdone
+	}
+
+	// need to write past end of current dag
+	if !done {
+		if err := dm.appendBuffer(); err != nil {
+			return err
+		}
 	}
 
 	dm.writeStart += uint64(buflen)
@@ -246,6 +245,7 @@ func (dm *DagModifier) modifyDag(node *mdag.ProtoNode, offset uint64, data io.Re
 		}
 
 		nd := new(mdag.ProtoNode)
+		nd.Prefix = node.Prefix
 		nd.SetData(b)
 		k, err := dm.dagserv.Add(nd)
 		if err != nil {
@@ -304,11 +304,37 @@ func (dm *DagModifier) modifyDag(node *mdag.ProtoNode, offset uint64, data io.Re
 	return k, done, err
 }
 
-// appendData appends the blocks from the given chan to the end of this dag
+// appendBuffer appends what is left in the write buffer to the end of the
+// dag with trickle.TrickleAppend, which only fetches and rewrites the
+// rightmost spine of the dag.
+func (dm *DagModifier) appendBuffer() error {
+	nd, err := dm.appendData(dm.curNode, dm.splitter(dm.wrBuf))
+	if err != nil {
+		return err
+	}
+
+	_, err = dm.dagserv.Add(nd)
+	if err != nil {
+		return err
+	}
+
+	pbnode, ok := nd.(*mdag.ProtoNode)
+	if !ok {
+		return mdag.ErrNotProtobuf
+	}
+
+	dm.curNode = pbnode
+	return nil
+}
+
+// appendData appends the blocks from the given chan to the end of this dag.
+// The new nodes are hashed like the root of the dag.
 func (dm *DagModifier) appendData(node *mdag.ProtoNode, spl chunk.Splitter) (node.Node, error) {
+	prefix := node.Cid().Prefix()
 	dbp := &help.DagBuilderParams{
 		Dagserv:  dm.dagserv,
 		Maxlinks: help.DefaultLinksPerBlock,
+		Prefix:   &prefix,
 	}
 
 	return trickle.TrickleAppend(dm.ctx, node, dbp.New(spl))
@@ -461,14 +487,25 @@ func dagTruncate(ctx context.Context, nd *mdag.ProtoNode, size uint64, ds mdag.D
 			return nil, err
 		}
 
-		nd.SetData(ft.WrapData(pbn.Data[:size]))
+		data, err := ft.WithUnixMeta(ft.WrapData(pbn.Data[:size]), ft.GetUnixMeta(pbn))
+		if err != nil {
+			return nil, err
+		}
+
+		nd.SetData(data)
 		return nd, nil
 	}
 
+	pbn, err := ft.FromBytes(nd.Data())
+	if err != nil {
+		return nil, err
+	}
+
 	var cur uint64
 	end := 0
 	var modified *mdag.ProtoNode
 	ndata := new(ft.FSNode)
+	ndata.Meta = ft.GetUnixMeta(pbn)
 	for i, lnk := range nd.Links() {
 		child, err := lnk.GetNode(ctx, ds)
 		if err != nil {
@@ -502,7 +539,7 @@ func dagTruncate(ctx context.Context, nd *mdag.ProtoNode, size uint64, ds mdag.D
 		ndata.AddBlockSize(childsize)
 	}
 
-	_, err := ds.Add(modified)
+	_, err = ds.Add(modified)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package mod
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -9,6 +10,8 @@ import (
 	"github.com/ipfs/go-ipfs/blocks/blockstore"
 	bs "github.com/ipfs/go-ipfs/blockservice"
 	"github.com/ipfs/go-ipfs/exchange/offline"
+	imp "github.com/ipfs/go-ipfs/importer"
+	chunk "github.com/ipfs/go-ipfs/importer/chunk"
 	h "github.com/ipfs/go-ipfs/importer/helpers"
 	trickle "github.com/ipfs/go-ipfs/importer/trickle"
 	mdag "github.com/ipfs/go-ipfs/merkledag"
@@ -17,9 +20,11 @@ import (
 	testu "github.com/ipfs/go-ipfs/unixfs/test"
 
 	context "context"
+	node "gx/ipfs/QmRSU5EqqWVZSNdbU51yXmVoF1uNw3JgTNB6RaiL7DZM16/go-ipld-node"
 	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
 	"gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
 	u "gx/ipfs/Qmb912gdngC1UWwTkhuW8knyRbcWeu5kqkxBpveLmW8bSr/go-ipfs-util"
+	cid "gx/ipfs/QmcTcsTvfaeEBRFo1TkFgT8sRmgi1n1LTZpecfVP8fzpGD/go-cid"
 )
 
 func getMockDagServAndBstore(t testing.TB) (mdag.DAGService, blockstore.Blockstore) {
@@ -275,6 +280,55 @@ func TestWriteNewFile(t *testing.T) {
 	}
 }
 
+func TestWriteKeepsPrefix(t *testing.T) {
+	dserv := testu.GetDAGServ()
+	n := mdag.NodeWithData(ft.FilePBData(nil, 0))
+	pref := mdag.V1CidPrefix()
+	n.SetPrefix(&pref)
+	if _, err := dserv.Add(n); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dagmod, err := NewDagModifier(ctx, n, dserv, testu.SizeSplitterGen(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	towrite := make([]byte, 5000)
+	u.NewTimeSeededRand().Read(towrite)
+
+	// append to the empty file, then overwrite some of it
+	if _, err := dagmod.Write(towrite); err != nil {
+		t.Fatal(err)
+	}
+	if err := dagmod.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dagmod.WriteAt(towrite[:1000], 100); err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := dagmod.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nd.Cid().Prefix().Version != 1 {
+		t.Fatal("root of the file is not CIDv1")
+	}
+	err = mdag.EnumerateChildren(ctx, dserv, nd.Cid(), func(c *cid.Cid) bool {
+		if c.Prefix().Version != 1 {
+			t.Fatalf("node %s of the file is not CIDv1", c)
+		}
+		return true
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestMultiWriteCoal(t *testing.T) {
 	dserv := testu.GetDAGServ()
 	n := testu.GetEmptyNode(t, dserv)
@@ -719,3 +773,147 @@ func BenchmarkDagmodWrite(b *testing.B) {
 		}
 	}
 }
+
+// countingDAGService counts the nodes fetched through it
+type countingDAGService struct {
+	mdag.DAGService
+	gets int
+}
+
+func (c *countingDAGService) Get(ctx context.Context, k *cid.Cid) (node.Node, error) {
+	c.gets++
+	return c.DAGService.Get(ctx, k)
+}
+
+func getLargeNode(t testing.TB, dserv mdag.DAGService, size int64) node.Node {
+	in := io.LimitReader(u.NewTimeSeededRand(), size)
+	nd, err := imp.BuildTrickleDagFromReader(dserv, chunk.NewSizeSplitter(in, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+// spineLength returns the number of nodes under nd on the rightmost path of
+// its dag, leaves excluded: the nodes a trickle append has to fetch.
+func spineLength(t *testing.T, ctx context.Context, dserv mdag.DAGService, nd node.Node) int {
+	n := 0
+	for {
+		links := nd.Links()
+		if len(links) == 0 {
+			return n
+		}
+
+		child, err := links[len(links)-1].GetNode(ctx, dserv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(child.Links()) == 0 {
+			return n
+		}
+		nd = child
+		n++
+	}
+}
+
+func TestAppendFetchesOnlySpine(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, size := range []int64{1 << 16, 1 << 20, 1 << 23} {
+		dserv := &countingDAGService{DAGService: testu.GetDAGServ()}
+		nd := getLargeNode(t, dserv, size)
+
+		dagmod, err := NewDagModifier(ctx, nd, dserv, testu.SizeSplitterGen(4096))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := dagmod.Seek(0, os.SEEK_END); err != nil {
+			t.Fatal(err)
+		}
+
+		spine := spineLength(t, ctx, dserv.DAGService, nd)
+
+		dserv.gets = 0
+		data := make([]byte, 10000)
+		u.NewTimeSeededRand().Read(data)
+		if _, err := dagmod.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := dagmod.Sync(); err != nil {
+			t.Fatal(err)
+		}
+
+		// only the nodes on the rightmost spine are fetched, as many as the
+		// dag is deep
+		if dserv.gets > spine {
+			t.Fatalf("appending to a %d byte file fetched %d nodes, its spine has %d", size, dserv.gets, spine)
+		}
+
+		out, err := dagmod.GetNode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = trickle.VerifyTrickleDagStructure(out, dserv, h.DefaultLinksPerBlock, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rd, err := uio.NewDagReader(ctx, out, dserv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rd.Size() != uint64(size)+uint64(len(data)) {
+			t.Fatalf("expected size %d, got %d", size+int64(len(data)), rd.Size())
+		}
+		if _, err := rd.Seek(size, os.SEEK_SET); err != nil {
+			t.Fatal(err)
+		}
+		tail, err := ioutil.ReadAll(rd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := testu.ArrComp(tail, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDagmodAppend appends 4KB to files of increasing size. As the
+// trickle append only rewrites the rightmost spine of the dag, the time per
+// append should grow with the depth of the dag, not the file size.
+func BenchmarkDagmodAppend(b *testing.B) {
+	for _, size := range []int64{1 << 20, 1 << 24, 1 << 26} {
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			dserv := testu.GetDAGServ()
+			nd := getLargeNode(b, dserv, size)
+
+			dagmod, err := NewDagModifier(ctx, nd, dserv, testu.SizeSplitterGen(4096))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := dagmod.Seek(0, os.SEEK_END); err != nil {
+				b.Fatal(err)
+			}
+
+			buf := make([]byte, 4096)
+			u.NewTimeSeededRand().Read(buf)
+
+			b.SetBytes(int64(len(buf)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := dagmod.Write(buf); err != nil {
+					b.Fatal(err)
+				}
+				if err := dagmod.Sync(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	Data
+	UnixTime
 	Metadata
 */
 package unixfs_pb
@@ -70,6 +71,8 @@ type Data struct {
 	Data             []byte         `protobuf:"bytes,2,opt,name=Data" json:"Data,omitempty"`
 	Filesize         *uint64        `protobuf:"varint,3,opt,name=filesize" json:"filesize,omitempty"`
 	Blocksizes       []uint64       `protobuf:"varint,4,rep,name=blocksizes" json:"blocksizes,omitempty"`
+	Mode             *uint32        `protobuf:"varint,7,opt,name=mode" json:"mode,omitempty"`
+	Mtime            *UnixTime      `protobuf:"bytes,8,opt,name=mtime" json:"mtime,omitempty"`
 	XXX_unrecognized []byte         `json:"-"`
 }
 
@@ -105,6 +108,44 @@ func (m *Data) GetBlocksizes() []uint64 {
 	return nil
 }
 
+func (m *Data) GetMode() uint32 {
+	if m != nil && m.Mode != nil {
+		return *m.Mode
+	}
+	return 0
+}
+
+func (m *Data) GetMtime() *UnixTime {
+	if m != nil {
+		return m.Mtime
+	}
+	return nil
+}
+
+type UnixTime struct {
+	Seconds               *int64  `protobuf:"varint,1,req,name=Seconds" json:"Seconds,omitempty"`
+	FractionalNanoseconds *uint32 `protobuf:"fixed32,2,opt,name=FractionalNanoseconds" json:"FractionalNanoseconds,omitempty"`
+	XXX_unrecognized      []byte  `json:"-"`
+}
+
+func (m *UnixTime) Reset()         { *m = UnixTime{} }
+func (m *UnixTime) String() string { return proto.CompactTextString(m) }
+func (*UnixTime) ProtoMessage()    {}
+
+func (m *UnixTime) GetSeconds() int64 {
+	if m != nil && m.Seconds != nil {
+		return *m.Seconds
+	}
+	return 0
+}
+
+func (m *UnixTime) GetFractionalNanoseconds() uint32 {
+	if m != nil && m.FractionalNanoseconds != nil {
+		return *m.FractionalNanoseconds
+	}
+	return 0
+}
+
 type Metadata struct {
 	MimeType         *string `protobuf:"bytes,1,opt,name=MimeType" json:"MimeType,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
@@ -123,6 +164,7 @@ func (m *Metadata) GetMimeType() string {
 
 func init() {
 	proto.RegisterType((*Data)(nil), "unixfs.pb.Data")
+	proto.RegisterType((*UnixTime)(nil), "unixfs.pb.UnixTime")
 	proto.RegisterType((*Metadata)(nil), "unixfs.pb.Metadata")
 	proto.RegisterEnum("unixfs.pb.Data_DataType", Data_DataType_name, Data_DataType_value)
 }